@@ -4,9 +4,11 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,8 +17,10 @@ import (
 	"github.com/hjames9/grpcwebcurl/pkg/format"
 	"github.com/hjames9/grpcwebcurl/pkg/protocol"
 	"github.com/spf13/cobra"
-	"google.golang.org/protobuf/proto"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/dynamicpb"
 )
 
@@ -32,16 +36,66 @@ var (
 	plaintext      bool
 	certFile       string
 	keyFile        string
-	caFile         string
-	resolve        string
+	certPassword   string
+	caFiles        []string
+	serverName     string
+	tlsMin         string
+	tlsMax         string
+	ciphers        []string
+	alpn           []string
+	keyLogFile     string
+	pinnedPubKey   string
+	resolve        []string
+	connectTo      []string
+	proxy          string
+	noProxy        string
+	unixSocket     string
+	abstractSocket string
 	connectTimeout time.Duration
 	timeout        time.Duration
+	serverTimeout  time.Duration
 	maxMsgSize     int
 	emitDefaults   bool
 	verbose        bool
 	useReflection  bool
 	outputFormat   string
 	showTrailers   bool
+	binTypes       []string
+	textMode       bool
+	wireMode       string
+	compression    string
+	rpcProtocol    string
+	codecName      string
+	httpGet        bool
+	retries        int
+	retryCodes     string
+	retryBackoff   string
+	serviceConfig  string
+
+	// Credential flags
+	tokenFile         string
+	tokenEnv          string
+	oauthTokenURL     string
+	oauthClientID     string
+	oauthClientSecret string
+	oauthScopes       []string
+	oauthAudience     string
+	oauthRefreshToken string
+	jwtKey            string
+	jwtClaims         string
+	jwtAudience       string
+
+	// Browser emulation flags
+	cookieJar string
+	cookies   []string
+	origin    string
+
+	// Tracing flags
+	traceExporter  string
+	otlpEndpoint   string
+	traceID        string
+	traceBin       bool
+	tracerProvider *sdktrace.TracerProvider // Shut down by closeClient to flush spans
 )
 
 func main() {
@@ -74,6 +128,14 @@ Examples:
   grpcwebcurl -plaintext -d '{"id": "123"}' \
     http://localhost:8080 package.Service/Method
 
+  # Use the Connect protocol
+  grpcwebcurl -protocol connect -d '{"id": "123"}' \
+    https://api.example.com:443 package.Service/Method
+
+  # Send grpc-web+json frames without proto files or reflection
+  grpcwebcurl -codec json -d '{"id": "123"}' \
+    https://api.example.com:443 package.Service/Method
+
   # Read request data from stdin
   echo '{"id": "123"}' | grpcwebcurl -proto api.proto -d @ \
     https://api.example.com:443 package.Service/Method`,
@@ -89,19 +151,71 @@ Examples:
 
 	// Request flags
 	rootCmd.Flags().StringVarP(&data, "data", "d", "", "Request data in JSON format (use @ to read from stdin)")
-	rootCmd.PersistentFlags().StringArrayVarP(&headers, "header", "H", nil, "Custom headers in 'Key: Value' format")
+	rootCmd.PersistentFlags().StringArrayVarP(&headers, "header", "H", nil, "Custom headers in 'Key: Value' format; -bin values are raw unless given as @file, hex:, 0x or base64:")
+	rootCmd.Flags().StringVar(&compression, "compress", "", "Compress request messages: "+strings.Join(protocol.RegisteredCompressors(), ", "))
 
 	// TLS flags (persistent for subcommands)
 	rootCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "k", false, "Skip TLS certificate verification")
 	rootCmd.PersistentFlags().BoolVar(&plaintext, "plaintext", false, "Use plaintext HTTP (no TLS)")
-	rootCmd.PersistentFlags().StringVar(&certFile, "cert", "", "Client certificate file")
-	rootCmd.PersistentFlags().StringVar(&keyFile, "key", "", "Client private key file")
-	rootCmd.PersistentFlags().StringVar(&caFile, "cacert", "", "CA certificate file")
-	rootCmd.PersistentFlags().StringVar(&resolve, "resolve", "", "Resolve host:port to address (e.g., example.com:443:127.0.0.1)")
+	rootCmd.PersistentFlags().StringVar(&certFile, "cert", "", "Client certificate file: PEM, or PKCS#12 (.p12/.pfx) with --pass")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "key", "", "Client private key file (default: the PEM --cert file)")
+	rootCmd.PersistentFlags().StringVar(&certPassword, "pass", "", "Password for a PKCS#12 client certificate")
+	rootCmd.PersistentFlags().StringArrayVar(&caFiles, "cacert", nil, "CA certificate file or directory added to the system CAs, repeatable")
+	rootCmd.PersistentFlags().StringVar(&serverName, "servername", "", "TLS server name (SNI) and name to verify, instead of the address host")
+	rootCmd.PersistentFlags().StringVar(&tlsMin, "tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	rootCmd.PersistentFlags().StringVar(&tlsMax, "tls-max", "", "Maximum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	rootCmd.PersistentFlags().StringSliceVar(&ciphers, "ciphers", nil, "Comma- or colon-separated TLS 1.0-1.2 cipher suites (e.g., TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)")
+	rootCmd.PersistentFlags().StringSliceVar(&alpn, "alpn", nil, "Comma-separated ALPN protocols to offer (default: h2,http/1.1)")
+	rootCmd.PersistentFlags().StringVar(&keyLogFile, "keylog-file", "", "Append TLS session keys to this file for Wireshark (default: from SSLKEYLOGFILE)")
+	rootCmd.PersistentFlags().StringVar(&pinnedPubKey, "pinned-pubkey", "", "Require the server's public key to match: sha256//<base64>[;sha256//<base64>]..., or a PEM or DER public key file")
+	rootCmd.PersistentFlags().StringArrayVar(&resolve, "resolve", nil, "Resolve host:port to addresses tried in order, repeatable (e.g., example.com:443:127.0.0.1,[::1]; * matches any host)")
+	rootCmd.PersistentFlags().StringArrayVar(&connectTo, "connect-to", nil, "Connect to host2:port2 instead of host:port, repeatable (e.g., example.com:443:backend.local:8443)")
+	rootCmd.PersistentFlags().StringVarP(&proxy, "proxy", "x", "", "Proxy URL: http, https, socks5 or socks5h, with optional user:password@ (default: from HTTPS_PROXY, HTTP_PROXY or ALL_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&noProxy, "noproxy", "", "Comma-separated hosts not to proxy, or * for none (default: from NO_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&unixSocket, "unix-socket", "", "Connect through this Unix domain socket; the address still sets the Host and path")
+	rootCmd.PersistentFlags().StringVar(&abstractSocket, "abstract-unix-socket", "", "Connect through this Linux abstract Unix domain socket")
+
+	// Wire format flags (persistent so reflection uses the same mode)
+	rootCmd.PersistentFlags().BoolVar(&textMode, "text", false, "Use the grpc-web-text (base64) wire format (same as --mode=text)")
+	rootCmd.PersistentFlags().StringVar(&wireMode, "mode", "binary", "Wire format: binary or text")
+	rootCmd.PersistentFlags().StringVar(&rpcProtocol, "protocol", client.ProtocolGRPCWeb, "Protocol: grpc-web or connect")
+	rootCmd.PersistentFlags().StringVar(&codecName, "codec", protocol.CodecProto, "Message codec: proto or json (json works without descriptors)")
+	rootCmd.Flags().BoolVar(&httpGet, "http-get", false, "Use GET for unary Connect calls to idempotent (no side effects) methods")
 
 	// Timeout flags (persistent for subcommands)
 	rootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", 10*time.Second, "Connection timeout")
 	rootCmd.PersistentFlags().DurationVar(&timeout, "max-time", 30*time.Second, "Maximum time for the request")
+	rootCmd.Flags().DurationVar(&serverTimeout, "server-timeout", 0, "Deadline sent in grpc-timeout instead of the remaining --max-time (for testing deadline propagation)")
+
+	// Retry flags
+	rootCmd.Flags().IntVar(&retries, "retry", 0, "Retry failed calls up to this many times")
+	rootCmd.Flags().StringVar(&retryCodes, "retry-codes", "UNAVAILABLE", "Comma-separated gRPC status codes to retry")
+	rootCmd.Flags().StringVar(&retryBackoff, "retry-backoff", "100ms,5s,2", "Retry backoff as initial[,max[,multiplier]]")
+	rootCmd.Flags().StringVar(&serviceConfig, "service-config", "", "gRPC service config JSON file with per-method timeouts and retry or hedging policies")
+
+	// Credential flags (persistent so reflection is authenticated too)
+	rootCmd.PersistentFlags().StringVar(&tokenFile, "token-file", "", "Send the bearer token in this file, re-read on every call")
+	rootCmd.PersistentFlags().StringVar(&tokenEnv, "token-env", "", "Send the bearer token in this environment variable")
+	rootCmd.PersistentFlags().StringVar(&oauthTokenURL, "oauth-token-url", "", "OAuth2 token endpoint for client credentials or refresh token grants")
+	rootCmd.PersistentFlags().StringVar(&oauthClientID, "oauth-client-id", "", "OAuth2 client ID")
+	rootCmd.PersistentFlags().StringVar(&oauthClientSecret, "oauth-client-secret", "", "OAuth2 client secret (@file reads it from a file)")
+	rootCmd.PersistentFlags().StringSliceVar(&oauthScopes, "oauth-scope", nil, "Comma-separated OAuth2 scopes to request")
+	rootCmd.PersistentFlags().StringVar(&oauthAudience, "oauth-audience", "", "OAuth2 audience parameter, for providers that require one")
+	rootCmd.PersistentFlags().StringVar(&oauthRefreshToken, "oauth-refresh-token", "", "Use the refresh token grant with this token (@file reads it from a file)")
+	rootCmd.PersistentFlags().StringVar(&jwtKey, "jwt-key", "", "Sign a JWT for every call with this PEM private key or service account JSON key (RS256/ES256)")
+	rootCmd.PersistentFlags().StringVar(&jwtClaims, "jwt-claims", "", "JSON file of claims for --jwt-key tokens, such as iss and sub")
+	rootCmd.PersistentFlags().StringVar(&jwtAudience, "jwt-audience", "", "aud claim for --jwt-key tokens (default: the address and service)")
+
+	// Browser emulation flags (persistent so reflection shares the session)
+	rootCmd.PersistentFlags().StringVar(&cookieJar, "cookie-jar", "", "Load cookies from this Netscape cookie file and save them back after the call")
+	rootCmd.PersistentFlags().StringArrayVar(&cookies, "cookie", nil, "Send cookies as 'name=value[; name2=value2]', repeatable")
+	rootCmd.PersistentFlags().StringVar(&origin, "origin", "", "Send this Origin and apply CORS to responses as a browser would (e.g., https://app.example.com)")
+
+	// Tracing flags (persistent so reflection calls are traced too)
+	rootCmd.PersistentFlags().StringVar(&traceExporter, "trace-exporter", "", "Record an OpenTelemetry span per call and export it: stdout or otlp")
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector URL for --trace-exporter otlp (default: from OTEL_EXPORTER_OTLP_ENDPOINT, or http://localhost:4318)")
+	rootCmd.PersistentFlags().StringVar(&traceID, "trace-id", "", "Trace calls with this 32-hex-digit trace ID, sent in traceparent, to find them in server traces")
+	rootCmd.PersistentFlags().BoolVar(&traceBin, "trace-bin", false, "Also send the trace context in the grpc-trace-bin header (OpenCensus format)")
 
 	// Output flags
 	rootCmd.Flags().IntVar(&maxMsgSize, "max-msg-sz", protocol.MaxMessageSize, "Maximum message size")
@@ -109,10 +223,12 @@ Examples:
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	rootCmd.Flags().StringVarP(&outputFormat, "format", "o", "json", "Output format: json or text")
 	rootCmd.Flags().BoolVar(&showTrailers, "show-trailers", false, "Always show response trailers")
+	rootCmd.Flags().StringArrayVar(&binTypes, "bin-type", nil, "Decode a binary (-bin) response header or trailer as a message, in 'key=package.Message' format")
 
 	// Add subcommands
 	rootCmd.AddCommand(listCmd())
 	rootCmd.AddCommand(describeCmd())
+	rootCmd.AddCommand(corsCmd())
 	rootCmd.AddCommand(versionCmd())
 	rootCmd.AddCommand(completionCmd())
 
@@ -153,22 +269,310 @@ func getDescriptorSource(ctx context.Context, address string, c *client.Client)
 
 // createClient creates a gRPC-Web client with the current options.
 func createClient(address string) (*client.Client, error) {
+	if wireMode != "binary" && wireMode != "text" {
+		return nil, fmt.Errorf("invalid mode %q: must be 'binary' or 'text'", wireMode)
+	}
+
+	retryPolicy, err := parseRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	var methodConfigs *client.ServiceConfig
+	if serviceConfig != "" {
+		if methodConfigs, err = client.LoadServiceConfig(serviceConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	credentials, err := parseCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := newTracerProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	socket := unixSocket
+	if abstractSocket != "" {
+		if socket != "" {
+			return nil, fmt.Errorf("--unix-socket and --abstract-unix-socket are mutually exclusive")
+		}
+		socket = "@" + abstractSocket
+	}
+
 	clientOpts := &client.Options{
 		Insecure:       insecure,
 		Plaintext:      plaintext,
 		CertFile:       certFile,
 		KeyFile:        keyFile,
-		CAFile:         caFile,
-		Resolve:        resolve,
+		CertPassword:   certPassword,
+		CAFiles:        caFiles,
+		ServerName:     serverName,
+		TLSMinVersion:  tlsMin,
+		TLSMaxVersion:  tlsMax,
+		CipherSuites:   splitCiphers(ciphers),
+		ALPN:           alpn,
+		KeyLogFile:     keyLogFile,
+		PinnedPubKey:   pinnedPubKey,
+		Resolves:       resolve,
+		ConnectTo:      connectTo,
+		Proxy:          proxy,
+		NoProxy:        noProxy,
+		UnixSocket:     socket,
 		Timeout:        timeout,
 		ConnectTimeout: connectTimeout,
+		ServerTimeout:  serverTimeout,
 		MaxMessageSize: maxMsgSize,
+		Protocol:       rpcProtocol,
+		Codec:          codecName,
+		Text:           textMode || wireMode == "text",
+		Compression:    compression,
+		HTTPGet:        httpGet,
+		Retry:          retryPolicy,
+		ServiceConfig:  methodConfigs,
+		Credentials:    credentials,
+		CookieJar:      cookieJar,
+		Cookies:        cookies,
+		Origin:         origin,
+		TraceBinary:    traceBin,
 		Verbose:        verbose,
 	}
+	if provider != nil {
+		clientOpts.TracerProvider = provider
+	}
 
 	return client.NewClient(address, clientOpts)
 }
 
+// closeClient closes a client, which saves the --cookie-jar file, flushes
+// the trace spans, and reports any error as a warning.
+func closeClient(c *client.Client) {
+	if err := c.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if tracerProvider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to export trace spans: %v\n", err)
+		}
+		tracerProvider = nil
+	}
+}
+
+// newTracerProvider creates the tracer provider for the tracing flags, or
+// returns nil if none are set.
+func newTracerProvider() (*sdktrace.TracerProvider, error) {
+	if otlpEndpoint != "" && traceExporter != client.TraceExporterOTLP {
+		return nil, fmt.Errorf("--otlp-endpoint requires --trace-exporter otlp")
+	}
+	if traceExporter == "" && traceID == "" && !traceBin {
+		return nil, nil
+	}
+	config := client.TracingConfig{Exporter: traceExporter, Endpoint: otlpEndpoint}
+	if traceID != "" {
+		id, err := trace.TraceIDFromHex(traceID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --trace-id %q: must be 32 hex digits, not all zero", traceID)
+		}
+		config.TraceID = id
+	}
+	provider, err := client.NewTracerProvider(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider = provider
+	return provider, nil
+}
+
+// splitCiphers splits --ciphers values on colons too, as in OpenSSL cipher
+// lists.
+func splitCiphers(values []string) []string {
+	var suites []string
+	for _, value := range values {
+		suites = append(suites, strings.Split(value, ":")...)
+	}
+	return suites
+}
+
+// parseCredentials builds the per-call credentials from the credential flags,
+// or returns nil if none are set.
+func parseCredentials() (client.PerRPCCredentials, error) {
+	sources := 0
+	for _, set := range []bool{tokenFile != "", tokenEnv != "", oauthTokenURL != "", jwtKey != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("--token-file, --token-env, --oauth-token-url and --jwt-key are mutually exclusive")
+	}
+	if jwtKey == "" && (jwtClaims != "" || jwtAudience != "") {
+		return nil, fmt.Errorf("--jwt-claims and --jwt-audience require --jwt-key")
+	}
+
+	switch {
+	case jwtKey != "":
+		config, err := client.LoadJWTConfig(jwtKey, jwtClaims)
+		if err != nil {
+			return nil, err
+		}
+		config.Audience = jwtAudience
+		return client.NewJWTCredentials(*config)
+	case tokenFile != "":
+		return client.NewTokenFileCredentials(tokenFile), nil
+	case tokenEnv != "":
+		return client.NewTokenEnvCredentials(tokenEnv), nil
+	case oauthTokenURL == "":
+		if oauthClientID != "" || oauthClientSecret != "" || oauthRefreshToken != "" {
+			return nil, fmt.Errorf("OAuth2 flags require --oauth-token-url")
+		}
+		return nil, nil
+	}
+
+	secret, err := readSecret(oauthClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --oauth-client-secret: %w", err)
+	}
+	refreshToken, err := readSecret(oauthRefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --oauth-refresh-token: %w", err)
+	}
+
+	config := client.OAuth2Config{
+		TokenURL:     oauthTokenURL,
+		ClientID:     oauthClientID,
+		ClientSecret: secret,
+		Scopes:       oauthScopes,
+		Audience:     oauthAudience,
+	}
+	if refreshToken != "" {
+		return client.NewRefreshTokenCredentials(config, refreshToken)
+	}
+	return client.NewClientCredentials(config)
+}
+
+// readSecret returns value, or the trimmed contents of the file it names if
+// it starts with @, keeping secrets out of the process list.
+func readSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	data, err := os.ReadFile(value[1:])
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// parseRetryPolicy builds the retry policy from the --retry flags, or returns
+// nil if retries are disabled.
+func parseRetryPolicy() (*client.RetryPolicy, error) {
+	if retries <= 0 {
+		return nil, nil
+	}
+	policy := client.DefaultRetryPolicy(retries + 1)
+
+	policy.RetryableCodes = nil
+	for _, name := range strings.Split(retryCodes, ",") {
+		name = strings.TrimSpace(name)
+		code, ok := protocol.StatusCode(name)
+		if !ok {
+			number, err := strconv.Atoi(name)
+			if err != nil || number < protocol.StatusOK || number > protocol.StatusUnauthenticated {
+				return nil, fmt.Errorf("invalid --retry-codes: unknown status code %q", name)
+			}
+			code = number
+		}
+		policy.RetryableCodes = append(policy.RetryableCodes, code)
+	}
+
+	parts := strings.Split(retryBackoff, ",")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid --retry-backoff %q: want initial[,max[,multiplier]]", retryBackoff)
+	}
+	var err error
+	if policy.InitialBackoff, err = time.ParseDuration(strings.TrimSpace(parts[0])); err != nil {
+		return nil, fmt.Errorf("invalid --retry-backoff initial delay: %w", err)
+	}
+	if len(parts) > 1 {
+		if policy.MaxBackoff, err = time.ParseDuration(strings.TrimSpace(parts[1])); err != nil {
+			return nil, fmt.Errorf("invalid --retry-backoff maximum delay: %w", err)
+		}
+	}
+	if len(parts) > 2 {
+		if policy.BackoffMultiplier, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err != nil || policy.BackoffMultiplier < 1 {
+			return nil, fmt.Errorf("invalid --retry-backoff multiplier %q: must be a number of at least 1", parts[2])
+		}
+	}
+	return policy, nil
+}
+
+// requestDataReader returns a reader over the request data from the -d flag or stdin.
+func requestDataReader() io.Reader {
+	if data == "@" {
+		return os.Stdin
+	}
+	return strings.NewReader(data)
+}
+
+// marshalRequestJSON parses a JSON request and serializes it with codec.
+func marshalRequestJSON(requestData []byte, inputDesc protoreflect.MessageDescriptor, codec protocol.Codec) ([]byte, error) {
+	formatter := format.NewJSONFormatter(nil)
+	reqMsg, err := formatter.UnmarshalDynamic(requestData, inputDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request JSON: %w\n\nExpected message type: %s", err, inputDesc.FullName())
+	}
+
+	reqBytes, err := codec.Marshal(reqMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %w", err)
+	}
+	return reqBytes, nil
+}
+
+// newJSONMessageSource returns a message source that reads a stream of JSON
+// objects (e.g., NDJSON) and yields each one as a serialized request message.
+func newJSONMessageSource(reader io.Reader, inputDesc protoreflect.MessageDescriptor, codec protocol.Codec) client.MessageSource {
+	decoder := json.NewDecoder(reader)
+	return func() ([]byte, error) {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("invalid request JSON: %w", err)
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Request: %s\n", raw)
+		}
+		return marshalRequestJSON(raw, inputDesc, codec)
+	}
+}
+
+// methodType describes the streaming kind of a method.
+func methodType(methodDesc protoreflect.MethodDescriptor) string {
+	switch {
+	case methodDesc.IsStreamingClient() && methodDesc.IsStreamingServer():
+		return "bidirectional streaming"
+	case methodDesc.IsStreamingClient():
+		return "client streaming"
+	case methodDesc.IsStreamingServer():
+		return "server streaming"
+	default:
+		return "unary"
+	}
+}
+
+// isIdempotent reports whether a method is declared free of side effects.
+func isIdempotent(methodDesc protoreflect.MethodDescriptor) bool {
+	opts, ok := methodDesc.Options().(*descriptorpb.MethodOptions)
+	return ok && opts.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
+
 // readRequestData reads request data from the -d flag or stdin.
 func readRequestData() (string, error) {
 	if data == "@" {
@@ -208,13 +612,7 @@ func runInvoke(cmd *cobra.Command, args []string) error {
 		return suggestMethodFormat(fullMethod, err)
 	}
 
-	// Read request data
-	requestData, err := readRequestData()
-	if err != nil {
-		return err
-	}
-
-	if requestData == "" {
+	if data == "" {
 		return fmt.Errorf("request data is required (-d flag)\n\nExample:\n  grpcwebcurl -d '{\"id\": \"123\"}' %s %s", address, fullMethod)
 	}
 
@@ -223,14 +621,11 @@ func runInvoke(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return suggestClientError(address, err)
 	}
-	defer c.Close()
+	defer closeClient(c)
 
 	// Set custom headers
-	for _, header := range headers {
-		parts := strings.SplitN(header, ":", 2)
-		if len(parts) == 2 {
-			c.SetHeader(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
-		}
+	if err := setCustomHeaders(c); err != nil {
+		return err
 	}
 
 	// Create context
@@ -246,60 +641,98 @@ func runInvoke(cmd *cobra.Command, args []string) error {
 	// Find the method descriptor
 	methodDesc, err := source.FindMethod(service, method)
 	if err != nil {
-		return suggestMethodNotFound(service, method, source, err)
-	}
-
-	// Parse request JSON
-	formatter := format.NewJSONFormatter(nil)
-	reqMsg, err := formatter.UnmarshalDynamic([]byte(requestData), methodDesc.Input())
-	if err != nil {
-		return fmt.Errorf("failed to parse request JSON: %w\n\nExpected message type: %s", err, methodDesc.Input().FullName())
-	}
-
-	// Serialize request message
-	reqBytes, err := proto.Marshal(reqMsg)
-	if err != nil {
-		return fmt.Errorf("failed to serialize request: %w", err)
-	}
-
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Calling %s/%s\n", service, method)
-		fmt.Fprintf(os.Stderr, "Request: %s\n", requestData)
-		if methodDesc.IsStreamingServer() {
-			fmt.Fprintln(os.Stderr, "Method type: server streaming")
-		} else {
-			fmt.Fprintln(os.Stderr, "Method type: unary")
+		// JSON messages can be sent without knowing their types when the
+		// server has no reflection service
+		if c.Codec().Name() == protocol.CodecJSON && len(protoFiles) == 0 {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "No descriptors available (%v); sending JSON as-is\n", err)
+			}
+			return invokeRawJSON(ctx, c, service, method)
 		}
-		fmt.Fprintln(os.Stderr)
+		return suggestMethodNotFound(service, method, source, err)
 	}
 
 	// JSON formatting options
 	jsonOpts := &format.JSONOptions{
 		EmitDefaults: emitDefaults,
 		Indent:       "  ",
+		Resolver:     descriptor.NewTypeResolver(source),
 	}
+	codec := messageCodec(c.Codec(), jsonOpts.Resolver)
 
 	var resp *client.Response
 
-	// Check if this is a server streaming method
-	if methodDesc.IsStreamingServer() {
-		// Handle server streaming
-		msgCount := 0
-		resp, err = c.InvokeServerStream(ctx, &client.Request{
-			Service: service,
-			Method:  method,
-			Message: reqBytes,
-		}, func(msgBytes []byte) error {
-			msgCount++
-			return printResponseMessage(msgBytes, methodDesc.Output(), jsonOpts, msgCount)
-		})
+	if methodDesc.IsStreamingClient() {
+		// Client and bidi streaming: messages are sent as they are read
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Calling %s/%s\n", service, method)
+			fmt.Fprintf(os.Stderr, "Method type: %s\n\n", methodType(methodDesc))
+		}
+
+		var handler client.StreamHandler
+		if methodDesc.IsStreamingServer() {
+			msgCount := 0
+			handler = func(msgBytes []byte) error {
+				msgCount++
+				return printResponseMessage(msgBytes, methodDesc.Output(), codec, jsonOpts, msgCount)
+			}
+		}
+
+		// Streamed responses are printed by the handler as they arrive
+		resp, err = c.InvokeBidiStream(ctx, &client.Request{
+			Service:         service,
+			Method:          method,
+			DiscardMessages: methodDesc.IsStreamingServer(),
+			ReuseBuffers:    methodDesc.IsStreamingServer(),
+		}, newJSONMessageSource(requestDataReader(), methodDesc.Input(), codec), handler)
 	} else {
-		// Handle unary call
-		resp, err = c.Invoke(ctx, &client.Request{
-			Service: service,
-			Method:  method,
-			Message: reqBytes,
-		})
+		// Read request data
+		var requestData string
+		requestData, err = readRequestData()
+		if err != nil {
+			return err
+		}
+
+		if requestData == "" {
+			return fmt.Errorf("request data is required (-d flag)\n\nExample:\n  grpcwebcurl -d '{\"id\": \"123\"}' %s %s", address, fullMethod)
+		}
+
+		var reqBytes []byte
+		reqBytes, err = marshalRequestJSON([]byte(requestData), methodDesc.Input(), codec)
+		if err != nil {
+			return err
+		}
+
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Calling %s/%s\n", service, method)
+			fmt.Fprintf(os.Stderr, "Request: %s\n", requestData)
+			fmt.Fprintf(os.Stderr, "Method type: %s\n\n", methodType(methodDesc))
+		}
+
+		// Check if this is a server streaming method
+		if methodDesc.IsStreamingServer() {
+			// Handle server streaming; messages are printed as they arrive
+			// and not retained, so long-running streams use constant memory
+			msgCount := 0
+			resp, err = c.InvokeServerStream(ctx, &client.Request{
+				Service:         service,
+				Method:          method,
+				Message:         reqBytes,
+				DiscardMessages: true,
+				ReuseBuffers:    true,
+			}, func(msgBytes []byte) error {
+				msgCount++
+				return printResponseMessage(msgBytes, methodDesc.Output(), codec, jsonOpts, msgCount)
+			})
+		} else {
+			// Handle unary call
+			resp, err = c.Invoke(ctx, &client.Request{
+				Service:    service,
+				Method:     method,
+				Message:    reqBytes,
+				Idempotent: isIdempotent(methodDesc),
+			})
+		}
 	}
 
 	if err != nil {
@@ -308,31 +741,94 @@ func runInvoke(cmd *cobra.Command, args []string) error {
 
 	// Check for gRPC errors
 	if resp.Status != nil && resp.Status.Code != 0 {
-		printGRPCError(resp.Status)
+		printGRPCError(resp.Status, jsonOpts)
+		closeClient(c)
 		os.Exit(1)
 	}
 
 	// For unary calls, print the response (streaming already printed via handler)
 	if !methodDesc.IsStreamingServer() {
 		for _, msgBytes := range resp.Messages {
-			if err := printResponseMessage(msgBytes, methodDesc.Output(), jsonOpts, 0); err != nil {
+			if err := printResponseMessage(msgBytes, methodDesc.Output(), codec, jsonOpts, 0); err != nil {
 				return err
 			}
 		}
 	}
 
 	// Print trailers if requested or verbose
-	if (showTrailers || verbose) && len(resp.Trailers) > 0 {
-		printTrailers(resp.Trailers)
+	if showTrailers || verbose {
+		printResponseMetadata(resp, source, jsonOpts)
+	}
+
+	return nil
+}
+
+// invokeRawJSON makes a unary call with the JSON codec when no descriptors are
+// available, sending the request data as-is and pretty-printing each response.
+func invokeRawJSON(ctx context.Context, c *client.Client, service, method string) error {
+	requestData, err := readRequestData()
+	if err != nil {
+		return err
+	}
+
+	reqBytes, err := format.CompactJSON([]byte(requestData))
+	if err != nil {
+		return fmt.Errorf("failed to parse request JSON: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Calling %s/%s\n", service, method)
+		fmt.Fprintf(os.Stderr, "Request: %s\n", reqBytes)
+		fmt.Fprintf(os.Stderr, "Method type: unknown (no descriptors)\n\n")
+	}
+
+	resp, err := c.Invoke(ctx, &client.Request{
+		Service: service,
+		Method:  method,
+		Message: reqBytes,
+	})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	jsonOpts := &format.JSONOptions{Indent: "  ", Resolver: descriptor.NewTypeResolver(nil)}
+	if resp.Status != nil && resp.Status.Code != 0 {
+		printGRPCError(resp.Status, jsonOpts)
+		closeClient(c)
+		os.Exit(1)
+	}
+
+	for index, msgBytes := range resp.Messages {
+		if outputFormat == "text" && len(resp.Messages) > 1 {
+			fmt.Printf("--- Message %d ---\n", index+1)
+		}
+		pretty, err := format.PrettyPrintJSON(msgBytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse response JSON: %w", err)
+		}
+		fmt.Println(string(pretty))
+	}
+
+	if showTrailers || verbose {
+		printResponseMetadata(resp, nil, jsonOpts)
 	}
 
 	return nil
 }
 
+// messageCodec returns the codec for request and response messages, using
+// resolver for google.protobuf.Any fields in JSON.
+func messageCodec(codec protocol.Codec, resolver format.Resolver) protocol.Codec {
+	if codec.Name() == protocol.CodecJSON {
+		return protocol.JSONCodec{Resolver: resolver}
+	}
+	return codec
+}
+
 // printResponseMessage formats and prints a single response message.
-func printResponseMessage(msgBytes []byte, outputDesc protoreflect.MessageDescriptor, jsonOpts *format.JSONOptions, msgNum int) error {
+func printResponseMessage(msgBytes []byte, outputDesc protoreflect.MessageDescriptor, codec protocol.Codec, jsonOpts *format.JSONOptions, msgNum int) error {
 	respMsg := dynamicpb.NewMessage(outputDesc)
-	if err := proto.Unmarshal(msgBytes, respMsg); err != nil {
+	if err := codec.Unmarshal(msgBytes, respMsg); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -400,18 +896,22 @@ func printFieldValue(name protoreflect.Name, fd protoreflect.FieldDescriptor, v
 }
 
 // printGRPCError prints a gRPC error with helpful formatting.
-func printGRPCError(status *protocol.Status) {
+// Rich error details are rendered as JSON, resolved through jsonOpts.Resolver.
+func printGRPCError(status *protocol.Status, jsonOpts *format.JSONOptions) {
 	fmt.Fprintf(os.Stderr, "ERROR:\n")
 	fmt.Fprintf(os.Stderr, "  Code: %s\n", protocol.StatusName(status.Code))
 	fmt.Fprintf(os.Stderr, "  Number: %d\n", status.Code)
 	if status.Message != "" {
 		fmt.Fprintf(os.Stderr, "  Message: %s\n", status.Message)
 	}
+	if len(status.Details) > 0 {
+		printStatusDetails(status, jsonOpts)
+	}
 
 	// Add helpful suggestions based on error code
 	switch status.Code {
 	case protocol.StatusUnauthenticated:
-		fmt.Fprintf(os.Stderr, "\nHint: Add authentication header with -H 'Authorization: Bearer <token>'\n")
+		fmt.Fprintf(os.Stderr, "\nHint: Add authentication header with -H 'Authorization: Bearer <token>', or use --token-file, --token-env, --oauth-token-url or --jwt-key\n")
 	case protocol.StatusPermissionDenied:
 		fmt.Fprintf(os.Stderr, "\nHint: Check if the provided credentials have access to this method\n")
 	case protocol.StatusNotFound:
@@ -425,14 +925,115 @@ func printGRPCError(status *protocol.Status) {
 	}
 }
 
-// printTrailers prints response trailers.
-func printTrailers(trailers map[string]string) {
-	fmt.Fprintln(os.Stderr, "\nTrailers:")
-	for key, value := range trailers {
-		fmt.Fprintf(os.Stderr, "  %s: %s\n", key, value)
+// printStatusDetails prints rich error details as JSON: one compact object per
+// line in text format, pretty-printed objects in JSON format.
+func printStatusDetails(status *protocol.Status, jsonOpts *format.JSONOptions) {
+	details, err := format.FormatStatusDetailsJSON(status, jsonOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Details: <failed to format: %v>\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "  Details:\n")
+	for _, detail := range details {
+		if outputFormat == "json" {
+			if pretty, err := format.PrettyPrintJSON([]byte(detail)); err == nil {
+				detail = strings.ReplaceAll(string(pretty), "\n", "\n    ")
+			}
+		}
+		fmt.Fprintf(os.Stderr, "    %s\n", detail)
 	}
 }
 
+// printResponseMetadata prints the response trailers and any binary (-bin)
+// response headers, decoding binary values for display.
+func printResponseMetadata(resp *client.Response, source descriptor.Source, jsonOpts *format.JSONOptions) {
+	binaryHeaders := protocol.NewMetadata()
+	for _, entry := range resp.Headers.Entries() {
+		if protocol.IsBinaryHeader(entry.Key) {
+			binaryHeaders.Add(entry.Key, entry.Value)
+		}
+	}
+	if binaryHeaders.Len() > 0 {
+		fmt.Fprintln(os.Stderr, "\nBinary headers:")
+		printMetadata(binaryHeaders, source, jsonOpts)
+	}
+
+	if resp.Trailers.Len() > 0 {
+		fmt.Fprintln(os.Stderr, "\nTrailers:")
+		printMetadata(resp.Trailers, source, jsonOpts)
+	}
+}
+
+// printMetadata prints metadata entries, one line per value. Binary values are shown as a hex
+// dump in text format or base64 in JSON format, or as a message when a
+// --bin-type is given for the key.
+func printMetadata(metadata *protocol.Metadata, source descriptor.Source, jsonOpts *format.JSONOptions) {
+	for _, entry := range metadata.Entries() {
+		key, value := entry.Key, entry.Value
+		if msgDesc := binaryMessageType(key, source); msgDesc != nil {
+			if decoded, err := format.FormatBinaryMessage(value, msgDesc, jsonOpts); err == nil {
+				fmt.Fprintf(os.Stderr, "  %s: %s %s\n", key, msgDesc.FullName(), decoded)
+				continue
+			}
+		}
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", key, format.FormatMetadataValue(key, value, outputFormat == "text", "    "))
+	}
+}
+
+// binaryMessageType returns the message type given with --bin-type for a
+// binary metadata key, or nil if there is none or it cannot be resolved.
+func binaryMessageType(key string, source descriptor.Source) protoreflect.MessageDescriptor {
+	if !protocol.IsBinaryHeader(key) {
+		return nil
+	}
+
+	for _, binType := range binTypes {
+		parts := strings.SplitN(binType, "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), key) {
+			continue
+		}
+		msgType, err := descriptor.NewTypeResolver(source).FindMessageByName(protoreflect.FullName(strings.TrimSpace(parts[1])))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: --bin-type %s: %v\n", binType, err)
+			return nil
+		}
+		return msgType.Descriptor()
+	}
+	return nil
+}
+
+// setCustomHeaders applies the -H flags to the client. Binary (-bin) header
+// values may be given as @file, hex:..., 0x... or base64:...; other values are
+// sent as raw bytes.
+// Repeating a key sends each value.
+func setCustomHeaders(c *client.Client) error {
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if !protocol.IsBinaryHeader(key) {
+			c.AddHeader(key, value)
+			continue
+		}
+
+		var binary []byte
+		var err error
+		if strings.HasPrefix(value, "@") {
+			binary, err = os.ReadFile(value[1:])
+		} else {
+			binary, err = protocol.ParseBinaryValue(value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for header %s: %w", key, err)
+		}
+		c.AddHeader(key, protocol.EncodeBinaryHeader(binary))
+	}
+	return nil
+}
+
 // suggestMethodFormat provides helpful error messages for method format errors.
 func suggestMethodFormat(fullMethod string, err error) error {
 	return fmt.Errorf("%w\n\nExpected format: package.Service/Method\nExamples:\n  messages.UserService/GetUser\n  helloworld.Greeter/SayHello", err)
@@ -516,14 +1117,11 @@ Examples:
 			if err != nil {
 				return fmt.Errorf("failed to create client: %w", err)
 			}
-			defer c.Close()
+			defer closeClient(c)
 
 			// Set custom headers
-			for _, header := range headers {
-				parts := strings.SplitN(header, ":", 2)
-				if len(parts) == 2 {
-					c.SetHeader(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
-				}
+			if err := setCustomHeaders(c); err != nil {
+				return err
 			}
 
 			source, err := getDescriptorSource(ctx, address, c)
@@ -574,14 +1172,11 @@ Examples:
 			if err != nil {
 				return fmt.Errorf("failed to create client: %w", err)
 			}
-			defer c.Close()
+			defer closeClient(c)
 
 			// Set custom headers
-			for _, header := range headers {
-				parts := strings.SplitN(header, ":", 2)
-				if len(parts) == 2 {
-					c.SetHeader(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
-				}
+			if err := setCustomHeaders(c); err != nil {
+				return err
 			}
 
 			source, err := getDescriptorSource(ctx, address, c)
@@ -629,6 +1224,130 @@ Examples:
 	}
 }
 
+func corsCmd() *cobra.Command {
+	var call, serverStream bool
+
+	cmd := &cobra.Command{
+		Use:   "cors <address> <method>",
+		Short: "Check the server's CORS configuration for a browser origin",
+		Long: `Check that a page at --origin could call a method from a browser.
+
+Sends the CORS preflight (OPTIONS) request a browser would send before the
+call, asking for the same headers the call sends, including -H headers and
+credentials, and checks the server allows the origin, method and headers.
+The method itself is not called.
+
+With --call, if the preflight passes, the method is really called with an
+empty request message to check the response can be read and, for gRPC-Web,
+that grpc-status and grpc-message are listed in
+Access-Control-Expose-Headers. Only use --call for methods that are safe to
+call.
+
+Each failed check is reported with a hint for fixing the server.
+
+Examples:
+  # Check the preflight of a gRPC-Web method
+  grpcwebcurl cors --origin https://app.example.com \
+    https://api.example.com:443 package.Service/Method
+
+  # Include a custom header, and call the method to check its response
+  grpcwebcurl cors --origin https://app.example.com -H "X-Tenant: acme" \
+    --call https://api.example.com:443 package.Service/Method`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			address := args[0]
+			fullMethod := args[1]
+
+			service, method, err := descriptor.ParseServiceMethod(fullMethod)
+			if err != nil {
+				return suggestMethodFormat(fullMethod, err)
+			}
+			if origin == "" {
+				return fmt.Errorf("--origin is required\n\nExample:\n  grpcwebcurl cors --origin https://app.example.com %s %s", address, fullMethod)
+			}
+
+			c, err := createClient(address)
+			if err != nil {
+				return suggestClientError(address, err)
+			}
+			defer closeClient(c)
+
+			// Set custom headers
+			if err := setCustomHeaders(c); err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			// An empty message is valid for every message type, so no
+			// descriptors are needed
+			message := []byte{}
+			if c.Codec().Name() == protocol.CodecJSON {
+				message = []byte("{}")
+			}
+
+			report, err := c.CheckCORS(ctx, &client.Request{
+				Service: service,
+				Method:  method,
+				Message: message,
+			}, client.CORSCheckOptions{Streaming: serverStream, Call: call})
+			if err != nil {
+				return err
+			}
+
+			printCORSReport(report, call)
+			if !report.Passed() {
+				return fmt.Errorf("a browser at %s cannot call %s", origin, fullMethod)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&call, "call", false, "Also call the method with an empty message, to check Access-Control-Expose-Headers on its response")
+	cmd.Flags().BoolVar(&serverStream, "server-stream", false, "The method is server streaming, which changes the headers of Connect calls")
+	return cmd
+}
+
+// printCORSReport prints the preflight request and the result of each check,
+// with hints for the failed ones.
+func printCORSReport(report *client.CORSReport, call bool) {
+	fmt.Printf("Preflight: OPTIONS %s\n", report.URL)
+	fmt.Printf("  Origin: %s\n", report.Origin)
+	fmt.Printf("  %s: %s\n", client.HeaderRequestMethod, report.Method)
+	if len(report.RequestHeaders) > 0 {
+		fmt.Printf("  %s: %s\n", client.HeaderRequestHeaders, strings.Join(report.RequestHeaders, ","))
+	}
+	if report.Credentialed {
+		fmt.Println("  (the call sends cookies, so it is credentialed)")
+	}
+	fmt.Println()
+
+	for _, check := range report.Checks {
+		result := "ok  "
+		if !check.Passed {
+			result = "FAIL"
+		}
+		if check.Detail == "" {
+			fmt.Printf("%s  %s\n", result, check.Name)
+		} else {
+			fmt.Printf("%s  %s: %s\n", result, check.Name, check.Detail)
+		}
+		if check.Hint != "" {
+			fmt.Printf("      Hint: %s\n", check.Hint)
+		}
+	}
+
+	switch {
+	case report.Called:
+	case !report.Passed():
+		fmt.Println("\nThe call was not made: a browser would stop at the preflight")
+	case !call:
+		fmt.Printf("\n%s was not checked: the method was not called (use --call)\n", client.HeaderExposeHeaders)
+	}
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// newFramedRequest creates a POST request whose body streams message as a
+// single frame. The body can be recreated, so the transport may resend it on
+// a stale keep-alive connection.
+func (client *Client) newFramedRequest(ctx context.Context, url string, message []byte, text bool) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return client.newRequestBody(singleMessage(message), text), nil
+	}
+	httpReq.Body, _ = httpReq.GetBody()
+
+	// The length is only known up front when the frame is not compressed;
+	// otherwise the body is sent chunked
+	if client.compressor == nil {
+		length := protocol.FrameHeaderSize + len(message)
+		if text {
+			length = base64.StdEncoding.EncodedLen(length)
+		}
+		httpReq.ContentLength = int64(length)
+	}
+	return httpReq, nil
+}
+
+// newRequestBody returns a request body that encodes the messages from source
+// straight into the transport through an io.Pipe, so messages are never
+// buffered as a whole request and are sent as soon as they are available.
+// Messages are base64-encoded for the grpc-web-text wire format if text is set.
+//
+// The transport closes the body when the request ends, which stops the
+// encoding goroutine even if the body was not fully read.
+func (client *Client) newRequestBody(source MessageSource, text bool) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(client.writeRequestBody(writer, source, text))
+	}()
+	return reader
+}
+
+// writeRequestBody frames each message from source and writes it to writer.
+func (client *Client) writeRequestBody(writer io.Writer, source MessageSource, text bool) error {
+	var textWriter io.WriteCloser
+	if text {
+		textWriter = base64.NewEncoder(base64.StdEncoding, writer)
+		writer = textWriter
+	}
+
+	encoder := protocol.NewEncoder(writer)
+	encoder.SetCompressor(client.compressor)
+	for {
+		message, err := source()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read request message: %w", err)
+		}
+
+		if err := encoder.Encode(message); err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+
+		if client.verbose {
+			client.logFrame(">", &protocol.Frame{
+				Type:       protocol.FrameData,
+				Payload:    message,
+				Compressed: client.compressor != nil,
+				WireSize:   encoder.WireSize(),
+			}, client.compressor)
+		}
+	}
+
+	if client.verbose {
+		fmt.Println()
+	}
+
+	// Flush the final base64 quantum and padding
+	if textWriter != nil {
+		return textWriter.Close()
+	}
+	return nil
+}
+
+// singleMessage returns a MessageSource that yields message once.
+func singleMessage(message []byte) MessageSource {
+	sent := false
+	return func() ([]byte, error) {
+		if sent {
+			return nil, io.EOF
+		}
+		sent = true
+		return message, nil
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// messageSource returns a MessageSource that yields messages in order.
+func messageSource(messages ...[]byte) MessageSource {
+	return func() ([]byte, error) {
+		if len(messages) == 0 {
+			return nil, io.EOF
+		}
+		message := messages[0]
+		messages = messages[1:]
+		return message, nil
+	}
+}
+
+func TestNewRequestBody(test *testing.T) {
+	client, err := NewClient("http://localhost:8080", &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	first, second := []byte{0x08, 0x01}, bytes.Repeat([]byte{0xAB}, 1<<20)
+	var want bytes.Buffer
+	encoder := protocol.NewEncoder(&want)
+	encoder.Encode(first)
+	encoder.Encode(second)
+
+	tests := []struct {
+		name string
+		text bool
+		want []byte
+	}{
+		{name: "binary", want: want.Bytes()},
+		{name: "text", text: true, want: protocol.EncodeText(want.Bytes())},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			body := client.newRequestBody(messageSource(first, second), tt.text)
+			defer body.Close()
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("body = %d bytes, want %d bytes", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestNewRequestBodySourceError(test *testing.T) {
+	client, err := NewClient("http://localhost:8080", &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	sourceErr := errors.New("source failed")
+	body := client.newRequestBody(func() ([]byte, error) { return nil, sourceErr }, false)
+	defer body.Close()
+
+	if _, err := io.ReadAll(body); !errors.Is(err, sourceErr) {
+		test.Errorf("ReadAll() error = %v, want %v", err, sourceErr)
+	}
+}
+
+func TestClientInvokeStreamedBody(test *testing.T) {
+	message := bytes.Repeat([]byte{0x2a}, 64*1024)
+
+	tests := []struct {
+		name              string
+		compression       string
+		wantContentLength int64
+	}{
+		{name: "uncompressed", wantContentLength: int64(protocol.FrameHeaderSize + len(message))},
+		{name: "compressed", compression: "gzip", wantContentLength: -1},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.ContentLength != tt.wantContentLength {
+					t.Errorf("ContentLength = %d, want %d", r.ContentLength, tt.wantContentLength)
+				}
+
+				decoder := protocol.NewDecoder(r.Body)
+				decoder.SetCompressor(protocol.GetCompressor(r.Header.Get(protocol.HeaderGRPCEncoding)))
+				got, err := decoder.Decode()
+				if err != nil {
+					t.Errorf("Decode() error = %v", err)
+				}
+				if !bytes.Equal(got, message) {
+					t.Errorf("received %d bytes, want %d", len(got), len(message))
+				}
+
+				w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+				w.Header().Set("Grpc-Status", "0")
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, &Options{Plaintext: true, Compression: tt.compression})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			resp, err := client.Invoke(context.Background(), &Request{
+				Service: "test.Service",
+				Method:  "Upload",
+				Message: message,
+			})
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+			if resp.Status.Code != protocol.StatusOK {
+				t.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+			}
+		})
+	}
+}
@@ -5,50 +5,139 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/hjames9/grpcwebcurl/pkg/protocol"
 )
 
+// Protocols supported by the client.
+const (
+	ProtocolGRPCWeb = "grpc-web"
+	ProtocolConnect = "connect"
+)
+
 // Client is a gRPC-Web client.
 type Client struct {
 	httpClient     *http.Client
+	transport      *http.Transport
 	baseURL        string
-	headers        map[string]string
+	headers        *protocol.Metadata
+	protocol       string
 	contentType    string
+	codec          protocol.Codec
+	text           bool
+	compressor     protocol.Compressor
+	httpGet        bool
 	timeout        time.Duration
+	serverTimeout  time.Duration
 	connectTimeout time.Duration
 	maxMsgSize     int
+	retry          *RetryPolicy
+	serviceConfig  *ServiceConfig
+	credentials    PerRPCCredentials
+	traceBinary    bool
+	unaryInvoker   UnaryInvoker
+	streamInvoker  StreamInvoker
+	cookies        *cookieJar
+	cookieFile     string
+	origin         string
+	proxy          *url.URL
+	unixSocket     string
+	keyLog         *os.File // TLS key log file, closed by Close
 	verbose        bool
+
+	// The interceptors of the invokers, including tracing, which CheckCORS
+	// also runs
+	unaryInterceptors  []UnaryInterceptor
+	streamInterceptors []StreamInterceptor
 }
 
 // Options configures the client.
 type Options struct {
 	// TLS options
-	Insecure   bool   // Skip TLS verification
-	Plaintext  bool   // Use plaintext HTTP (no TLS)
-	CertFile   string // Client certificate file
-	KeyFile    string // Client key file
-	CAFile     string // CA certificate file
-	ServerName string // Override server name for TLS
+	Insecure      bool     // Skip TLS verification
+	Plaintext     bool     // Use plaintext HTTP (no TLS)
+	CertFile      string   // Client certificate file: PEM, or PKCS#12 with CertPassword
+	KeyFile       string   // Client key file (default: the PEM certificate file)
+	CertPassword  string   // Password for a PKCS#12 client certificate
+	CAFiles       []string // CA certificate files or directories, trusted in addition to the system's
+	CAFile        string   // Deprecated: Use CAFiles; a CA certificate file added to CAFiles
+	ServerName    string   // Override server name for TLS
+	TLSMinVersion string   // Minimum TLS version: 1.0, 1.1, 1.2 or 1.3
+	TLSMaxVersion string   // Maximum TLS version: 1.0, 1.1, 1.2 or 1.3
+	CipherSuites  []string // TLS 1.2 and earlier cipher suites (e.g., TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	ALPN          []string // ALPN protocols to offer (default: h2, http/1.1)
+	KeyLogFile    string   // Append TLS session keys in NSS key log format (default: from SSLKEYLOGFILE)
+	PinnedPubKey  string   // Server public key pins: sha256//<base64>[;sha256//<base64>]..., or a PEM or DER public key file
 
 	// Connection options
-	Resolve string // Resolve host:port to address (e.g., example.com:443:127.0.0.1)
+	Resolves  []string // Resolve host:port to addresses (e.g., example.com:443:127.0.0.1,[::1] or *:443:127.0.0.1)
+	Resolve   string   // Deprecated: Use Resolves; an entry added to Resolves
+	ConnectTo []string // Connect to host2:port2 instead of host:port (e.g., example.com:443:backend.local:8443)
+	Proxy     string   // Proxy URL: http, https, socks5 or socks5h, with optional user:password (default: from HTTPS_PROXY, HTTP_PROXY or ALL_PROXY)
+	NoProxy   string   // Comma-separated hosts not to proxy (default: from NO_PROXY)
+
+	// Browser emulation
+	CookieJar string   // Netscape cookie file loaded by NewClient and saved by Close
+	Cookies   []string // Cookies to send, as name=value pairs separated by semicolons
+	Origin    string   // Send this Origin and handle CORS response headers as a browser would
+
+	// UnixSocket connects to a Unix domain socket instead of the base URL's
+	// host, which still sets the request authority. Names starting with @ are
+	// Linux abstract sockets. Base URLs of the form unix:path,
+	// unix:///absolute/path and unix-abstract:name name the socket directly.
+	UnixSocket string
 
 	// Timeouts
 	Timeout        time.Duration // Total request timeout
 	ConnectTimeout time.Duration // Connection timeout
+	ServerTimeout  time.Duration // Deadline sent to the server instead of the local one (for testing)
 
 	// Message size
 	MaxMessageSize int
 
+	// Wire format
+	Protocol    string // Protocol to speak: grpc-web (default) or connect
+	Codec       string // Message codec: proto (default) or json
+	Text        bool   // Use the grpc-web-text (base64) wire format
+	Compression string // Compress request messages (e.g., gzip, deflate, snappy)
+	HTTPGet     bool   // Use GET for unary Connect calls to idempotent methods
+
+	// Retry retries failed unary and server streaming calls; nil disables
+	// retries
+	Retry *RetryPolicy
+
+	// ServiceConfig applies per-method timeouts and retry or hedging
+	// policies. Its policies take precedence over Retry for matching methods.
+	ServiceConfig *ServiceConfig
+
+	// Credentials add authentication metadata, such as an OAuth2 access
+	// token, to every call
+	Credentials PerRPCCredentials
+
+	// Interceptors run around every unary call, and every server, client and
+	// bidi streaming call, in order with the first outermost
+	UnaryInterceptors  []UnaryInterceptor
+	StreamInterceptors []StreamInterceptor
+
+	// TracerProvider records a client span for every call, outside the
+	// interceptors, and propagates its trace context in traceparent and
+	// tracestate headers; nil disables tracing
+	TracerProvider trace.TracerProvider
+	TraceBinary    bool // Also propagate the trace context in grpc-trace-bin
+
 	// Debugging
 	Verbose bool
 }
@@ -62,6 +151,15 @@ func DefaultOptions() *Options {
 	}
 }
 
+// withDeprecated returns values with the value of a deprecated single-value
+// option appended, if it is set, without changing the caller's slice.
+func withDeprecated(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	return append(slices.Clip(values), value)
+}
+
 // NewClient creates a new gRPC-Web client.
 func NewClient(baseURL string, opts *Options) (*Client, error) {
 	if opts == nil {
@@ -77,117 +175,225 @@ func NewClient(baseURL string, opts *Options) (*Client, error) {
 		ForceAttemptHTTP2:   true,
 	}
 
-	// Configure custom dialer if --resolve is specified
-	if opts.Resolve != "" {
-		dialer, err := createCustomDialer(opts.Resolve, opts.ConnectTimeout)
+	// Configure custom dialer if --resolve or --connect-to is specified
+	resolver, err := newResolver(withDeprecated(opts.Resolves, opts.Resolve), opts.ConnectTo)
+	if err != nil {
+		return nil, err
+	}
+	resolver.verbose = opts.Verbose
+	dial := resolver.dialer(opts.ConnectTimeout)
+	if !resolver.empty() {
+		transport.DialContext = dial
+	}
+
+	// Connect to a Unix socket instead of over TCP; sockets bypass --resolve,
+	// --connect-to and proxies
+	socket, baseURL, err := unixSocketTarget(baseURL, opts.UnixSocket, opts.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Route connections through a proxy; the proxy itself is dialed with the
+	// resolver's overrides
+	var proxyURL *url.URL
+	if socket != "" {
+		transport.DialContext = unixSocketDialer(socket, opts.ConnectTimeout)
+	} else {
+		proxyURL, err = selectProxy(baseURL, opts.Proxy, opts.NoProxy)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse --resolve: %w", err)
+			return nil, err
+		}
+		if proxyURL != nil {
+			configureProxy(transport, proxyURL, resolver, dial)
 		}
-		transport.DialContext = dialer
 	}
 
-	// Configure TLS unless plaintext mode
+	// Configure TLS unless plaintext mode. The client owns the key log file;
+	// it is closed here if NewClient fails after opening it.
+	var keyLog *os.File
+	defer func() {
+		if keyLog != nil {
+			keyLog.Close()
+		}
+	}()
 	if !opts.Plaintext {
 		tlsConfig, err := configureTLS(opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to configure TLS: %w", err)
 		}
 		transport.TLSClientConfig = tlsConfig
-	}
+		keyLog, _ = tlsConfig.KeyLogWriter.(*os.File)
 
-	httpClient := &http.Client{
-		Transport: transport,
-		Timeout:   opts.Timeout,
+		// HTTP/2 would add h2 to the offered protocols
+		if len(opts.ALPN) > 0 && !slices.Contains(opts.ALPN, "h2") {
+			transport.ForceAttemptHTTP2 = false
+		}
 	}
 
-	return &Client{
-		httpClient:     httpClient,
-		baseURL:        baseURL,
-		headers:        make(map[string]string),
-		contentType:    protocol.ContentTypeGRPCWeb,
-		timeout:        opts.Timeout,
-		connectTimeout: opts.ConnectTimeout,
-		maxMsgSize:     opts.MaxMessageSize,
-		verbose:        opts.Verbose,
-	}, nil
-}
-
-// configureTLS sets up TLS configuration based on options.
-func configureTLS(opts *Options) (*tls.Config, error) {
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: opts.Insecure,
+	if opts.Credentials != nil && opts.Credentials.RequireTransportSecurity() && opts.Plaintext && socket == "" {
+		return nil, fmt.Errorf("credentials require transport security: connect with TLS")
 	}
 
-	if opts.ServerName != "" {
-		tlsConfig.ServerName = opts.ServerName
+	// Token requests of credentials go to an identity provider rather than the
+	// server, so they share only the proxy and the trusted CAs, client
+	// certificate and --insecure of calls; --resolve, --connect-to, the Unix
+	// socket, the TLS server name and the pinned key apply to the server alone
+	if tokenCredentials, ok := opts.Credentials.(httpCredentials); ok {
+		tokenTransport := &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: opts.ConnectTimeout}).DialContext,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			ForceAttemptHTTP2:   true,
+		}
+		if proxyURL != nil {
+			direct, _ := newResolver(nil, nil)
+			configureProxy(tokenTransport, proxyURL, direct, tokenTransport.DialContext)
+		}
+		if tlsConfig := transport.TLSClientConfig; tlsConfig != nil {
+			tokenTransport.TLSClientConfig = &tls.Config{
+				RootCAs:            tlsConfig.RootCAs,
+				Certificates:       tlsConfig.Certificates,
+				InsecureSkipVerify: opts.Insecure,
+			}
+		}
+		tokenCredentials.setHTTPClient(&http.Client{Transport: tokenTransport, Timeout: opts.Timeout})
 	}
 
-	// Load client certificate if provided
-	if opts.CertFile != "" && opts.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	// Keep cookies across calls, including reflection, as a browser would
+	cookies := newCookieJar()
+	if opts.CookieJar != "" {
+		if err := cookies.load(opts.CookieJar); err != nil {
+			return nil, err
 		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if err := cookies.addCookies(baseURL, opts.Cookies); err != nil {
+		return nil, err
 	}
 
-	// Load CA certificate if provided
-	if opts.CAFile != "" {
-		caCert, err := os.ReadFile(opts.CAFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	var roundTripper http.RoundTripper = transport
+	if opts.Origin != "" {
+		if err := checkOrigin(opts.Origin); err != nil {
+			return nil, err
 		}
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, fmt.Errorf("failed to parse CA certificate")
-		}
-		tlsConfig.RootCAs = caCertPool
+		roundTripper = &corsTransport{base: transport, origin: opts.Origin, verbose: opts.Verbose}
 	}
 
-	return tlsConfig, nil
-}
+	httpClient := &http.Client{
+		Transport: roundTripper,
+		Jar:       cookies,
+		Timeout:   opts.Timeout,
+	}
 
-// createCustomDialer creates a custom dialer that overrides DNS resolution for a specific host:port.
-// The resolve format is: host:port:address (e.g., example.com:443:127.0.0.1)
-func createCustomDialer(resolve string, timeout time.Duration) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
-	// Parse the resolve string: host:port:address
-	parts := strings.Split(resolve, ":")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid --resolve format: expected host:port:address, got %q", resolve)
+	maxMsgSize := opts.MaxMessageSize
+	if maxMsgSize <= 0 {
+		maxMsgSize = protocol.MaxMessageSize
 	}
 
-	targetHost := parts[0]
-	targetPort := parts[1]
-	resolveAddr := parts[2]
-	targetHostPort := net.JoinHostPort(targetHost, targetPort)
+	rpcProtocol := opts.Protocol
+	if rpcProtocol == "" {
+		rpcProtocol = ProtocolGRPCWeb
+	}
+	if rpcProtocol != ProtocolGRPCWeb && rpcProtocol != ProtocolConnect {
+		return nil, fmt.Errorf("unsupported protocol %q: must be %q or %q", opts.Protocol, ProtocolGRPCWeb, ProtocolConnect)
+	}
+
+	codec, err := protocol.GetCodec(opts.Codec)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create a custom dialer
-	dialer := &net.Dialer{
-		Timeout: timeout,
+	if opts.Text && rpcProtocol == ProtocolConnect {
+		return nil, fmt.Errorf("text mode is not supported with the %s protocol", ProtocolConnect)
 	}
+	contentType := protocol.GRPCWebContentType(codec.Name(), opts.Text)
 
-	return func(ctx context.Context, network, addr string) (net.Conn, error) {
-		// If the address matches our target host:port, use the resolved address
-		if addr == targetHostPort {
-			// Replace with the resolved address but keep the port
-			addr = net.JoinHostPort(resolveAddr, targetPort)
+	var compressor protocol.Compressor
+	if opts.Compression != "" && opts.Compression != protocol.CompressionIdentity {
+		compressor = protocol.GetCompressor(opts.Compression)
+		if compressor == nil {
+			return nil, fmt.Errorf("unsupported compression %q (available: %s)",
+				opts.Compression, strings.Join(protocol.RegisteredCompressors(), ", "))
 		}
-		return dialer.DialContext(ctx, network, addr)
-	}, nil
+	}
+
+	client := &Client{
+		httpClient:     httpClient,
+		transport:      transport,
+		baseURL:        baseURL,
+		headers:        protocol.NewMetadata(),
+		protocol:       rpcProtocol,
+		contentType:    contentType,
+		codec:          codec,
+		text:           opts.Text,
+		compressor:     compressor,
+		httpGet:        opts.HTTPGet,
+		timeout:        opts.Timeout,
+		serverTimeout:  opts.ServerTimeout,
+		connectTimeout: opts.ConnectTimeout,
+		maxMsgSize:     maxMsgSize,
+		retry:          opts.Retry,
+		serviceConfig:  opts.ServiceConfig,
+		credentials:    opts.Credentials,
+		traceBinary:    opts.TraceBinary,
+		cookies:        cookies,
+		cookieFile:     opts.CookieJar,
+		origin:         opts.Origin,
+		proxy:          proxyURL,
+		unixSocket:     socket,
+		keyLog:         keyLog,
+		verbose:        opts.Verbose,
+	}
+	keyLog = nil
+	unaryInterceptors, streamInterceptors := opts.UnaryInterceptors, opts.StreamInterceptors
+	if opts.TracerProvider != nil {
+		tracer := opts.TracerProvider.Tracer(tracerName, trace.WithInstrumentationVersion(protocol.Version))
+		unaryInterceptors = append([]UnaryInterceptor{client.tracingUnaryInterceptor(tracer)}, unaryInterceptors...)
+		streamInterceptors = append([]StreamInterceptor{client.tracingStreamInterceptor(tracer)}, streamInterceptors...)
+	}
+	client.unaryInterceptors, client.streamInterceptors = unaryInterceptors, streamInterceptors
+	client.unaryInvoker = chainUnaryInterceptors(unaryInterceptors, client.invokeUnary)
+	client.streamInvoker = chainStreamInterceptors(streamInterceptors, client.invokeStream)
+	return client, nil
 }
 
-// SetHeader sets a custom header for all requests.
+// SetHeader sets a custom header for all requests, replacing any existing values.
 func (client *Client) SetHeader(key, value string) {
-	client.headers[key] = value
+	client.headers.Set(key, value)
+}
+
+// AddHeader adds a value to a custom header for all requests, keeping any
+// existing values.
+func (client *Client) AddHeader(key, value string) {
+	client.headers.Add(key, value)
+}
+
+// Headers returns the custom headers sent with all requests.
+func (client *Client) Headers() *protocol.Metadata {
+	return client.headers.Clone()
+}
+
+// SetBinaryHeader sets a binary metadata header for all requests. The key
+// must end in -bin; the value is base64 encoded as gRPC requires.
+func (client *Client) SetBinaryHeader(key string, value []byte) error {
+	if !protocol.IsBinaryHeader(key) {
+		return fmt.Errorf("binary header %q must end in %s", key, protocol.BinaryHeaderSuffix)
+	}
+	client.headers.Set(key, protocol.EncodeBinaryHeader(value))
+	return nil
 }
 
 // SetHeaders sets multiple custom headers.
 func (client *Client) SetHeaders(headers map[string]string) {
 	for key, value := range headers {
-		client.headers[key] = value
+		client.headers.Set(key, value)
 	}
 }
 
+// Codec returns the codec used to serialize messages.
+func (client *Client) Codec() protocol.Codec {
+	return client.codec
+}
+
 // SetContentType sets the content type for requests.
 func (client *Client) SetContentType(contentType string) {
 	client.contentType = contentType
@@ -198,64 +404,61 @@ type Request struct {
 	Service string
 	Method  string
 	Message []byte
-	Headers map[string]string
+	Headers *protocol.Metadata
+
+	// Codec overrides the client's codec for this call; the message must
+	// already be serialized with it.
+	Codec protocol.Codec
+
+	// Idempotent marks a method without side effects; with the Connect
+	// protocol such unary calls may be sent as GET requests.
+	Idempotent bool
+
+	// DiscardMessages passes streamed messages to the handler only and leaves
+	// Response.Messages empty, so long-lived streams run in constant memory.
+	DiscardMessages bool
+
+	// ReuseBuffers decodes every streamed message into the same buffer. The
+	// slice passed to the handler is then only valid until the handler
+	// returns and must be copied to be kept.
+	ReuseBuffers bool
 }
 
 // Response represents a gRPC-Web response.
 type Response struct {
 	Messages    [][]byte
-	Trailers    map[string]string
+	Trailers    *protocol.Metadata
+	Headers     *protocol.Metadata // Response headers; see also HTTPHeaders
 	Status      *protocol.Status
 	HTTPStatus  int
 	HTTPHeaders http.Header
 }
 
-// Invoke makes a unary gRPC-Web call.
+// Invoke makes a unary gRPC-Web call, or a unary Connect call when the client
+// uses the Connect protocol. The call runs through the client's unary
+// interceptors, and failed calls are retried under the client's retry policy.
 func (client *Client) Invoke(ctx context.Context, req *Request) (*Response, error) {
-	// Build URL: baseURL/package.Service/Method
-	url := fmt.Sprintf("%s/%s/%s", client.baseURL, req.Service, req.Method)
+	return client.unaryInvoker(ctx, req)
+}
 
-	// Encode message
-	body, err := protocol.EncodeMessage(req.Message)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode message: %w", err)
-	}
+// invokeUnary is the UnaryInvoker at the end of the interceptor chain.
+func (client *Client) invokeUnary(ctx context.Context, req *Request) (*Response, error) {
+	return client.call(ctx, req, func(ctx context.Context, commit func() bool) (*Response, error) {
+		return client.attempt(ctx, req, false, nil)
+	})
+}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+// attempt makes a single attempt of a unary or server streaming call in
+// either protocol: it builds the request, sends it, and reads the response,
+// logging each step in verbose mode.
+func (client *Client) attempt(ctx context.Context, req *Request, streaming bool, handler StreamHandler) (*Response, error) {
+	httpReq, frame, err := client.newRequest(ctx, req, streaming)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set standard gRPC-Web headers
-	protocol.SetRequestHeaders(httpReq, client.contentType)
-
-	// Set custom headers from client
-	for key, value := range client.headers {
-		// Special handling for Host header - must set req.Host field
-		if strings.EqualFold(key, "Host") {
-			httpReq.Host = value
-		} else {
-			httpReq.Header.Set(key, value)
-		}
-	}
-
-	// Set custom headers from request
-	for key, value := range req.Headers {
-		// Special handling for Host header - must set req.Host field
-		if strings.EqualFold(key, "Host") {
-			httpReq.Host = value
-		} else {
-			httpReq.Header.Set(key, value)
-		}
+		return nil, err
 	}
 
 	if client.verbose {
-		fmt.Printf("> %s %s\n", httpReq.Method, httpReq.URL)
-		for key, values := range httpReq.Header {
-			fmt.Printf("> %s: %s\n", key, values)
-		}
-		fmt.Println()
+		client.logRequest(httpReq, frame)
 	}
 
 	// Make request
@@ -266,205 +469,408 @@ func (client *Client) Invoke(ctx context.Context, req *Request) (*Response, erro
 	defer httpResp.Body.Close()
 
 	if client.verbose {
-		fmt.Printf("< %s\n", httpResp.Status)
-		for key, values := range httpResp.Header {
-			fmt.Printf("< %s: %s\n", key, values)
-		}
-		fmt.Println()
+		client.logResponse(httpResp)
 	}
 
-	// Read response body
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check for HTTP errors
-	if httpResp.StatusCode != http.StatusOK {
-		// Try to extract gRPC status from headers
-		code, msg := protocol.GetGRPCStatus(httpResp)
-		if code != 0 || msg != "" {
-			return &Response{
-				HTTPStatus:  httpResp.StatusCode,
-				HTTPHeaders: httpResp.Header,
-				Status: &protocol.Status{
-					Code:    code,
-					Message: msg,
-				},
-			}, nil
-		}
-		return nil, fmt.Errorf("HTTP error: %s", httpResp.Status)
+	var resp *Response
+	switch {
+	case client.protocol == ProtocolConnect && streaming:
+		resp, err = client.readConnectStream(httpResp, req, handler)
+	case client.protocol == ProtocolConnect:
+		resp, err = client.readConnectResponse(httpResp)
+	default:
+		resp, err = client.readGRPCWebResponse(httpResp, req, handler)
 	}
-
-	// Decode gRPC-Web response
-	decoded, err := protocol.DecodeResponse(respBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, err
 	}
 
-	return &Response{
-		Messages:    decoded.Messages,
-		Trailers:    decoded.Trailers,
-		Status:      decoded.Status,
-		HTTPStatus:  httpResp.StatusCode,
-		HTTPHeaders: httpResp.Header,
-	}, nil
+	resp.HTTPStatus = httpResp.StatusCode
+	resp.Headers = protocol.MetadataFromHeader(httpResp.Header)
+	resp.HTTPHeaders = httpResp.Header
+	return resp, nil
 }
 
-// Close closes the client and releases resources.
-func (client *Client) Close() error {
-	client.httpClient.CloseIdleConnections()
-	return nil
+// newRequest creates the HTTP request of a unary or server streaming call.
+// The request frame is returned for verbose logging when the message is sent
+// unframed, as in unary Connect calls.
+func (client *Client) newRequest(ctx context.Context, req *Request, streaming bool) (*http.Request, *protocol.Frame, error) {
+	switch {
+	case client.protocol == ProtocolConnect && streaming:
+		httpReq, err := client.newConnectStreamRequest(ctx, req)
+		return httpReq, nil, err
+	case client.protocol == ProtocolConnect:
+		httpReq, body, err := client.newConnectRequest(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		return httpReq, &protocol.Frame{
+			Type:       protocol.FrameData,
+			Payload:    req.Message,
+			Compressed: client.compressor != nil,
+			WireSize:   len(body),
+		}, nil
+	default:
+		httpReq, err := client.newGRPCWebRequest(ctx, req)
+		return httpReq, nil, err
+	}
 }
 
-// StreamHandler is called for each message received in a server streaming call.
-type StreamHandler func(message []byte) error
+// readGRPCWebResponse reads a gRPC-Web response, passing each message to
+// handler if it is non-nil.
+func (client *Client) readGRPCWebResponse(httpResp *http.Response, req *Request, handler StreamHandler) (*Response, error) {
+	// Trailers-only and non-200 responses carry the status in the headers
+	if resp := client.headerStatusResponse(httpResp); resp != nil {
+		return resp, nil
+	}
 
-// InvokeServerStream makes a server streaming gRPC-Web call.
-// The handler is called for each message received from the server.
-func (client *Client) InvokeServerStream(ctx context.Context, req *Request, handler StreamHandler) (*Response, error) {
-	// Build URL: baseURL/package.Service/Method
-	url := fmt.Sprintf("%s/%s/%s", client.baseURL, req.Service, req.Method)
+	decoder := client.newResponseDecoder(httpResp)
+	decoder.SetReuseBuffers(req.ReuseBuffers)
+	defer decoder.Release()
 
-	// Encode message
-	body, err := protocol.EncodeMessage(req.Message)
+	resp, err := client.readFrames(decoder, req, handler, func(frame *protocol.Frame) (*protocol.Metadata, *protocol.Status, error) {
+		if frame.Type&protocol.FrameTrailer == 0 {
+			return nil, nil, nil
+		}
+		trailers, status := protocol.ParseTrailers(frame.Payload)
+		return trailers, status, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode message: %w", err)
+		return nil, err
+	}
+
+	// A gRPC-Web response must end with a trailer frame
+	if resp.Status == nil {
+		resp.Status = client.missingTrailersStatus()
+	}
+	return resp, nil
+}
+
+// readFrames decodes the frames of a response until the body ends. Each data
+// frame is passed to handler, if non-nil, and retained unless the request
+// discards messages; other frames are parsed by trailer, which returns nil
+// for frames that carry no trailers.
+func (client *Client) readFrames(decoder *protocol.Decoder, req *Request, handler StreamHandler, trailer func(*protocol.Frame) (*protocol.Metadata, *protocol.Status, error)) (*Response, error) {
+	resp := &Response{Trailers: protocol.NewMetadata()}
+	for {
+		frame, err := decoder.DecodeFrame()
+		if err == io.EOF {
+			return resp, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame: %w", err)
+		}
+		if client.verbose {
+			client.logFrame("<", frame, decoder.Compressor())
+		}
+
+		if frame.Type == protocol.FrameData {
+			if handler != nil {
+				if err := handler(frame.Payload); err != nil {
+					return nil, fmt.Errorf("handler error: %w", err)
+				}
+			}
+			resp.Messages = retainMessage(resp.Messages, frame.Payload, req)
+			continue
+		}
+
+		trailers, status, err := trailer(frame)
+		if err != nil {
+			return nil, err
+		}
+		resp.Trailers.Append(trailers)
+		if status != nil {
+			resp.Status = status
+		}
 	}
+}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+// newGRPCWebRequest creates the HTTP request of a unary or server streaming
+// gRPC-Web call to baseURL/package.Service/Method. The message is encoded as
+// the body is sent.
+func (client *Client) newGRPCWebRequest(ctx context.Context, req *Request) (*http.Request, error) {
+	url := fmt.Sprintf("%s/%s/%s", client.baseURL, req.Service, req.Method)
+	httpReq, err := client.newFramedRequest(ctx, url, req.Message, protocol.IsTextContentType(client.contentType))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	client.setGRPCWebHeaders(httpReq, client.requestContentType(req), req)
+	return httpReq, nil
+}
 
-	// Set standard gRPC-Web headers
-	protocol.SetRequestHeaders(httpReq, client.contentType)
+// setGRPCWebHeaders sets the standard gRPC-Web headers, the deadline, and the
+// custom headers of a call.
+func (client *Client) setGRPCWebHeaders(httpReq *http.Request, contentType string, req *Request) {
+	protocol.SetRequestHeaders(httpReq, contentType)
+	protocol.SetCompressionHeaders(httpReq, client.compressor)
+	if timeout, ok := client.requestTimeout(httpReq.Context()); ok {
+		protocol.SetTimeout(httpReq, protocol.EncodeTimeout(timeout))
+	}
+	client.applyHeaders(httpReq, req)
+}
 
-	// Set custom headers from client
-	for key, value := range client.headers {
-		// Special handling for Host header - must set req.Host field
-		if strings.EqualFold(key, "Host") {
-			httpReq.Host = value
-		} else {
-			httpReq.Header.Set(key, value)
+// headerStatusResponse returns the response for a call whose status is
+// carried in the HTTP response headers, or nil if the status follows in a
+// trailer frame. A 200 response with a grpc-status header is a trailers-only
+// response; a non-200 response without a usable grpc-status is mapped from
+// its HTTP status code.
+func (client *Client) headerStatusResponse(httpResp *http.Response) *Response {
+	status := protocol.StatusFromHeaders(httpResp.Header)
+	hasStatus := httpResp.Header.Get(protocol.HeaderGRPCStatus) != ""
+
+	switch {
+	case httpResp.StatusCode == http.StatusOK && !hasStatus:
+		return nil
+	case httpResp.StatusCode == http.StatusOK:
+		if client.verbose {
+			fmt.Printf("< [trailers-only response] %s in headers\n", protocol.StatusName(status.Code))
+		}
+	case hasStatus && status.Code != protocol.StatusOK:
+		if client.verbose {
+			fmt.Printf("< [HTTP %d] %s in headers\n", httpResp.StatusCode, protocol.StatusName(status.Code))
+		}
+	default:
+		status = protocol.StatusFromHTTP(httpResp.StatusCode)
+		if client.verbose {
+			fmt.Printf("< [HTTP %d without grpc-status] mapped to %s\n", httpResp.StatusCode, protocol.StatusName(status.Code))
 		}
 	}
 
-	// Set custom headers from request
-	for key, value := range req.Headers {
-		// Special handling for Host header - must set req.Host field
-		if strings.EqualFold(key, "Host") {
-			httpReq.Host = value
-		} else {
-			httpReq.Header.Set(key, value)
-		}
+	metadata := protocol.MetadataFromHeader(httpResp.Header)
+	resp := &Response{
+		Status:      status,
+		HTTPStatus:  httpResp.StatusCode,
+		Headers:     metadata,
+		HTTPHeaders: httpResp.Header,
+	}
+	if hasStatus {
+		resp.Trailers = metadata
 	}
+	return resp
+}
 
+// missingTrailersStatus returns the status for a response body that ended
+// without a trailer frame.
+func (client *Client) missingTrailersStatus() *protocol.Status {
 	if client.verbose {
-		fmt.Printf("> %s %s\n", httpReq.Method, httpReq.URL)
-		for key, values := range httpReq.Header {
-			fmt.Printf("> %s: %s\n", key, values)
-		}
-		fmt.Println()
+		fmt.Println("< [missing trailers] response ended without a trailer frame")
 	}
+	return &protocol.Status{
+		Code:    protocol.StatusInternal,
+		Message: "protocol error: missing trailers",
+	}
+}
 
-	// Make request
-	httpResp, err := client.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+// retainMessage appends a streamed message to messages unless the request
+// discards them, copying it if the decoder reuses its buffers.
+func retainMessage(messages [][]byte, message []byte, req *Request) [][]byte {
+	if req.DiscardMessages {
+		return messages
 	}
-	defer httpResp.Body.Close()
+	if req.ReuseBuffers {
+		message = bytes.Clone(message)
+	}
+	return append(messages, message)
+}
 
-	if client.verbose {
-		fmt.Printf("< %s\n", httpResp.Status)
-		for key, values := range httpResp.Header {
-			fmt.Printf("< %s: %s\n", key, values)
-		}
-		fmt.Println()
-	}
-
-	// Check for HTTP errors
-	if httpResp.StatusCode != http.StatusOK {
-		// Try to extract gRPC status from headers
-		code, msg := protocol.GetGRPCStatus(httpResp)
-		if code != 0 || msg != "" {
-			return &Response{
-				HTTPStatus:  httpResp.StatusCode,
-				HTTPHeaders: httpResp.Header,
-				Status: &protocol.Status{
-					Code:    code,
-					Message: msg,
-				},
-			}, nil
-		}
-		return nil, fmt.Errorf("HTTP error: %s", httpResp.Status)
+// requestTimeout returns the deadline to propagate to the server: the
+// configured server timeout if set, otherwise the time remaining before the
+// context deadline or, without one, the client timeout.
+func (client *Client) requestTimeout(ctx context.Context) (time.Duration, bool) {
+	if client.serverTimeout > 0 {
+		return client.serverTimeout, true
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline), true
+	}
+	if client.timeout > 0 {
+		return client.timeout, true
+	}
+	return 0, false
+}
+
+// requestContentType returns the gRPC-Web content type for a call, honoring a
+// per-request codec override.
+func (client *Client) requestContentType(req *Request) string {
+	if req.Codec != nil && req.Codec.Name() != client.codec.Name() {
+		return protocol.GRPCWebContentType(req.Codec.Name(), client.text)
 	}
+	return client.contentType
+}
+
+// requestCodec returns the codec a call's message is serialized with.
+func (client *Client) requestCodec(req *Request) protocol.Codec {
+	if req.Codec != nil {
+		return req.Codec
+	}
+	return client.codec
+}
 
-	// Read and process streaming response
-	decoder := protocol.NewDecoder(httpResp.Body)
+// newResponseDecoder creates a frame decoder for a response body, honoring the
+// wire format, the negotiated Grpc-Encoding, and the maximum message size.
+func (client *Client) newResponseDecoder(httpResp *http.Response) *protocol.Decoder {
+	decoder := protocol.NewDecoder(client.responseReader(httpResp))
 	decoder.SetMaxMessageSize(client.maxMsgSize)
+	decoder.SetCompressor(protocol.GetCompressor(httpResp.Header.Get(protocol.HeaderGRPCEncoding)))
+	return decoder
+}
 
-	var messages [][]byte
-	trailers := make(map[string]string)
-	var status *protocol.Status
+// applyHeaders sets the custom headers from the client and the request, and
+// the credentials metadata in the request's context. Request headers replace
+// client headers with the same key, credentials replace both, and custom
+// headers replace the standard ones.
+func (client *Client) applyHeaders(httpReq *http.Request, req *Request) {
+	headers := client.headers.Clone()
+	for _, key := range req.Headers.Keys() {
+		headers.Del(key)
+	}
+	headers.Append(req.Headers)
+	if credentials, ok := httpReq.Context().Value(credentialsKey{}).(map[string]string); ok {
+		for key, value := range credentials {
+			headers.Set(key, value)
+		}
+	}
 
-	for {
-		frame, err := decoder.DecodeFrame()
-		if err == io.EOF {
-			break
+	for _, key := range headers.Keys() {
+		// Special handling for Host header - must set req.Host field
+		if key == "host" {
+			httpReq.Host = headers.Get(key)
+			continue
 		}
+		httpReq.Header.Del(key)
+		for _, value := range headers.Values(key) {
+			httpReq.Header.Add(key, value)
+		}
+	}
+}
+
+// logRequest prints the request line, headers, and request frame in verbose
+// mode. A nil frame is for streamed bodies, whose frames are logged as sent.
+func (client *Client) logRequest(httpReq *http.Request, frame *protocol.Frame) {
+	if client.proxy != nil {
+		fmt.Printf("* [proxy] via %s\n", client.proxy.Redacted())
+	}
+	if client.unixSocket != "" {
+		fmt.Printf("* [unix socket] via %s\n", client.unixSocket)
+	}
+	fmt.Printf("> %s %s\n", httpReq.Method, httpReq.URL)
+	logHeader(">", httpReq.Header)
+	if frame != nil {
+		client.logFrame(">", frame, client.compressor)
+	}
+	fmt.Println()
+}
+
+// logResponse prints the response status line and headers in verbose mode.
+func (client *Client) logResponse(httpResp *http.Response) {
+	fmt.Printf("< %s\n", httpResp.Status)
+	logHeader("<", httpResp.Header)
+	fmt.Println()
+}
+
+// logHeader prints HTTP headers in verbose mode, sorted by name, since
+// http.Header does not keep the order they were set in.
+func logHeader(prefix string, header http.Header) {
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		values := header[key]
+		fmt.Printf("%s %s: %s%s\n", prefix, key, values, describeBinary(key, values))
+	}
+}
+
+// describeBinary returns the decoded bytes of binary (-bin) header values for
+// verbose output, or an empty string for other headers.
+func describeBinary(key string, values []string) string {
+	if !protocol.IsBinaryHeader(key) {
+		return ""
+	}
+
+	var decoded []string
+	for _, value := range values {
+		data, err := protocol.DecodeBinaryHeader(value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode frame: %w", err)
+			return " (invalid base64)"
 		}
+		decoded = append(decoded, fmt.Sprintf("%d bytes: %x", len(data), data))
+	}
+	return " (" + strings.Join(decoded, "; ") + ")"
+}
 
-		switch frame.Type {
-		case protocol.FrameData:
-			// Call handler for each message
-			if handler != nil {
-				if err := handler(frame.Payload); err != nil {
-					return nil, fmt.Errorf("handler error: %w", err)
-				}
-			}
-			messages = append(messages, frame.Payload)
-
-		case protocol.FrameTrailer:
-			// Parse trailers
-			trailerStr := string(frame.Payload)
-			for _, line := range strings.Split(trailerStr, "\r\n") {
-				if line == "" {
-					continue
-				}
-				parts := strings.SplitN(line, ":", 2)
-				if len(parts) == 2 {
-					key := strings.TrimSpace(strings.ToLower(parts[0]))
-					value := strings.TrimSpace(parts[1])
-					trailers[key] = value
-				}
-			}
+// logFrame prints the size of a frame in verbose mode, including the
+// compressed size when the frame was compressed on the wire.
+func (client *Client) logFrame(prefix string, frame *protocol.Frame, compressor protocol.Compressor) {
+	kind := "data"
+	switch {
+	case frame.Type&protocol.FrameTrailer != 0:
+		kind = "trailer"
+	case client.protocol == ProtocolConnect && frame.Type == protocol.FrameConnectEndStream:
+		kind = "end-stream"
+	}
 
-			// Extract status from trailers
-			if statusStr, ok := trailers["grpc-status"]; ok {
-				code := 0
-				fmt.Sscanf(statusStr, "%d", &code)
-				status = &protocol.Status{
-					Code:    code,
-					Message: trailers["grpc-message"],
-				}
-			}
+	if frame.Compressed && compressor != nil {
+		fmt.Printf("%s [%s frame] %d bytes (%s compressed: %d bytes)\n",
+			prefix, kind, len(frame.Payload), compressor.Name(), frame.WireSize)
+		return
+	}
+	fmt.Printf("%s [%s frame] %d bytes (uncompressed)\n", prefix, kind, len(frame.Payload))
+}
+
+// responseReader returns a reader over the binary frames of a response body.
+// grpc-web-text responses are decoded incrementally so streamed chunks can be
+// consumed as they arrive.
+func (client *Client) responseReader(httpResp *http.Response) io.Reader {
+	contentType := httpResp.Header.Get(protocol.HeaderContentType)
+	if protocol.IsTextContentType(contentType) || (contentType == "" && protocol.IsTextContentType(client.contentType)) {
+		return protocol.NewTextReader(httpResp.Body)
+	}
+	return httpResp.Body
+}
+
+// Close closes the client and releases resources.
+func (client *Client) Close() error {
+	client.httpClient.CloseIdleConnections()
+	var errs []error
+	if client.cookieFile != "" {
+		errs = append(errs, client.cookies.save(client.cookieFile))
+	}
+	if client.keyLog != nil {
+		if err := client.keyLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close key log file: %w", err))
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// StreamHandler is called for each message received in a server streaming call.
+type StreamHandler func(message []byte) error
+
+// InvokeServerStream makes a server streaming gRPC-Web or Connect call through
+// the client's stream interceptors. The handler is called for each message
+// received from the server. A failed call is retried or hedged only until the
+// first message reaches the handler.
+func (client *Client) InvokeServerStream(ctx context.Context, req *Request, handler StreamHandler) (*Response, error) {
+	return client.streamInvoker(ctx, req, nil, handler)
+}
 
-	// Default to OK status if not set
-	if status == nil {
-		status = &protocol.Status{Code: 0}
+// invokeStream is the StreamInvoker at the end of the interceptor chain.
+func (client *Client) invokeStream(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error) {
+	if source != nil {
+		return client.invokeBidiStream(ctx, req, source, handler)
 	}
+	return client.invokeServerStream(ctx, req, handler)
+}
 
-	return &Response{
-		Messages:    messages,
-		Trailers:    trailers,
-		Status:      status,
-		HTTPStatus:  httpResp.StatusCode,
-		HTTPHeaders: httpResp.Header,
-	}, nil
+// invokeServerStream makes a server streaming call under the client's retry
+// and hedging policies.
+func (client *Client) invokeServerStream(ctx context.Context, req *Request, handler StreamHandler) (*Response, error) {
+	return client.call(ctx, req, func(ctx context.Context, commit func() bool) (*Response, error) {
+		return client.attempt(ctx, req, true, func(message []byte) error {
+			if !commit() {
+				return errAttemptAbandoned
+			}
+			if handler == nil {
+				return nil
+			}
+			return handler(message)
+		})
+	})
 }
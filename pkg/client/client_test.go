@@ -2,12 +2,20 @@ package client
 
 import (
 	"context"
+	"encoding/base64"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func TestDefaultOptions(test *testing.T) {
@@ -98,11 +106,29 @@ func TestClientSetHeader(test *testing.T) {
 	client.SetHeader("Authorization", "Bearer token123")
 	client.SetHeader("X-Custom-Header", "custom-value")
 
-	if client.headers["Authorization"] != "Bearer token123" {
-		test.Errorf("Authorization header = %q, want %q", client.headers["Authorization"], "Bearer token123")
+	if client.headers.Get("Authorization") != "Bearer token123" {
+		test.Errorf("Authorization header = %q, want %q", client.headers.Get("Authorization"), "Bearer token123")
 	}
-	if client.headers["X-Custom-Header"] != "custom-value" {
-		test.Errorf("X-Custom-Header = %q, want %q", client.headers["X-Custom-Header"], "custom-value")
+	if client.headers.Get("X-Custom-Header") != "custom-value" {
+		test.Errorf("X-Custom-Header = %q, want %q", client.headers.Get("X-Custom-Header"), "custom-value")
+	}
+}
+
+func TestClientSetBinaryHeader(test *testing.T) {
+	client, err := NewClient("http://localhost:8080", &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	if err := client.SetBinaryHeader("Trace-Bin", []byte{0x00, 0xff, 0x10}); err != nil {
+		test.Fatalf("SetBinaryHeader() error = %v", err)
+	}
+	if client.headers.Get("Trace-Bin") != "AP8Q" {
+		test.Errorf("Trace-Bin header = %q, want unpadded base64 %q", client.headers.Get("Trace-Bin"), "AP8Q")
+	}
+
+	if err := client.SetBinaryHeader("X-Trace", []byte{0x01}); err == nil {
+		test.Error("SetBinaryHeader() expected error for key without -bin suffix")
 	}
 }
 
@@ -121,7 +147,7 @@ func TestClientSetHeaders(test *testing.T) {
 	client.SetHeaders(headers)
 
 	for k, want := range headers {
-		if got := client.headers[k]; got != want {
+		if got := client.headers.Get(k); got != want {
 			test.Errorf("headers[%q] = %q, want %q", k, got, want)
 		}
 	}
@@ -235,9 +261,7 @@ func TestClientInvokeWithHeaders(test *testing.T) {
 		Service: "test.Service",
 		Method:  "TestMethod",
 		Message: []byte{},
-		Headers: map[string]string{
-			"X-Custom": "request-value",
-		},
+		Headers: protocol.Pairs("X-Custom", "request-value"),
 	})
 
 	if err != nil {
@@ -252,9 +276,107 @@ func TestClientInvokeWithHeaders(test *testing.T) {
 	}
 }
 
-func TestClientInvokeHT(test *testing.T) {
+func TestClientInvokeHTTPStatus(test *testing.T) {
+	tests := []struct {
+		name        string
+		httpStatus  int
+		grpcStatus  string
+		grpcMessage string
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:        "not found",
+			httpStatus:  http.StatusNotFound,
+			wantCode:    protocol.StatusUnimplemented,
+			wantMessage: "HTTP 404 Not Found",
+		},
+		{
+			name:        "unauthorized",
+			httpStatus:  http.StatusUnauthorized,
+			wantCode:    protocol.StatusUnauthenticated,
+			wantMessage: "HTTP 401 Unauthorized",
+		},
+		{
+			name:        "service unavailable",
+			httpStatus:  http.StatusServiceUnavailable,
+			wantCode:    protocol.StatusUnavailable,
+			wantMessage: "HTTP 503 Service Unavailable",
+		},
+		{
+			name:        "non-200 with grpc-status",
+			httpStatus:  http.StatusInternalServerError,
+			grpcStatus:  "8",
+			grpcMessage: "quota exceeded",
+			wantCode:    protocol.StatusResourceExhausted,
+			wantMessage: "quota exceeded",
+		},
+		{
+			name:        "non-200 with OK grpc-status",
+			httpStatus:  http.StatusBadGateway,
+			grpcStatus:  "0",
+			wantCode:    protocol.StatusUnavailable,
+			wantMessage: "HTTP 502 Bad Gateway",
+		},
+		{
+			name:        "trailers-only",
+			httpStatus:  http.StatusOK,
+			grpcStatus:  "5",
+			grpcMessage: "no such entity",
+			wantCode:    protocol.StatusNotFound,
+			wantMessage: "no such entity",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+				if tt.grpcStatus != "" {
+					w.Header().Set("Grpc-Status", tt.grpcStatus)
+					w.Header().Set("Grpc-Message", tt.grpcMessage)
+				}
+				w.WriteHeader(tt.httpStatus)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, &Options{Plaintext: true})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			resp, err := client.Invoke(context.Background(), &Request{
+				Service: "test.Service",
+				Method:  "TestMethod",
+				Message: []byte{},
+			})
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+
+			if resp.HTTPStatus != tt.httpStatus {
+				t.Errorf("HTTPStatus = %d, want %d", resp.HTTPStatus, tt.httpStatus)
+			}
+			if resp.Status.Code != tt.wantCode {
+				t.Errorf("Status code = %d, want %d", resp.Status.Code, tt.wantCode)
+			}
+			if resp.Status.Message != tt.wantMessage {
+				t.Errorf("Status message = %q, want %q", resp.Status.Message, tt.wantMessage)
+			}
+			if tt.grpcStatus != "" && resp.Trailers.Get("grpc-status") != tt.grpcStatus {
+				t.Errorf("grpc-status trailer = %q, want %q", resp.Trailers.Get("grpc-status"), tt.grpcStatus)
+			}
+		})
+	}
+}
+
+func TestClientInvokeMissingTrailers(test *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		w.WriteHeader(http.StatusOK)
+
+		// A data frame with no trailer frame after it
+		w.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01})
 	}))
 	defer server.Close()
 
@@ -263,14 +385,109 @@ func TestClientInvokeHT(test *testing.T) {
 		test.Fatalf("NewClient() error = %v", err)
 	}
 
-	_, err = client.Invoke(context.Background(), &Request{
+	request := &Request{
 		Service: "test.Service",
 		Method:  "TestMethod",
 		Message: []byte{},
+	}
+
+	resp, err := client.Invoke(context.Background(), request)
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusInternal {
+		test.Errorf("Invoke() status code = %d, want %d", resp.Status.Code, protocol.StatusInternal)
+	}
+	if !strings.Contains(resp.Status.Message, "missing trailers") {
+		test.Errorf("Invoke() status message = %q, want missing trailers", resp.Status.Message)
+	}
+	if len(resp.Messages) != 1 {
+		test.Errorf("Invoke() got %d messages, want 1", len(resp.Messages))
+	}
+
+	resp, err = client.InvokeServerStream(context.Background(), request, nil)
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusInternal {
+		test.Errorf("InvokeServerStream() status code = %d, want %d", resp.Status.Code, protocol.StatusInternal)
+	}
+}
+
+func TestClientInvokeServerStreamDiscardMessages(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		w.WriteHeader(http.StatusOK)
+
+		encoder := protocol.NewEncoder(w)
+		for _, message := range []string{"one", "two", "three"} {
+			encoder.Encode([]byte(message))
+		}
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	var received []string
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service:         "test.Service",
+		Method:          "TestMethod",
+		Message:         []byte{},
+		DiscardMessages: true,
+		ReuseBuffers:    true,
+	}, func(message []byte) error {
+		received = append(received, string(message))
+		return nil
 	})
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
 
-	if err == nil {
-		test.Fatal("Invoke() expected error for 404 response")
+	if strings.Join(received, ",") != "one,two,three" {
+		test.Errorf("handler received %q, want [one two three]", received)
+	}
+	if len(resp.Messages) != 0 {
+		test.Errorf("Response.Messages has %d messages, want none", len(resp.Messages))
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+}
+
+func TestClientInvokeServerStreamReuseBuffers(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		w.WriteHeader(http.StatusOK)
+
+		encoder := protocol.NewEncoder(w)
+		encoder.Encode([]byte("first"))
+		encoder.Encode([]byte("second"))
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	// Retained messages are copied out of the reused buffer
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service:      "test.Service",
+		Method:       "TestMethod",
+		Message:      []byte{},
+		ReuseBuffers: true,
+	}, nil)
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+
+	if len(resp.Messages) != 2 || string(resp.Messages[0]) != "first" || string(resp.Messages[1]) != "second" {
+		test.Errorf("Response.Messages = %q, want [first second]", resp.Messages)
 	}
 }
 
@@ -378,12 +595,276 @@ func TestClientInvokeURLConstruction(test *testing.T) {
 	}
 }
 
+func TestClientInvokeTextMode(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != protocol.ContentTypeGRPCWebText {
+			test.Errorf("Content-Type = %q, want %q", ct, protocol.ContentTypeGRPCWebText)
+		}
+
+		// Request body must be base64-encoded frames
+		body, _ := io.ReadAll(r.Body)
+		frames, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			test.Errorf("request body is not base64: %v", err)
+		}
+		if msg, err := protocol.DecodeMessage(frames); err != nil || len(msg) != 2 {
+			test.Errorf("decoded request message = %v, err = %v", msg, err)
+		}
+
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWebText)
+		w.WriteHeader(http.StatusOK)
+
+		// Each frame is flushed as a separately padded base64 chunk
+		dataFrame, _ := protocol.EncodeFrame(protocol.Frame{Type: protocol.FrameData, Payload: []byte{0x08, 0x01}})
+		w.Write(protocol.EncodeText(dataFrame))
+		w.(http.Flusher).Flush()
+		trailerFrame, _ := protocol.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+		w.Write(protocol.EncodeText(trailerFrame))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Text: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "TestMethod", Message: []byte{0x08, 0x01}}
+
+	resp, err := client.Invoke(context.Background(), req)
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if len(resp.Messages) != 1 || len(resp.Messages[0]) != 2 {
+		test.Errorf("Messages = %v, want one 2-byte message", resp.Messages)
+	}
+	if resp.Status == nil || resp.Status.Code != 0 {
+		test.Errorf("Status = %+v, want OK", resp.Status)
+	}
+
+	var streamed int
+	_, err = client.InvokeServerStream(context.Background(), req, func(message []byte) error {
+		streamed++
+		return nil
+	})
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if streamed != 1 {
+		test.Errorf("streamed %d messages, want 1", streamed)
+	}
+}
+
+func TestClientInvokeCompression(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := r.Header.Get("Grpc-Encoding"); enc != "gzip" {
+			test.Errorf("Grpc-Encoding = %q, want %q", enc, "gzip")
+		}
+		if accept := r.Header.Get("Grpc-Accept-Encoding"); accept == "" {
+			test.Error("Grpc-Accept-Encoding header not set")
+		}
+
+		dec := protocol.NewDecoder(r.Body)
+		dec.SetCompressor(protocol.GetCompressor("gzip"))
+		msg, err := dec.Decode()
+		if err != nil || len(msg) != 2 {
+			test.Errorf("decoded request message = %v, err = %v", msg, err)
+		}
+
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		w.Header().Set("Grpc-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+
+		enc := protocol.NewEncoder(w)
+		enc.SetCompressor(protocol.GetCompressor("gzip"))
+		enc.Encode([]byte{0x08, 0x2a})
+		enc.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Compression: "gzip"})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "TestMethod",
+		Message: []byte{0x08, 0x01},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if len(resp.Messages) != 1 || resp.Messages[0][1] != 0x2a {
+		test.Errorf("Messages = %v, want decompressed [8 42]", resp.Messages)
+	}
+	if resp.Status == nil || resp.Status.Code != 0 {
+		test.Errorf("Status = %+v, want OK", resp.Status)
+	}
+}
+
+func TestNewClientUnsupportedCompression(test *testing.T) {
+	if _, err := NewClient("http://localhost:8080", &Options{Plaintext: true, Compression: "brotli"}); err == nil {
+		test.Fatal("NewClient() expected error for unsupported compression")
+	}
+}
+
+func TestClientInvokeStatusDetails(test *testing.T) {
+	detail, _ := anypb.New(&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+	raw, _ := proto.Marshal(&spb.Status{Code: 14, Message: "try later", Details: []*anypb.Any{detail}})
+	details := base64.RawStdEncoding.EncodeToString(raw)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		w.WriteHeader(http.StatusOK)
+
+		trailer := []byte("grpc-status: 14\r\ngrpc-message: try later\r\ngrpc-status-details-bin: " + details + "\r\n")
+		enc := protocol.NewEncoder(w)
+		enc.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: trailer})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "TestMethod", Message: []byte{}}
+	unary, err := client.Invoke(context.Background(), req)
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	stream, err := client.InvokeServerStream(context.Background(), req, nil)
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+
+	for name, resp := range map[string]*Response{"unary": unary, "stream": stream} {
+		if resp.Status == nil || resp.Status.Code != 14 {
+			test.Fatalf("%s: Status = %+v, want code 14", name, resp.Status)
+		}
+		if len(resp.Status.Details) != 1 || resp.Status.Details[0].GetTypeUrl() != "type.googleapis.com/google.rpc.RetryInfo" {
+			test.Errorf("%s: Details = %v, want one RetryInfo", name, resp.Status.Details)
+		}
+	}
+}
+
+func TestClientInvokeJSONCodec(test *testing.T) {
+	gotContentTypes := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentTypes <- r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		message, err := protocol.DecodeMessage(body)
+		if err != nil {
+			test.Errorf("DecodeMessage() error = %v", err)
+		}
+
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		response, _ := protocol.EncodeMessage(message)
+		w.Write(response)
+		trailer, _ := protocol.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+		w.Write(trailer)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Codec: protocol.CodecJSON})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	if client.Codec().Name() != protocol.CodecJSON {
+		test.Errorf("Codec() = %q, want %q", client.Codec().Name(), protocol.CodecJSON)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Echo",
+		Message: []byte(`{"id":"123"}`),
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if got := <-gotContentTypes; got != protocol.ContentTypeGRPCWebJSON {
+		test.Errorf("Content-Type = %q, want %q", got, protocol.ContentTypeGRPCWebJSON)
+	}
+	if len(resp.Messages) != 1 || string(resp.Messages[0]) != `{"id":"123"}` {
+		test.Errorf("Messages = %q", resp.Messages)
+	}
+
+	// A per-request codec overrides the client's codec
+	_, err = client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Echo",
+		Message: []byte{0x08, 0x01},
+		Codec:   protocol.ProtoCodec{},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if got := <-gotContentTypes; got != protocol.ContentTypeGRPCWeb {
+		test.Errorf("Content-Type = %q, want %q", got, protocol.ContentTypeGRPCWeb)
+	}
+}
+
+func TestClientInvokeGRPCTimeout(test *testing.T) {
+	gotTimeouts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTimeouts <- r.Header.Get(protocol.HeaderGRPCTimeout)
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		trailer, _ := protocol.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+		w.Write(trailer)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		serverTimeout time.Duration
+		ctxTimeout    time.Duration
+		wantMin       time.Duration
+		wantMax       time.Duration
+	}{
+		{name: "context deadline", ctxTimeout: 5 * time.Second, wantMin: 4 * time.Second, wantMax: 5 * time.Second},
+		{name: "client timeout", wantMin: 30 * time.Second, wantMax: 30 * time.Second},
+		{name: "server override", serverTimeout: 250 * time.Millisecond, ctxTimeout: 5 * time.Second, wantMin: 250 * time.Millisecond, wantMax: 250 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Plaintext = true
+			opts.ServerTimeout = tt.serverTimeout
+			client, err := NewClient(server.URL, opts)
+			if err != nil {
+				test.Fatalf("NewClient() error = %v", err)
+			}
+
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+
+			if _, err := client.Invoke(ctx, &Request{Service: "test.Service", Method: "Slow"}); err != nil {
+				test.Fatalf("Invoke() error = %v", err)
+			}
+
+			header := <-gotTimeouts
+			got, err := protocol.ParseTimeout(header)
+			if err != nil {
+				test.Fatalf("Grpc-Timeout %q: %v", header, err)
+			}
+			if got < tt.wantMin || got > tt.wantMax {
+				test.Errorf("Grpc-Timeout = %v (%q), want between %v and %v", got, header, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
 func TestOptionsWithCertificates(test *testing.T) {
 	// Test that certificate options are accepted (we can't test actual TLS without real certs)
 	opts := &Options{
 		CertFile: "client.crt",
 		KeyFile:  "client.key",
-		CAFile:   "ca.crt",
+		CAFiles:  []string{"ca.crt"},
 	}
 
 	// This will fail because files don't exist, but we're testing the options are handled
@@ -399,9 +880,7 @@ func TestRequestStruct(test *testing.T) {
 		Service: "test.Service",
 		Method:  "TestMethod",
 		Message: []byte{0x01, 0x02, 0x03},
-		Headers: map[string]string{
-			"Key": "Value",
-		},
+		Headers: protocol.Pairs("Key", "Value"),
 	}
 
 	if req.Service != "test.Service" {
@@ -413,15 +892,15 @@ func TestRequestStruct(test *testing.T) {
 	if len(req.Message) != 3 {
 		test.Errorf("Message length = %d, want 3", len(req.Message))
 	}
-	if req.Headers["Key"] != "Value" {
-		test.Errorf("Headers[Key] = %q, want %q", req.Headers["Key"], "Value")
+	if req.Headers.Get("Key") != "Value" {
+		test.Errorf("Headers[Key] = %q, want %q", req.Headers.Get("Key"), "Value")
 	}
 }
 
 func TestResponseStruct(test *testing.T) {
 	resp := &Response{
 		Messages:   [][]byte{{0x01}, {0x02}},
-		Trailers:   map[string]string{"grpc-status": "0"},
+		Trailers:   protocol.Pairs("grpc-status", "0"),
 		Status:     &protocol.Status{Code: 0, Message: "OK"},
 		HTTPStatus: 200,
 		HTTPHeaders: http.Header{
@@ -432,8 +911,8 @@ func TestResponseStruct(test *testing.T) {
 	if len(resp.Messages) != 2 {
 		test.Errorf("Messages count = %d, want 2", len(resp.Messages))
 	}
-	if resp.Trailers["grpc-status"] != "0" {
-		test.Errorf("Trailers[grpc-status] = %q, want %q", resp.Trailers["grpc-status"], "0")
+	if resp.Trailers.Get("grpc-status") != "0" {
+		test.Errorf("Trailers[grpc-status] = %q, want %q", resp.Trailers.Get("grpc-status"), "0")
 	}
 	if resp.Status.Code != 0 {
 		test.Errorf("Status.Code = %d, want 0", resp.Status.Code)
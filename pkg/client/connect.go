@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// readConnectResponse reads the response to a unary Connect call: the message
+// unframed in the body, or a JSON error with a non-200 status.
+func (client *Client) readConnectResponse(httpResp *http.Response) (*Response, error) {
+	respBody, err := client.readConnectBody(httpResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	resp := &Response{Trailers: connectTrailers(httpResp.Header)}
+
+	// Errors are reported as a JSON body with a non-200 status
+	if httpResp.StatusCode != http.StatusOK {
+		resp.Status = protocol.ParseConnectError(respBody, httpResp.StatusCode)
+		return resp, nil
+	}
+
+	if client.verbose {
+		fmt.Printf("< [data frame] %d bytes\n", len(respBody))
+	}
+	resp.Messages = [][]byte{respBody}
+	resp.Status = &protocol.Status{Code: protocol.StatusOK}
+	return resp, nil
+}
+
+// readConnectStream reads the response to a server streaming Connect call,
+// passing each message to handler if it is non-nil. Messages arrive in
+// enveloped frames and the stream ends with an end-of-stream frame carrying
+// the status and trailers.
+func (client *Client) readConnectStream(httpResp *http.Response, req *Request, handler StreamHandler) (*Response, error) {
+	// Errors before the stream starts use the unary error format
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, err := client.readConnectBody(httpResp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return &Response{Status: protocol.ParseConnectError(respBody, httpResp.StatusCode)}, nil
+	}
+
+	decoder := protocol.NewDecoder(httpResp.Body)
+	decoder.SetMaxMessageSize(client.maxMsgSize)
+	decoder.SetCompressor(protocol.GetCompressor(httpResp.Header.Get(protocol.HeaderConnectContentEncoding)))
+	decoder.SetReuseBuffers(req.ReuseBuffers)
+	defer decoder.Release()
+
+	resp, err := client.readFrames(decoder, req, handler, func(frame *protocol.Frame) (*protocol.Metadata, *protocol.Status, error) {
+		if frame.Type != protocol.FrameConnectEndStream {
+			return nil, nil, nil
+		}
+		return protocol.ParseConnectEndStream(frame.Payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A Connect stream must end with an end-of-stream message
+	if resp.Status == nil {
+		resp.Status = &protocol.Status{
+			Code:    protocol.StatusInternal,
+			Message: "protocol error: missing end-of-stream message",
+		}
+	}
+	return resp, nil
+}
+
+// newConnectRequest creates the HTTP request of a unary Connect call and
+// returns it with its body as sent on the wire.
+func (client *Client) newConnectRequest(ctx context.Context, req *Request) (*http.Request, []byte, error) {
+	url := fmt.Sprintf("%s/%s/%s", client.baseURL, req.Service, req.Method)
+
+	body := req.Message
+	if client.compressor != nil {
+		compressed, err := protocol.CompressPayload(client.compressor, req.Message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode message: %w", err)
+		}
+		body = compressed
+	}
+
+	method := http.MethodPost
+	var reqBody io.Reader = bytes.NewReader(body)
+	if client.httpGet && req.Idempotent {
+		compression := ""
+		if client.compressor != nil {
+			compression = client.compressor.Name()
+		}
+		method = http.MethodGet
+		url += "?" + protocol.ConnectGetQuery(body, client.requestCodec(req).Name(), compression)
+		reqBody = nil
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client.setConnectHeaders(httpReq, req, false)
+	return httpReq, body, nil
+}
+
+// newConnectStreamRequest creates the HTTP request of a server streaming
+// Connect call. The message is enveloped as the body is sent.
+func (client *Client) newConnectStreamRequest(ctx context.Context, req *Request) (*http.Request, error) {
+	url := fmt.Sprintf("%s/%s/%s", client.baseURL, req.Service, req.Method)
+	httpReq, err := client.newFramedRequest(ctx, url, req.Message, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	client.setConnectHeaders(httpReq, req, true)
+	return httpReq, nil
+}
+
+// setConnectHeaders sets the Connect headers, the deadline, and the custom
+// headers of a unary or streaming call. Streaming calls negotiate compression
+// with Connect-prefixed headers, since the HTTP ones describe the envelope.
+func (client *Client) setConnectHeaders(httpReq *http.Request, req *Request, streaming bool) {
+	protocol.SetConnectRequestHeaders(httpReq, protocol.ConnectContentType(client.requestCodec(req).Name(), streaming))
+	acceptEncoding, contentEncoding := protocol.HeaderAcceptEncoding, protocol.HeaderContentEncoding
+	if streaming {
+		acceptEncoding, contentEncoding = protocol.HeaderConnectAcceptEncoding, protocol.HeaderConnectContentEncoding
+	}
+	httpReq.Header.Set(acceptEncoding, protocol.AcceptEncoding())
+	if client.compressor != nil && httpReq.Method != http.MethodGet {
+		httpReq.Header.Set(contentEncoding, client.compressor.Name())
+	}
+	if timeout, ok := client.requestTimeout(httpReq.Context()); ok {
+		httpReq.Header.Set(protocol.HeaderConnectTimeout, protocol.EncodeConnectTimeout(timeout))
+	}
+	client.applyHeaders(httpReq, req)
+}
+
+// readConnectBody reads an unframed Connect response body, enforcing the
+// maximum message size and undoing any Content-Encoding the transport did not.
+func (client *Client) readConnectBody(httpResp *http.Response) ([]byte, error) {
+	var reader io.Reader = httpResp.Body
+	if encoding := httpResp.Header.Get(protocol.HeaderContentEncoding); encoding != "" && !httpResp.Uncompressed {
+		compressor := protocol.GetCompressor(encoding)
+		if compressor == nil && !strings.EqualFold(encoding, protocol.CompressionIdentity) {
+			return nil, fmt.Errorf("unsupported response encoding %q", encoding)
+		}
+		if compressor != nil {
+			decompressed, err := compressor.Decompress(reader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress response: %w", err)
+			}
+			reader = decompressed
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, int64(client.maxMsgSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > client.maxMsgSize {
+		return nil, fmt.Errorf("message size exceeds maximum %d", client.maxMsgSize)
+	}
+	return body, nil
+}
+
+// connectTrailers extracts the trailers that unary Connect responses send as
+// Trailer- prefixed headers.
+func connectTrailers(header http.Header) *protocol.Metadata {
+	trailers := http.Header{}
+	for key, values := range header {
+		if len(key) > len(protocol.ConnectTrailerPrefix) && strings.EqualFold(key[:len(protocol.ConnectTrailerPrefix)], protocol.ConnectTrailerPrefix) {
+			trailers[key[len(protocol.ConnectTrailerPrefix):]] = values
+		}
+	}
+	return protocol.MetadataFromHeader(trailers)
+}
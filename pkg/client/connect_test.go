@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+func TestClientInvokeConnect(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			test.Errorf("Method = %s, want POST", r.Method)
+		}
+		if r.Header.Get("Content-Type") != protocol.ContentTypeConnectProto {
+			test.Errorf("Content-Type = %q, want %q", r.Header.Get("Content-Type"), protocol.ContentTypeConnectProto)
+		}
+		if r.Header.Get(protocol.HeaderConnectProtocolVersion) != "1" {
+			test.Errorf("Connect-Protocol-Version = %q, want 1", r.Header.Get(protocol.HeaderConnectProtocolVersion))
+		}
+
+		// Unary Connect bodies are not enveloped
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "ping" {
+			test.Errorf("body = %q, want %q", body, "ping")
+		}
+
+		w.Header().Set("Content-Type", protocol.ContentTypeConnectProto)
+		w.Header().Set("Trailer-X-Served-By", "test")
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Protocol: ProtocolConnect})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Ping", Message: []byte("ping")})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status.Code = %d, want 0", resp.Status.Code)
+	}
+	if len(resp.Messages) != 1 || string(resp.Messages[0]) != "pong" {
+		test.Errorf("Messages = %q, want [pong]", resp.Messages)
+	}
+	if resp.Trailers.Get("x-served-by") != "test" {
+		test.Errorf("Trailers[x-served-by] = %q, want %q", resp.Trailers.Get("x-served-by"), "test")
+	}
+}
+
+func TestClientInvokeConnectGet(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			test.Errorf("Method = %s, want GET", r.Method)
+		}
+		query := r.URL.Query()
+		message, err := base64.RawURLEncoding.DecodeString(query.Get("message"))
+		if err != nil || string(message) != "ping" {
+			test.Errorf("message = %q (%v), want %q", message, err, "ping")
+		}
+		if query.Get("encoding") != "proto" || query.Get("connect") != "v1" {
+			test.Errorf("query = %v", query)
+		}
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Protocol: ProtocolConnect, HTTPGet: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service:    "test.Service",
+		Method:     "Ping",
+		Message:    []byte("ping"),
+		Idempotent: true,
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if len(resp.Messages) != 1 || string(resp.Messages[0]) != "pong" {
+		test.Errorf("Messages = %q, want [pong]", resp.Messages)
+	}
+}
+
+func TestClientInvokeConnectError(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":"not_found","message":"no such user"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Protocol: ProtocolConnect})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Get"})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusNotFound || resp.Status.Message != "no such user" {
+		test.Errorf("Status = %d %q, want %d %q", resp.Status.Code, resp.Status.Message, protocol.StatusNotFound, "no such user")
+	}
+}
+
+func TestClientInvokeConnectStream(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != protocol.ContentTypeConnectStreamProto {
+			test.Errorf("Content-Type = %q, want %q", r.Header.Get("Content-Type"), protocol.ContentTypeConnectStreamProto)
+		}
+		message, err := protocol.DecodeMessage(mustReadAll(test, r.Body))
+		if err != nil || string(message) != "count" {
+			test.Errorf("request message = %q (%v), want %q", message, err, "count")
+		}
+
+		w.Header().Set("Content-Type", protocol.ContentTypeConnectStreamProto)
+		for _, frame := range []protocol.Frame{
+			{Type: protocol.FrameData, Payload: []byte("one")},
+			{Type: protocol.FrameData, Payload: []byte("two")},
+			{Type: protocol.FrameConnectEndStream, Payload: []byte(`{"error":{"code":"aborted","message":"done early"},"metadata":{"x-count":["2"]}}`)},
+		} {
+			encoded, _ := protocol.EncodeFrame(frame)
+			w.Write(encoded)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Protocol: ProtocolConnect})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	var received []string
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Count",
+		Message: []byte("count"),
+	}, func(message []byte) error {
+		received = append(received, string(message))
+		return nil
+	})
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if len(received) != 2 || received[0] != "one" || received[1] != "two" {
+		test.Errorf("received = %q, want [one two]", received)
+	}
+	if resp.Status.Code != protocol.StatusAborted || resp.Status.Message != "done early" {
+		test.Errorf("Status = %d %q, want %d %q", resp.Status.Code, resp.Status.Message, protocol.StatusAborted, "done early")
+	}
+	if resp.Trailers.Get("x-count") != "2" {
+		test.Errorf("Trailers[x-count] = %q, want %q", resp.Trailers.Get("x-count"), "2")
+	}
+}
+
+func TestNewClientConnectOptions(test *testing.T) {
+	if _, err := NewClient("http://localhost", &Options{Protocol: "grpc"}); err == nil {
+		test.Error("NewClient() expected error for unknown protocol")
+	}
+	if _, err := NewClient("http://localhost", &Options{Protocol: ProtocolConnect, Text: true}); err == nil {
+		test.Error("NewClient() expected error for text mode with Connect")
+	}
+}
+
+func mustReadAll(test *testing.T, reader io.Reader) []byte {
+	test.Helper()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		test.Fatalf("ReadAll() error = %v", err)
+	}
+	return data
+}
@@ -0,0 +1,314 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in the domain field of a Netscape
+// cookie file, as curl writes them.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// cookieJar is an http.CookieJar that, unlike net/http/cookiejar, can list
+// its cookies so they can be saved in the Netscape format curl and browsers'
+// export tools use. Session cookies are saved too, so a session outlives one
+// command.
+type cookieJar struct {
+	mu      sync.Mutex
+	cookies []*jarCookie
+	created int // Creation counter that orders cookies with equal paths
+}
+
+// jarCookie is a stored cookie.
+type jarCookie struct {
+	domain   string // Lower-case, without a leading dot
+	hostOnly bool   // Sent to domain only, not its subdomains
+	path     string
+	secure   bool
+	httpOnly bool
+	expires  time.Time // Zero for session cookies
+	name     string
+	value    string
+	created  int
+}
+
+// newCookieJar returns an empty cookie jar.
+func newCookieJar() *cookieJar {
+	return &cookieJar{}
+}
+
+// SetCookies implements http.CookieJar, storing the cookies a response to u
+// sets. Cookies for a domain that u's host is not in are ignored, and expired
+// cookies delete stored ones.
+func (jar *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	for _, cookie := range cookies {
+		stored := &jarCookie{
+			domain:   host,
+			hostOnly: true,
+			path:     cookie.Path,
+			secure:   cookie.Secure,
+			httpOnly: cookie.HttpOnly,
+			name:     cookie.Name,
+			value:    cookie.Value,
+		}
+		if cookie.Domain != "" {
+			domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+			if !domainMatch(host, domain) {
+				continue
+			}
+			// A cookie for a public suffix such as com or co.uk would reach
+			// every site under it; like browsers, keep it only as a
+			// host-only cookie of the suffix itself
+			if isPublicSuffix(domain) {
+				if host != domain {
+					continue
+				}
+			} else {
+				stored.domain, stored.hostOnly = domain, false
+			}
+		}
+		if !strings.HasPrefix(stored.path, "/") {
+			stored.path = defaultCookiePath(u.Path)
+		}
+
+		remove := false
+		switch {
+		case cookie.MaxAge < 0:
+			remove = true
+		case cookie.MaxAge > 0:
+			stored.expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		case !cookie.Expires.IsZero():
+			stored.expires = cookie.Expires
+			remove = !cookie.Expires.After(now)
+		}
+		jar.store(stored, remove)
+	}
+}
+
+// store replaces the cookie with the same name, domain and path, or deletes
+// it if remove is set.
+func (jar *cookieJar) store(cookie *jarCookie, remove bool) {
+	for index, existing := range jar.cookies {
+		if existing.name == cookie.name && existing.domain == cookie.domain && existing.path == cookie.path {
+			if remove {
+				jar.cookies = append(jar.cookies[:index], jar.cookies[index+1:]...)
+				return
+			}
+			cookie.created = existing.created
+			jar.cookies[index] = cookie
+			return
+		}
+	}
+	if !remove {
+		jar.created++
+		cookie.created = jar.created
+		jar.cookies = append(jar.cookies, cookie)
+	}
+}
+
+// Cookies implements http.CookieJar, returning the cookies to send to u with
+// longer paths first.
+func (jar *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	now := time.Now()
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	var matches []*jarCookie
+	for _, cookie := range jar.cookies {
+		if !cookie.expires.IsZero() && !cookie.expires.After(now) {
+			continue
+		}
+		if cookie.hostOnly && host != cookie.domain || !cookie.hostOnly && !domainMatch(host, cookie.domain) {
+			continue
+		}
+		if cookie.secure && !secure || !pathMatch(u.Path, cookie.path) {
+			continue
+		}
+		matches = append(matches, cookie)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if len(matches[i].path) != len(matches[j].path) {
+			return len(matches[i].path) > len(matches[j].path)
+		}
+		return matches[i].created < matches[j].created
+	})
+
+	cookies := make([]*http.Cookie, len(matches))
+	for index, cookie := range matches {
+		cookies[index] = &http.Cookie{Name: cookie.name, Value: cookie.value}
+	}
+	return cookies
+}
+
+// domainMatch reports whether host is domain or a subdomain of it. IP
+// addresses only match themselves.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// isPublicSuffix reports whether domain is a public suffix, under which
+// unrelated parties register names, such as com, co.uk or github.io.
+func isPublicSuffix(domain string) bool {
+	if net.ParseIP(domain) != nil {
+		return false
+	}
+	return publicsuffix.List.PublicSuffix(domain) == domain
+}
+
+// pathMatch reports whether a cookie with cookiePath is sent to requestPath.
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return len(requestPath) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath is the path of a cookie set without one: the directory of
+// the request path (RFC 6265 section 5.1.4).
+func defaultCookiePath(requestPath string) string {
+	index := strings.LastIndex(requestPath, "/")
+	if index <= 0 {
+		return "/"
+	}
+	return requestPath[:index]
+}
+
+// addCookies adds name=value pairs, separated by semicolons as in a Cookie
+// header, as session cookies for baseURL's host.
+func (jar *cookieJar) addCookies(baseURL string, pairs []string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	var cookies []*http.Cookie
+	for _, pair := range pairs {
+		for _, part := range strings.Split(pair, ";") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, found := strings.Cut(part, "=")
+			if !found || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid cookie %q: expected name=value", part)
+			}
+			cookies = append(cookies, &http.Cookie{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value), Path: "/"})
+		}
+	}
+	jar.SetCookies(u, cookies)
+	return nil
+}
+
+// load reads cookies from a Netscape cookie file, skipping expired ones. A
+// missing file is an empty jar.
+func (jar *cookieJar) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cookie jar: %w", err)
+	}
+
+	now := time.Now()
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("invalid cookie jar %s line %d: expected 7 tab-separated fields", path, number)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cookie jar %s line %d: invalid expiry %q", path, number, fields[4])
+		}
+
+		cookie := &jarCookie{
+			domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			hostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			path:     fields[2],
+			secure:   strings.EqualFold(fields[3], "TRUE"),
+			httpOnly: httpOnly,
+			name:     fields[5],
+			value:    fields[6],
+		}
+		if expires != 0 {
+			cookie.expires = time.Unix(expires, 0)
+			if !cookie.expires.After(now) {
+				continue
+			}
+		}
+		jar.store(cookie, false)
+	}
+	return scanner.Err()
+}
+
+// save writes the unexpired cookies to a Netscape cookie file.
+func (jar *cookieJar) save(path string) error {
+	now := time.Now()
+	var buf bytes.Buffer
+	buf.WriteString("# Netscape HTTP Cookie File\n# This file was generated by grpcwebcurl. Edit at your own risk.\n\n")
+
+	jar.mu.Lock()
+	for _, cookie := range jar.cookies {
+		if !cookie.expires.IsZero() && !cookie.expires.After(now) {
+			continue
+		}
+		domain, includeSubdomains := cookie.domain, "FALSE"
+		if !cookie.hostOnly {
+			domain, includeSubdomains = "."+domain, "TRUE"
+		}
+		if cookie.httpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !cookie.expires.IsZero() {
+			expires = cookie.expires.Unix()
+		}
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, includeSubdomains, cookie.path,
+			strings.ToUpper(strconv.FormatBool(cookie.secure)), expires, cookie.name, cookie.value)
+	}
+	jar.mu.Unlock()
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to save cookie jar: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cookieHeader returns the cookies the jar sends to rawURL as a Cookie header.
+func cookieHeader(jar *cookieJar, rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	var pairs []string
+	for _, cookie := range jar.Cookies(u) {
+		pairs = append(pairs, cookie.String())
+	}
+	return strings.Join(pairs, "; ")
+}
+
+func TestCookieJar(test *testing.T) {
+	tests := []struct {
+		name    string
+		setURL  string
+		cookies []*http.Cookie
+		getURL  string
+		want    string
+	}{
+		{
+			name:    "host-only cookie",
+			setURL:  "https://api.example.com/pkg.Service/Method",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Path: "/"}},
+			getURL:  "https://api.example.com/other.Service/Method",
+			want:    "session=abc",
+		},
+		{
+			name:    "host-only cookie is not sent to subdomains",
+			setURL:  "https://example.com/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc"}},
+			getURL:  "https://api.example.com/",
+		},
+		{
+			name:    "domain cookie is sent to subdomains",
+			setURL:  "https://login.example.com/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: ".Example.com"}},
+			getURL:  "https://api.example.com/",
+			want:    "session=abc",
+		},
+		{
+			name:    "cookie for another domain is ignored",
+			setURL:  "https://api.example.com/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: "other.com"}},
+			getURL:  "https://other.com/",
+		},
+		{
+			name:    "cookie for a public suffix is ignored",
+			setURL:  "https://www.example.co.uk/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: "co.uk"}, {Name: "tld", Value: "1", Domain: ".com"}},
+			getURL:  "https://other.co.uk/",
+		},
+		{
+			name:    "domain cookie below a public suffix is sent to subdomains",
+			setURL:  "https://www.example.co.uk/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: "example.co.uk"}},
+			getURL:  "https://api.example.co.uk/",
+			want:    "session=abc",
+		},
+		{
+			name:    "public suffix host keeps its cookie host-only",
+			setURL:  "https://github.io/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: "github.io"}},
+			getURL:  "https://user.github.io/",
+		},
+		{
+			name:    "default path is the request directory",
+			setURL:  "https://api.example.com/pkg.Service/Method",
+			cookies: []*http.Cookie{{Name: "scoped", Value: "1"}},
+			getURL:  "https://api.example.com/pkg.Service/Other",
+			want:    "scoped=1",
+		},
+		{
+			name:    "path must match on a segment boundary",
+			setURL:  "https://api.example.com/",
+			cookies: []*http.Cookie{{Name: "scoped", Value: "1", Path: "/pkg.Service"}},
+			getURL:  "https://api.example.com/pkg.ServiceV2/Method",
+		},
+		{
+			name:    "secure cookie is not sent over http",
+			setURL:  "https://api.example.com/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Secure: true}},
+			getURL:  "http://api.example.com/",
+		},
+		{
+			name:   "longer paths first",
+			setURL: "https://api.example.com/",
+			cookies: []*http.Cookie{
+				{Name: "a", Value: "1", Path: "/"},
+				{Name: "b", Value: "2", Path: "/pkg.Service"},
+			},
+			getURL: "https://api.example.com/pkg.Service/Method",
+			want:   "b=2; a=1",
+		},
+		{
+			name:   "later cookie replaces earlier",
+			setURL: "https://api.example.com/",
+			cookies: []*http.Cookie{
+				{Name: "session", Value: "old", Path: "/"},
+				{Name: "session", Value: "new", Path: "/"},
+			},
+			getURL: "https://api.example.com/",
+			want:   "session=new",
+		},
+		{
+			name:   "Max-Age 0 deletes",
+			setURL: "https://api.example.com/",
+			cookies: []*http.Cookie{
+				{Name: "session", Value: "abc", Path: "/"},
+				{Name: "session", Path: "/", MaxAge: -1},
+			},
+			getURL: "https://api.example.com/",
+		},
+		{
+			name:    "expired cookie is not stored",
+			setURL:  "https://api.example.com/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Expires: time.Now().Add(-time.Hour)}},
+			getURL:  "https://api.example.com/",
+		},
+		{
+			name:    "IP address hosts",
+			setURL:  "http://127.0.0.1:8080/",
+			cookies: []*http.Cookie{{Name: "session", Value: "abc", Domain: "0.1"}, {Name: "ip", Value: "1", Domain: "127.0.0.1"}},
+			getURL:  "http://127.0.0.1:9090/",
+			want:    "ip=1",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			jar := newCookieJar()
+			u, _ := url.Parse(tt.setURL)
+			jar.SetCookies(u, tt.cookies)
+			if got := cookieHeader(jar, tt.getURL); got != tt.want {
+				t.Errorf("cookies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieJarFile(test *testing.T) {
+	dir := test.TempDir()
+	path := filepath.Join(dir, "cookies.txt")
+	future := time.Now().Add(time.Hour).Unix()
+	content := "# Netscape HTTP Cookie File\n\n" +
+		".example.com\tTRUE\t/\tTRUE\t" + strconv.FormatInt(future, 10) + "\tdomain\t1\n" +
+		"#HttpOnly_api.example.com\tFALSE\t/pkg.Service\tFALSE\t0\tsession\tabc\r\n" +
+		"api.example.com\tFALSE\t/\tFALSE\t1\texpired\tx\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		test.Fatalf("WriteFile() error = %v", err)
+	}
+
+	jar := newCookieJar()
+	if err := jar.load(path); err != nil {
+		test.Fatalf("load() error = %v", err)
+	}
+	if got := cookieHeader(jar, "https://api.example.com/pkg.Service/Method"); got != "session=abc; domain=1" {
+		test.Errorf("cookies = %q", got)
+	}
+	if got := cookieHeader(jar, "http://www.example.com/"); got != "" {
+		test.Errorf("secure cookie sent over http: %q", got)
+	}
+
+	// Saving and loading again keeps the cookies and their attributes
+	saved := filepath.Join(dir, "saved.txt")
+	if err := jar.save(saved); err != nil {
+		test.Fatalf("save() error = %v", err)
+	}
+	data, _ := os.ReadFile(saved)
+	for _, want := range []string{
+		"# Netscape HTTP Cookie File",
+		".example.com\tTRUE\t/\tTRUE\t" + strconv.FormatInt(future, 10) + "\tdomain\t1\n",
+		"#HttpOnly_api.example.com\tFALSE\t/pkg.Service\tFALSE\t0\tsession\tabc\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			test.Errorf("saved jar %q does not contain %q", data, want)
+		}
+	}
+	if strings.Contains(string(data), "expired") {
+		test.Errorf("saved jar %q contains an expired cookie", data)
+	}
+
+	// A missing file is an empty jar
+	if err := newCookieJar().load(filepath.Join(dir, "missing.txt")); err != nil {
+		test.Errorf("load() of a missing file error = %v", err)
+	}
+	for _, bad := range []string{"example.com\tTRUE\t/\n", "example.com\tTRUE\t/\tFALSE\tsoon\tname\tvalue\n"} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if err := newCookieJar().load(path); err == nil {
+			test.Errorf("load(%q) expected error", bad)
+		}
+	}
+}
+
+func TestClientCookies(test *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Cookie")
+		if r.URL.Path == "/login.Service/Login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/", HttpOnly: true})
+		}
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+	jarFile := filepath.Join(test.TempDir(), "cookies.txt")
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, CookieJar: jarFile, Cookies: []string{"csrf=t1; theme=dark"}})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	for _, service := range []string{"login.Service", "test.Service"} {
+		if _, err := client.Invoke(context.Background(), &Request{Service: service, Method: "Login", Message: []byte{}}); err != nil {
+			test.Fatalf("Invoke() error = %v", err)
+		}
+	}
+	if got := <-received; got != "csrf=t1; theme=dark" {
+		test.Errorf("first call cookies = %q", got)
+	}
+	if got := <-received; got != "csrf=t1; theme=dark; session=s1" {
+		test.Errorf("second call cookies = %q, want the session cookie added", got)
+	}
+	if err := client.Close(); err != nil {
+		test.Fatalf("Close() error = %v", err)
+	}
+
+	// A new client starts with the saved session
+	client, err = NewClient(server.URL, &Options{Plaintext: true, CookieJar: jarFile})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if got := <-received; !strings.Contains(got, "session=s1") {
+		test.Errorf("cookies after reload = %q, want the saved session", got)
+	}
+
+	if _, err := NewClient(server.URL, &Options{Cookies: []string{"novalue"}}); err == nil {
+		test.Error("NewClient() expected error for a cookie without =")
+	}
+}
@@ -0,0 +1,484 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CORS response headers.
+const (
+	HeaderAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderExposeHeaders    = "Access-Control-Expose-Headers"
+)
+
+// CORS preflight request headers.
+const (
+	HeaderRequestMethod  = "Access-Control-Request-Method"
+	HeaderRequestHeaders = "Access-Control-Request-Headers"
+)
+
+// safelistedResponseHeaders are the response headers browsers always let
+// scripts read (the Fetch standard's CORS-safelisted response header names).
+var safelistedResponseHeaders = []string{
+	"Cache-Control",
+	"Content-Language",
+	"Content-Length",
+	"Content-Type",
+	"Expires",
+	"Last-Modified",
+	"Pragma",
+}
+
+// CORSError is a response a browser would block under its CORS policy.
+type CORSError struct {
+	Origin string
+	Reason string
+	Hint   string // How to fix the server's CORS configuration
+}
+
+// Error implements error.
+func (err *CORSError) Error() string {
+	return fmt.Sprintf("CORS: a browser at %s would block this response: %s", err.Origin, err.Reason)
+}
+
+// corsTransport makes requests as a page at origin would: it sends the Origin
+// header, fails responses a browser would block, and hides the response
+// headers a script could not read.
+type corsTransport struct {
+	base    http.RoundTripper
+	origin  string
+	verbose bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (transport *corsTransport) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	httpReq = httpReq.Clone(httpReq.Context())
+	httpReq.Header.Set("Origin", transport.origin)
+
+	httpResp, err := transport.base.RoundTrip(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cookies make the request credentialed, as with fetch's credentials:
+	// "include" or XHR's withCredentials
+	credentialed := httpReq.Header.Get("Cookie") != ""
+	if err := checkAllowOrigin(httpResp.Header, transport.origin, credentialed); err != nil {
+		httpResp.Body.Close()
+		return nil, err
+	}
+
+	if hidden := hideUnexposedHeaders(httpResp.Header, credentialed); len(hidden) > 0 && transport.verbose {
+		fmt.Printf("* [cors] hidden from scripts at %s (not in %s): %s\n",
+			transport.origin, HeaderExposeHeaders, strings.Join(hidden, ", "))
+	}
+	return httpResp, nil
+}
+
+// checkOrigin validates an origin: a scheme and host with an optional port,
+// or null.
+func checkOrigin(origin string) error {
+	if origin == "null" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("invalid origin %q: expected scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// checkAllowOrigin checks that a response's CORS headers let a page at origin
+// read it.
+func checkAllowOrigin(header http.Header, origin string, credentialed bool) error {
+	allowOrigin := header.Get(HeaderAllowOrigin)
+	switch {
+	case allowOrigin == "":
+		return &CORSError{
+			Origin: origin,
+			Reason: fmt.Sprintf("the response has no %s header", HeaderAllowOrigin),
+			Hint:   fmt.Sprintf("add %s to the server's allowed origins, so it sends %s: %s", origin, HeaderAllowOrigin, origin),
+		}
+	case allowOrigin == "*" && credentialed:
+		return &CORSError{
+			Origin: origin,
+			Reason: fmt.Sprintf("%s is * but the request sends cookies; it must be the origin", HeaderAllowOrigin),
+			Hint:   fmt.Sprintf("echo the request's Origin in %s instead of *", HeaderAllowOrigin),
+		}
+	case allowOrigin != "*" && allowOrigin != origin:
+		return &CORSError{
+			Origin: origin,
+			Reason: fmt.Sprintf("%s is %q, not the origin", HeaderAllowOrigin, allowOrigin),
+			Hint:   fmt.Sprintf("add %s to the server's allowed origins", origin),
+		}
+	case credentialed && header.Get(HeaderAllowCredentials) != "true":
+		return &CORSError{
+			Origin: origin,
+			Reason: fmt.Sprintf("the request sends cookies but %s is not true", HeaderAllowCredentials),
+			Hint:   fmt.Sprintf("send %s: true, or call without cookies", HeaderAllowCredentials),
+		}
+	}
+	return nil
+}
+
+// headerList returns the values of a comma-separated list header, such as
+// Access-Control-Allow-Headers.
+func headerList(header http.Header, name string) []string {
+	var values []string
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// containsFold reports whether values contains value, ignoring case.
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(item string) bool { return strings.EqualFold(item, value) })
+}
+
+// exposedHeaders returns the lower-case header names listed in a response's
+// Access-Control-Expose-Headers.
+func exposedHeaders(header http.Header) []string {
+	names := headerList(header, HeaderExposeHeaders)
+	for index, name := range names {
+		names[index] = strings.ToLower(name)
+	}
+	return names
+}
+
+// headerExposed reports whether scripts can read a response header. A *
+// exposes every header unless the request is credentialed.
+func headerExposed(name string, exposed []string, credentialed bool) bool {
+	name = strings.ToLower(name)
+	for _, safe := range safelistedResponseHeaders {
+		if strings.EqualFold(name, safe) {
+			return true
+		}
+	}
+	return slices.Contains(exposed, name) || !credentialed && slices.Contains(exposed, "*")
+}
+
+// hideUnexposedHeaders removes the response headers scripts cannot read and
+// returns their names in sorted order. Set-Cookie, which the browser handles
+// itself, and the CORS headers are kept.
+func hideUnexposedHeaders(header http.Header, credentialed bool) []string {
+	exposed := exposedHeaders(header)
+	var hidden []string
+	for name := range header {
+		if name == "Set-Cookie" || strings.HasPrefix(name, "Access-Control-") {
+			continue
+		}
+		if !headerExposed(name, exposed, credentialed) {
+			hidden = append(hidden, name)
+			header.Del(name)
+		}
+	}
+	slices.Sort(hidden)
+	return hidden
+}
+
+// safelistedMethods are the methods a preflight allows without
+// Access-Control-Allow-Methods listing them.
+var safelistedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// forbiddenRequestHeaders are the request headers scripts cannot set, so
+// browsers never ask a server about them; names starting with Proxy- or Sec-
+// are forbidden too. User-Agent is no longer forbidden by the Fetch standard,
+// but Chromium still drops it, which is why gRPC-Web sends X-User-Agent.
+var forbiddenRequestHeaders = []string{
+	"accept-charset", "accept-encoding", "access-control-request-headers", "access-control-request-method",
+	"connection", "content-length", "cookie", "cookie2", "date", "dnt", "expect", "host", "keep-alive",
+	"origin", "referer", "set-cookie", "te", "trailer", "transfer-encoding", "upgrade", "user-agent", "via",
+}
+
+// CORSCheck is the outcome of one check of a server's CORS configuration.
+type CORSCheck struct {
+	Name   string // The response or header checked
+	Passed bool
+	Detail string // What the server sent, or why the check failed
+	Hint   string // How to fix the server's configuration, for failed checks
+}
+
+// CORSReport is the result of CheckCORS.
+type CORSReport struct {
+	Origin         string
+	URL            string
+	Method         string   // Sent in Access-Control-Request-Method
+	RequestHeaders []string // Sent in Access-Control-Request-Headers
+	Credentialed   bool     // The call sends cookies
+	Called         bool     // The call was made after the preflight
+	Checks         []CORSCheck
+}
+
+// Passed reports whether every check passed.
+func (report *CORSReport) Passed() bool {
+	for _, check := range report.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// CORSCheckOptions configures CheckCORS.
+type CORSCheckOptions struct {
+	Streaming bool // The method is server streaming, which changes the Connect headers
+	Call      bool // Also make the call, to check Access-Control-Expose-Headers on its response
+}
+
+// CheckCORS checks that a page at the client's origin could make a call. It
+// sends the preflight a browser would send before the call's request, with
+// the same headers, and checks the server allows the origin, method and
+// headers. The call itself is only made if opts.Call is set, since it runs
+// the method for real: then, as the browser would, it checks the response is
+// readable and, for gRPC-Web, that scripts can read grpc-status and
+// grpc-message. Calls a browser would stop at the preflight are never made.
+//
+// The check runs through the client's interceptors like the call would, so
+// the preflight asks about the headers they add, such as traceparent.
+func (client *Client) CheckCORS(ctx context.Context, req *Request, opts CORSCheckOptions) (*CORSReport, error) {
+	if client.origin == "" {
+		return nil, fmt.Errorf("an origin is required to check CORS")
+	}
+
+	var report *CORSReport
+	check := func(ctx context.Context, req *Request) (*Response, error) {
+		var err error
+		report, err = client.checkCORS(ctx, req, opts)
+		return &Response{}, err
+	}
+	var err error
+	if opts.Streaming {
+		invoker := chainStreamInterceptors(client.streamInterceptors, func(ctx context.Context, req *Request, _ MessageSource, _ StreamHandler) (*Response, error) {
+			return check(ctx, req)
+		})
+		_, err = invoker(ctx, req, nil, nil)
+	} else {
+		_, err = chainUnaryInterceptors(client.unaryInterceptors, check)(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkCORS implements CheckCORS at the end of the interceptor chain.
+func (client *Client) checkCORS(ctx context.Context, req *Request, opts CORSCheckOptions) (*CORSReport, error) {
+	ctx, err := client.credentialsContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, _, err := client.newRequest(ctx, req, opts.Streaming)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the body stops its writer when the call is not made
+	if httpReq.Body != nil {
+		defer httpReq.Body.Close()
+	}
+
+	report := &CORSReport{
+		Origin:         client.origin,
+		URL:            httpReq.URL.String(),
+		Method:         httpReq.Method,
+		RequestHeaders: preflightHeaders(httpReq.Header),
+		Credentialed:   len(client.cookies.Cookies(httpReq.URL)) > 0,
+	}
+
+	// Preflights carry no cookies or credentials
+	preflight, err := http.NewRequestWithContext(ctx, http.MethodOptions, report.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preflight request: %w", err)
+	}
+	preflight.Host = httpReq.Host
+	preflight.Header.Set("Origin", client.origin)
+	preflight.Header.Set(HeaderRequestMethod, report.Method)
+	if len(report.RequestHeaders) > 0 {
+		preflight.Header.Set(HeaderRequestHeaders, strings.Join(report.RequestHeaders, ","))
+	}
+	httpResp, err := client.corsRoundTrip(preflight, nil)
+	if err != nil {
+		return nil, fmt.Errorf("preflight request failed: %w", err)
+	}
+	report.Checks = checkPreflight(httpResp, report)
+	if !opts.Call || !report.Passed() {
+		return report, nil
+	}
+
+	httpReq.Header.Set("Origin", client.origin)
+	if httpResp, err = client.corsRoundTrip(httpReq, client.cookies); err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	report.Called = true
+
+	// Connect reports errors in the body, so it needs no exposed headers
+	var statusHeaders []string
+	if client.protocol != ProtocolConnect {
+		statusHeaders = []string{"grpc-status", "grpc-message"}
+	}
+	report.Checks = append(report.Checks, checkCORSResponse(httpResp.Header, report, statusHeaders)...)
+	return report, nil
+}
+
+// corsRoundTrip sends a request with the client's transport, without the CORS
+// handling that would fail the responses to inspect, and closes the response
+// body, so streams end once their headers arrive.
+func (client *Client) corsRoundTrip(httpReq *http.Request, jar http.CookieJar) (*http.Response, error) {
+	if client.verbose {
+		client.logRequest(httpReq, nil)
+	}
+	httpClient := &http.Client{Transport: client.transport, Jar: jar, Timeout: client.timeout}
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Body.Close()
+	if client.verbose {
+		client.logResponse(httpResp)
+	}
+	return httpResp, nil
+}
+
+// preflightHeaders returns the sorted, lower-case names of the request headers
+// a browser asks the server about in a preflight: those that are neither
+// CORS-safelisted nor set by the browser itself.
+func preflightHeaders(header http.Header) []string {
+	var names []string
+	for name, values := range header {
+		name = strings.ToLower(name)
+		if slices.Contains(forbiddenRequestHeaders, name) || strings.HasPrefix(name, "proxy-") || strings.HasPrefix(name, "sec-") {
+			continue
+		}
+		if !corsSafelisted(name, values) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// corsSafelisted reports whether a request header can be sent without a
+// preflight. Content-Type only can with the media types an HTML form sends.
+func corsSafelisted(name string, values []string) bool {
+	switch name {
+	case "accept", "accept-language", "content-language":
+		return true
+	case "content-type":
+		mediaType, _, err := mime.ParseMediaType(strings.Join(values, ", "))
+		return err == nil && (mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" || mediaType == "text/plain")
+	}
+	return false
+}
+
+// checkPreflight checks a preflight response as a browser would.
+func checkPreflight(httpResp *http.Response, report *CORSReport) []CORSCheck {
+	status := CORSCheck{
+		Name:   "preflight status",
+		Passed: httpResp.StatusCode >= 200 && httpResp.StatusCode < 300,
+		Detail: httpResp.Status,
+	}
+	if !status.Passed {
+		status.Hint = "answer OPTIONS requests with 200 or 204 before they reach authentication or the gRPC service"
+	}
+	return []CORSCheck{
+		status,
+		checkOriginHeader("preflight "+HeaderAllowOrigin, httpResp.Header, report),
+		checkAllowMethods(httpResp.Header, report),
+		checkAllowHeaders(httpResp.Header, report),
+	}
+}
+
+// checkCORSResponse checks the response to the call as a browser would, and
+// that scripts can read the statusHeaders.
+func checkCORSResponse(header http.Header, report *CORSReport, statusHeaders []string) []CORSCheck {
+	origin := checkOriginHeader("response "+HeaderAllowOrigin, header, report)
+	if !origin.Passed {
+		origin.Hint += ", on every response and not only preflights"
+	}
+	checks := []CORSCheck{origin}
+	if len(statusHeaders) == 0 {
+		return checks
+	}
+
+	exposed := exposedHeaders(header)
+	var hidden []string
+	for _, name := range statusHeaders {
+		if !headerExposed(name, exposed, report.Credentialed) {
+			hidden = append(hidden, name)
+		}
+	}
+	expose := CORSCheck{Name: HeaderExposeHeaders, Passed: len(hidden) == 0, Detail: strings.Join(exposed, ", ")}
+	if len(hidden) > 0 {
+		expose.Detail = fmt.Sprintf("%s hidden from scripts, so errors in trailers-only responses cannot be read", strings.Join(hidden, " and "))
+		expose.Hint = fmt.Sprintf("add %s to %s", strings.Join(hidden, ", "), HeaderExposeHeaders)
+		if slices.Contains(exposed, "*") {
+			expose.Hint += "; * does not apply to requests with cookies"
+		}
+	}
+	return append(checks, expose)
+}
+
+// checkOriginHeader checks a response's Access-Control-Allow-Origin.
+func checkOriginHeader(name string, header http.Header, report *CORSReport) CORSCheck {
+	check := CORSCheck{Name: name, Passed: true, Detail: header.Get(HeaderAllowOrigin)}
+	if err, ok := checkAllowOrigin(header, report.Origin, report.Credentialed).(*CORSError); ok {
+		check.Passed, check.Detail, check.Hint = false, err.Reason, err.Hint
+	}
+	return check
+}
+
+// checkAllowMethods checks a preflight's Access-Control-Allow-Methods allows
+// the call's method.
+func checkAllowMethods(header http.Header, report *CORSReport) CORSCheck {
+	allowed := headerList(header, HeaderAllowMethods)
+	check := CORSCheck{Name: HeaderAllowMethods, Passed: true, Detail: strings.Join(allowed, ", ")}
+	switch {
+	case containsFold(allowed, report.Method):
+	case slices.Contains(allowed, "*") && !report.Credentialed:
+	case slices.Contains(safelistedMethods, report.Method):
+		check.Detail = fmt.Sprintf("%s need not be listed: it is CORS-safelisted", report.Method)
+	default:
+		check.Passed = false
+		check.Detail = fmt.Sprintf("%s is not allowed", report.Method)
+		check.Hint = fmt.Sprintf("add %s to %s", report.Method, HeaderAllowMethods)
+	}
+	return check
+}
+
+// checkAllowHeaders checks a preflight's Access-Control-Allow-Headers allows
+// every header the call sends. A * allows any header but Authorization, and
+// only in requests without cookies.
+func checkAllowHeaders(header http.Header, report *CORSReport) CORSCheck {
+	allowed := headerList(header, HeaderAllowHeaders)
+	wildcard := slices.Contains(allowed, "*") && !report.Credentialed
+
+	var missing []string
+	for _, name := range report.RequestHeaders {
+		if !containsFold(allowed, name) && (!wildcard || name == "authorization") {
+			missing = append(missing, name)
+		}
+	}
+
+	check := CORSCheck{Name: HeaderAllowHeaders, Passed: len(missing) == 0, Detail: strings.Join(allowed, ", ")}
+	if len(missing) > 0 {
+		check.Detail = "does not allow " + strings.Join(missing, ", ")
+		check.Hint = fmt.Sprintf("add %s to %s", strings.Join(missing, ", "), HeaderAllowHeaders)
+		if slices.Contains(allowed, "*") {
+			check.Hint += "; * does not cover authorization or requests with cookies"
+		}
+	}
+	return check
+}
@@ -0,0 +1,453 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+func TestCheckAllowOrigin(test *testing.T) {
+	const origin = "https://app.example.com"
+
+	tests := []struct {
+		name         string
+		header       http.Header
+		credentialed bool
+		wantErr      string
+	}{
+		{name: "matching origin", header: http.Header{HeaderAllowOrigin: {origin}}},
+		{name: "any origin", header: http.Header{HeaderAllowOrigin: {"*"}}},
+		{name: "missing", header: http.Header{}, wantErr: "no Access-Control-Allow-Origin"},
+		{name: "other origin", header: http.Header{HeaderAllowOrigin: {"https://other.example.com"}}, wantErr: "not the origin"},
+		{
+			name:         "credentialed with any origin",
+			header:       http.Header{HeaderAllowOrigin: {"*"}, HeaderAllowCredentials: {"true"}},
+			credentialed: true,
+			wantErr:      "sends cookies",
+		},
+		{
+			name:         "credentialed without allow credentials",
+			header:       http.Header{HeaderAllowOrigin: {origin}},
+			credentialed: true,
+			wantErr:      "Access-Control-Allow-Credentials is not true",
+		},
+		{
+			name:         "credentialed",
+			header:       http.Header{HeaderAllowOrigin: {origin}, HeaderAllowCredentials: {"true"}},
+			credentialed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			err := checkAllowOrigin(tt.header, origin, tt.credentialed)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkAllowOrigin() error = %v", err)
+				}
+				return
+			}
+			var corsErr *CORSError
+			if !errors.As(err, &corsErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkAllowOrigin() error = %v, want a CORSError containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHideUnexposedHeaders(test *testing.T) {
+	tests := []struct {
+		name         string
+		expose       string
+		credentialed bool
+		wantHidden   []string
+	}{
+		{name: "nothing exposed", wantHidden: []string{"Grpc-Message", "Grpc-Status", "X-Custom"}},
+		{name: "listed headers", expose: "grpc-status, Grpc-Message", wantHidden: []string{"X-Custom"}},
+		{name: "wildcard", expose: "*"},
+		{name: "wildcard does not apply to credentialed requests", expose: "*", credentialed: true, wantHidden: []string{"Grpc-Message", "Grpc-Status", "X-Custom"}},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			header := http.Header{
+				"Content-Type":    {protocol.ContentTypeGRPCWeb},
+				"Grpc-Status":     {"0"},
+				"Grpc-Message":    {"ok"},
+				"X-Custom":        {"1"},
+				"Set-Cookie":      {"session=abc"},
+				HeaderAllowOrigin: {"*"},
+			}
+			if tt.expose != "" {
+				header.Set(HeaderExposeHeaders, tt.expose)
+			}
+
+			hidden := hideUnexposedHeaders(header, tt.credentialed)
+			if !slices.Equal(hidden, tt.wantHidden) {
+				t.Errorf("hidden = %v, want %v", hidden, tt.wantHidden)
+			}
+			for _, name := range tt.wantHidden {
+				if header.Get(name) != "" {
+					t.Errorf("header %s was not removed", name)
+				}
+			}
+			for _, name := range []string{"Content-Type", "Set-Cookie", HeaderAllowOrigin} {
+				if header.Get(name) == "" {
+					t.Errorf("header %s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestClientInvokeOrigin(test *testing.T) {
+	const origin = "https://app.example.com"
+
+	tests := []struct {
+		name     string
+		headers  http.Header // CORS headers of the response
+		status   string      // grpc-status header of a trailers-only response
+		cookies  []string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name:     "allowed",
+			headers:  http.Header{HeaderAllowOrigin: {origin}},
+			wantCode: protocol.StatusOK,
+		},
+		{
+			name:    "blocked and not retried",
+			headers: http.Header{},
+			wantErr: "no Access-Control-Allow-Origin",
+		},
+		{
+			name:    "cookies need allow credentials",
+			headers: http.Header{HeaderAllowOrigin: {origin}},
+			cookies: []string{"session=abc"},
+			wantErr: "Access-Control-Allow-Credentials",
+		},
+		{
+			name:     "exposed trailers-only status",
+			headers:  http.Header{HeaderAllowOrigin: {"*"}, HeaderExposeHeaders: {"grpc-status, grpc-message"}},
+			status:   "7",
+			wantCode: protocol.StatusPermissionDenied,
+		},
+		{
+			// The browser cannot read the status, so the call fails as it
+			// would in grpc-web
+			name:     "hidden trailers-only status",
+			headers:  http.Header{HeaderAllowOrigin: {"*"}},
+			status:   "7",
+			wantCode: protocol.StatusInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if got := r.Header.Get("Origin"); got != origin {
+					t.Errorf("Origin = %q, want %q", got, origin)
+				}
+				for name, values := range tt.headers {
+					w.Header()[name] = values
+				}
+				if tt.status != "" {
+					w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+					w.Header().Set("Grpc-Status", tt.status)
+					return
+				}
+				grpcWebOK(w, r)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, &Options{
+				Plaintext: true,
+				Origin:    origin,
+				Cookies:   tt.cookies,
+				Retry:     DefaultRetryPolicy(3),
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			resp, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}})
+			if tt.wantErr != "" {
+				var corsErr *CORSError
+				if !errors.As(err, &corsErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Invoke() error = %v, want a CORSError containing %q", err, tt.wantErr)
+				}
+				if got := requests.Load(); got != 1 {
+					t.Errorf("requests = %d, want 1", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+			if resp.Status.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", resp.Status.Code, tt.wantCode)
+			}
+		})
+	}
+
+	for _, bad := range []string{"app.example.com", "https://app.example.com/path", "https://"} {
+		if _, err := NewClient("https://api.example.com", &Options{Origin: bad}); err == nil {
+			test.Errorf("NewClient() expected error for origin %q", bad)
+		}
+	}
+}
+
+func TestPreflightHeaders(test *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []string
+	}{
+		{
+			name: "gRPC-Web request",
+			header: http.Header{
+				"Content-Type":         {protocol.ContentTypeGRPCWeb},
+				"Accept":               {protocol.ContentTypeGRPCWeb},
+				"X-Grpc-Web":           {"1"},
+				"X-User-Agent":         {"grpcwebcurl"},
+				"Grpc-Timeout":         {"30S"},
+				"Grpc-Accept-Encoding": {"gzip"},
+				"Authorization":        {"Bearer token"},
+			},
+			want: []string{"authorization", "content-type", "grpc-accept-encoding", "grpc-timeout", "x-grpc-web", "x-user-agent"},
+		},
+		{
+			name: "headers set by the browser",
+			header: http.Header{
+				"User-Agent":       {"grpcwebcurl"},
+				"Accept-Encoding":  {"gzip"},
+				"Cookie":           {"session=abc"},
+				"Sec-Fetch-Mode":   {"cors"},
+				"Proxy-Connection": {"keep-alive"},
+			},
+		},
+		{
+			name:   "form content type is safelisted",
+			header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "Accept-Language": {"en"}},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			if got := preflightHeaders(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("preflightHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCheckCORS(test *testing.T) {
+	const origin = "https://app.example.com"
+	allowAll := http.Header{
+		HeaderAllowOrigin:  {origin},
+		HeaderAllowMethods: {"POST, OPTIONS"},
+		HeaderAllowHeaders: {"content-type, x-grpc-web, x-user-agent, grpc-timeout, grpc-accept-encoding, x-tenant"},
+	}
+
+	tests := []struct {
+		name       string
+		preflight  http.Header
+		status     int         // Preflight status (default: 204)
+		response   http.Header // CORS headers of the call's response
+		protocol   string
+		cookies    []string
+		options    CORSCheckOptions
+		wantFailed map[string]string // Failed check name to a hint substring
+		wantCalled bool
+	}{
+		{
+			name:       "allowed",
+			preflight:  allowAll,
+			response:   http.Header{HeaderAllowOrigin: {origin}, HeaderExposeHeaders: {"grpc-status, grpc-message"}},
+			options:    CORSCheckOptions{Call: true},
+			wantCalled: true,
+		},
+		{
+			name:      "preflight only by default",
+			preflight: allowAll,
+		},
+		{
+			name:      "missing gRPC-Web headers",
+			preflight: http.Header{HeaderAllowOrigin: {"*"}, HeaderAllowHeaders: {"content-type, x-tenant"}},
+			wantFailed: map[string]string{
+				HeaderAllowHeaders: "add grpc-accept-encoding, grpc-timeout, x-grpc-web, x-user-agent to " + HeaderAllowHeaders,
+			},
+		},
+		{
+			name:       "preflight rejected",
+			preflight:  http.Header{},
+			status:     http.StatusUnauthorized,
+			options:    CORSCheckOptions{Call: true},
+			wantFailed: map[string]string{"preflight status": "OPTIONS", "preflight " + HeaderAllowOrigin: "allowed origins", HeaderAllowHeaders: "x-grpc-web"},
+		},
+		{
+			name:       "status not exposed",
+			preflight:  allowAll,
+			response:   http.Header{HeaderAllowOrigin: {origin}, HeaderExposeHeaders: {"grpc-status"}},
+			wantFailed: map[string]string{HeaderExposeHeaders: "add grpc-message to " + HeaderExposeHeaders},
+			options:    CORSCheckOptions{Call: true},
+			wantCalled: true,
+		},
+		{
+			name:       "response without allow origin",
+			preflight:  allowAll,
+			response:   http.Header{HeaderExposeHeaders: {"*"}},
+			wantFailed: map[string]string{"response " + HeaderAllowOrigin: "not only preflights"},
+			options:    CORSCheckOptions{Call: true},
+			wantCalled: true,
+		},
+		{
+			name:      "wildcards do not cover requests with cookies",
+			preflight: http.Header{HeaderAllowOrigin: {"*"}, HeaderAllowMethods: {"*"}, HeaderAllowHeaders: {"*"}},
+			cookies:   []string{"session=abc"},
+			wantFailed: map[string]string{
+				"preflight " + HeaderAllowOrigin: "instead of *",
+				HeaderAllowHeaders:               "* does not cover",
+			},
+		},
+		{
+			name:       "Connect needs no exposed headers",
+			preflight:  http.Header{HeaderAllowOrigin: {origin}, HeaderAllowHeaders: {"content-type, connect-protocol-version, connect-timeout-ms, x-tenant"}},
+			response:   http.Header{HeaderAllowOrigin: {origin}},
+			protocol:   ProtocolConnect,
+			options:    CORSCheckOptions{Call: true},
+			wantCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			var called atomic.Bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Origin"); got != origin {
+					t.Errorf("Origin = %q, want %q", got, origin)
+				}
+				if r.Method == http.MethodOptions {
+					if r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != "" {
+						t.Error("preflight sent cookies or credentials")
+					}
+					if got := r.Header.Get(HeaderRequestMethod); got != http.MethodPost {
+						t.Errorf("%s = %q", HeaderRequestMethod, got)
+					}
+					for name, values := range tt.preflight {
+						w.Header()[name] = values
+					}
+					status := tt.status
+					if status == 0 {
+						status = http.StatusNoContent
+					}
+					w.WriteHeader(status)
+					return
+				}
+				called.Store(true)
+				for name, values := range tt.response {
+					w.Header()[name] = values
+				}
+				if tt.protocol == ProtocolConnect {
+					w.Header().Set("Content-Type", "application/proto")
+					return
+				}
+				grpcWebOK(w, r)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, &Options{Plaintext: true, Origin: origin, Cookies: tt.cookies, Protocol: tt.protocol, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			client.SetHeader("X-Tenant", "acme")
+			report, err := client.CheckCORS(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}, tt.options)
+			if err != nil {
+				t.Fatalf("CheckCORS() error = %v", err)
+			}
+
+			if !slices.Contains(report.RequestHeaders, "x-tenant") {
+				t.Errorf("RequestHeaders = %v, want the custom header", report.RequestHeaders)
+			}
+			failed := map[string]bool{}
+			for _, check := range report.Checks {
+				if check.Passed {
+					continue
+				}
+				failed[check.Name] = true
+				if want, ok := tt.wantFailed[check.Name]; !ok || !strings.Contains(check.Hint, want) {
+					t.Errorf("check %s failed with hint %q, want a hint containing %q", check.Name, check.Hint, want)
+				}
+			}
+			for name := range tt.wantFailed {
+				if !failed[name] {
+					t.Errorf("check %s passed, want it to fail", name)
+				}
+			}
+			if report.Passed() != (len(tt.wantFailed) == 0) {
+				t.Errorf("Passed() = %v", report.Passed())
+			}
+			if report.Called != tt.wantCalled || called.Load() != tt.wantCalled {
+				t.Errorf("Called = %v, server called = %v, want %v", report.Called, called.Load(), tt.wantCalled)
+			}
+		})
+	}
+
+	client, _ := NewClient("http://127.0.0.1:1", &Options{Plaintext: true})
+	if _, err := client.CheckCORS(context.Background(), &Request{Service: "test.Service", Method: "Method"}, CORSCheckOptions{}); err == nil {
+		test.Error("CheckCORS() expected error without an origin")
+	}
+}
+
+func TestClientCheckCORSTracing(test *testing.T) {
+	// The preflight asks about the trace context headers tracing adds to calls
+	const origin = "https://app.example.com"
+	preflights := make(chan http.Header, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preflights <- r.Header.Clone()
+		w.Header().Set(HeaderAllowOrigin, origin)
+		w.Header().Set(HeaderAllowHeaders, "*")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	client, err := NewClient(server.URL, &Options{
+		Plaintext:      true,
+		Origin:         origin,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		TraceBinary:    true,
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	for _, streaming := range []bool{false, true} {
+		report, err := client.CheckCORS(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}, CORSCheckOptions{Streaming: streaming})
+		if err != nil {
+			test.Fatalf("CheckCORS() error = %v", err)
+		}
+		requested := strings.Split((<-preflights).Get(HeaderRequestHeaders), ",")
+		for _, name := range []string{"traceparent", HeaderTraceBin} {
+			if !slices.Contains(report.RequestHeaders, name) || !slices.Contains(requested, name) {
+				test.Errorf("streaming %v: preflight asked about %v, want %s", streaming, requested, name)
+			}
+		}
+	}
+	if spans := exporter.GetSpans(); len(spans) != 2 {
+		test.Errorf("recorded %d spans, want one per check", len(spans))
+	}
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// tokenExpiryDelta is how long before its expiry a cached token is replaced,
+// so it does not expire while a call is in flight.
+const tokenExpiryDelta = 10 * time.Second
+
+// PerRPCCredentials supplies authentication metadata for each call, like
+// gRPC's credentials.PerRPCCredentials.
+type PerRPCCredentials interface {
+	// GetRequestMetadata returns the headers to send with a call to uri, the
+	// base URL and service of the call.
+	GetRequestMetadata(ctx context.Context, uri string) (map[string]string, error)
+
+	// RequireTransportSecurity reports whether the credentials must only be
+	// sent over TLS.
+	RequireTransportSecurity() bool
+}
+
+// RefreshableCredentials are credentials that cache a token. A call rejected
+// with UNAUTHENTICATED invalidates the token and is retried once with a new
+// one.
+type RefreshableCredentials interface {
+	PerRPCCredentials
+
+	// Invalidate discards the cached token so the next call fetches a new one.
+	Invalidate()
+}
+
+// httpCredentials are credentials that make HTTP requests of their own, such
+// as to an OAuth2 token endpoint. NewClient gives them an HTTP client that
+// connects the way its calls do.
+type httpCredentials interface {
+	setHTTPClient(httpClient *http.Client)
+}
+
+// credentialsKey is the context key for the credentials metadata of an
+// attempt.
+type credentialsKey struct{}
+
+// withCredentials gets the credentials metadata before each attempt and passes
+// it to applyHeaders in the context. An attempt rejected with UNAUTHENTICATED
+// before it commits is repeated once with refreshed credentials.
+func (client *Client) withCredentials(req *Request, attempt attemptFunc) attemptFunc {
+	if client.credentials == nil {
+		return attempt
+	}
+
+	return func(ctx context.Context, commit func() bool) (*Response, error) {
+		committed := false
+		trackCommit := func() bool {
+			committed = true
+			return commit()
+		}
+
+		refreshable, canRefresh := client.credentials.(RefreshableCredentials)
+		for refreshed := false; ; refreshed = true {
+			attemptCtx, err := client.credentialsContext(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := attempt(attemptCtx, trackCommit)
+			if err != nil || resp.Status.Code != protocol.StatusUnauthenticated || !canRefresh || refreshed || committed {
+				return resp, err
+			}
+
+			if client.verbose {
+				fmt.Printf("* [credentials] UNAUTHENTICATED: %s; refreshing credentials and retrying\n", resp.Status.Message)
+			}
+			refreshable.Invalidate()
+		}
+	}
+}
+
+// credentialsContext returns ctx carrying the credentials metadata for a call,
+// or ctx itself without credentials.
+func (client *Client) credentialsContext(ctx context.Context, req *Request) (context.Context, error) {
+	if client.credentials == nil {
+		return ctx, nil
+	}
+	metadata, err := client.credentials.GetRequestMetadata(ctx, client.baseURL+"/"+req.Service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	return context.WithValue(ctx, credentialsKey{}, metadata), nil
+}
+
+// staticCredentials sends a bearer token that is read on every call, so
+// rotated token files and changed environment variables take effect.
+type staticCredentials struct {
+	token func() (string, error)
+}
+
+// NewStaticCredentials returns credentials that send token as a bearer token.
+func NewStaticCredentials(token string) PerRPCCredentials {
+	return &staticCredentials{token: func() (string, error) { return token, nil }}
+}
+
+// NewTokenFileCredentials returns credentials that send the bearer token in
+// path, read on every call. Surrounding whitespace is ignored.
+func NewTokenFileCredentials(path string) PerRPCCredentials {
+	return &staticCredentials{token: func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}}
+}
+
+// NewTokenEnvCredentials returns credentials that send the bearer token in the
+// environment variable name.
+func NewTokenEnvCredentials(name string) PerRPCCredentials {
+	return &staticCredentials{token: func() (string, error) {
+		return strings.TrimSpace(os.Getenv(name)), nil
+	}}
+}
+
+// GetRequestMetadata implements PerRPCCredentials.
+func (credentials *staticCredentials) GetRequestMetadata(ctx context.Context, uri string) (map[string]string, error) {
+	token, err := credentials.token()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New("empty token")
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity implements PerRPCCredentials. A token given
+// explicitly may be sent in plaintext, as with -H.
+func (credentials *staticCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// OAuth2Config configures the OAuth2 token endpoint for client credentials
+// and refresh token grants.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string // Sent as the audience parameter that some providers require
+
+	// AuthInBody sends the client ID and secret as form parameters instead of
+	// with HTTP Basic authentication.
+	AuthInBody bool
+
+	// HTTPClient makes the token requests. If nil, NewClient sets a client
+	// with its proxy, resolver, socket and TLS options, so token requests
+	// take the same path as calls; credentials used without a Client fall
+	// back to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// oauth2Credentials fetches access tokens from an OAuth2 token endpoint and
+// caches them until shortly before they expire.
+type oauth2Credentials struct {
+	config    OAuth2Config
+	grantType string
+
+	mu           sync.Mutex
+	refreshToken string
+	tokenType    string
+	accessToken  string
+	expiry       time.Time // Zero if the token does not expire
+}
+
+// tokenResponse is a token endpoint response, successful or not (RFC 6749
+// sections 5.1 and 5.2).
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	RefreshToken     string      `json:"refresh_token"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// NewClientCredentials returns credentials that get access tokens with the
+// OAuth2 client credentials grant.
+func NewClientCredentials(config OAuth2Config) (RefreshableCredentials, error) {
+	if config.TokenURL == "" || config.ClientID == "" {
+		return nil, errors.New("client credentials require a token URL and client ID")
+	}
+	return &oauth2Credentials{config: config, grantType: "client_credentials"}, nil
+}
+
+// NewRefreshTokenCredentials returns credentials that get access tokens with
+// the OAuth2 refresh token grant. A new refresh token issued by the server
+// replaces refreshToken.
+func NewRefreshTokenCredentials(config OAuth2Config, refreshToken string) (RefreshableCredentials, error) {
+	if config.TokenURL == "" || refreshToken == "" {
+		return nil, errors.New("refresh token credentials require a token URL and refresh token")
+	}
+	return &oauth2Credentials{config: config, grantType: "refresh_token", refreshToken: refreshToken}, nil
+}
+
+// GetRequestMetadata implements PerRPCCredentials, fetching a new access token
+// if there is no valid cached one.
+func (credentials *oauth2Credentials) GetRequestMetadata(ctx context.Context, uri string) (map[string]string, error) {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+
+	if credentials.accessToken == "" || (!credentials.expiry.IsZero() && time.Now().After(credentials.expiry)) {
+		if err := credentials.fetchToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"authorization": credentials.tokenType + " " + credentials.accessToken}, nil
+}
+
+// RequireTransportSecurity implements PerRPCCredentials.
+func (credentials *oauth2Credentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// setHTTPClient implements httpCredentials, keeping an HTTP client set in the
+// config.
+func (credentials *oauth2Credentials) setHTTPClient(httpClient *http.Client) {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+	if credentials.config.HTTPClient == nil {
+		credentials.config.HTTPClient = httpClient
+	}
+}
+
+// Invalidate implements RefreshableCredentials.
+func (credentials *oauth2Credentials) Invalidate() {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+	credentials.accessToken = ""
+}
+
+// fetchToken requests an access token from the token endpoint and caches it.
+func (credentials *oauth2Credentials) fetchToken(ctx context.Context) error {
+	config := credentials.config
+	form := url.Values{"grant_type": {credentials.grantType}}
+	if credentials.grantType == "refresh_token" {
+		form.Set("refresh_token", credentials.refreshToken)
+	}
+	if len(config.Scopes) > 0 {
+		form.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	if config.Audience != "" {
+		form.Set("audience", config.Audience)
+	}
+	// Public clients without a secret identify themselves in the body
+	if config.ClientID != "" && (config.AuthInBody || config.ClientSecret == "") {
+		form.Set("client_id", config.ClientID)
+		if config.ClientSecret != "" {
+			form.Set("client_secret", config.ClientSecret)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" && !config.AuthInBody {
+		httpReq.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+	var token tokenResponse
+	jsonErr := json.Unmarshal(body, &token)
+
+	switch {
+	case token.Error != "":
+		message := token.Error
+		if token.ErrorDescription != "" {
+			message += ": " + token.ErrorDescription
+		}
+		return fmt.Errorf("token endpoint returned %s: %s", httpResp.Status, message)
+	case httpResp.StatusCode != http.StatusOK:
+		return fmt.Errorf("token endpoint returned %s", httpResp.Status)
+	case jsonErr != nil:
+		return fmt.Errorf("invalid token response: %w", jsonErr)
+	case token.AccessToken == "":
+		return errors.New("token response has no access_token")
+	}
+
+	credentials.accessToken = token.AccessToken
+	credentials.tokenType = "Bearer"
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		credentials.tokenType = token.TokenType
+	}
+	credentials.expiry = time.Time{}
+	if seconds, err := token.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		credentials.expiry = time.Now().Add(time.Duration(seconds)*time.Second - tokenExpiryDelta)
+	}
+	if token.RefreshToken != "" && credentials.grantType == "refresh_token" {
+		credentials.refreshToken = token.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+func TestStaticCredentials(test *testing.T) {
+	tokenFile := filepath.Join(test.TempDir(), "token")
+	test.Setenv("TEST_GRPCWEBCURL_TOKEN", " env-token\n")
+	test.Setenv("TEST_GRPCWEBCURL_EMPTY", "")
+
+	tests := []struct {
+		name        string
+		credentials PerRPCCredentials
+		setup       func()
+		want        string
+		wantErr     bool
+	}{
+		{name: "static", credentials: NewStaticCredentials("token"), want: "Bearer token"},
+		{name: "empty static", credentials: NewStaticCredentials(""), wantErr: true},
+		{name: "environment", credentials: NewTokenEnvCredentials("TEST_GRPCWEBCURL_TOKEN"), want: "Bearer env-token"},
+		{name: "empty environment", credentials: NewTokenEnvCredentials("TEST_GRPCWEBCURL_EMPTY"), wantErr: true},
+		{
+			name:        "file",
+			credentials: NewTokenFileCredentials(tokenFile),
+			setup:       func() { os.WriteFile(tokenFile, []byte("file-token\n"), 0o600) },
+			want:        "Bearer file-token",
+		},
+		{
+			name:        "rotated file",
+			credentials: NewTokenFileCredentials(tokenFile),
+			setup:       func() { os.WriteFile(tokenFile, []byte("rotated-token"), 0o600) },
+			want:        "Bearer rotated-token",
+		},
+		{name: "missing file", credentials: NewTokenFileCredentials(tokenFile + ".missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			metadata, err := tt.credentials.GetRequestMetadata(context.Background(), "https://api.example.com/test.Service")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetRequestMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := metadata["authorization"]; got != tt.want {
+				t.Errorf("authorization = %q, want %q", got, tt.want)
+			}
+			if tt.credentials.RequireTransportSecurity() {
+				t.Error("RequireTransportSecurity() = true for a static token")
+			}
+		})
+	}
+}
+
+// tokenEndpoint is a test OAuth2 token endpoint that issues token-1, token-2
+// and so on, and records each token request's form and Basic credentials.
+type tokenEndpoint struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	response map[string]any
+	status   int
+}
+
+func newTokenEndpoint(test *testing.T, response map[string]any) (*tokenEndpoint, *httptest.Server) {
+	endpoint := &tokenEndpoint{response: response, status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		endpoint.mu.Lock()
+		endpoint.requests = append(endpoint.requests, r)
+		count := len(endpoint.requests)
+		endpoint.mu.Unlock()
+
+		body := map[string]any{"access_token": "token-" + strconv.Itoa(count), "token_type": "bearer", "expires_in": 3600}
+		for key, value := range endpoint.response {
+			if value == nil {
+				delete(body, key)
+			} else {
+				body[key] = value
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(endpoint.status)
+		json.NewEncoder(w).Encode(body)
+	}))
+	test.Cleanup(server.Close)
+	return endpoint, server
+}
+
+// count returns the number of token requests.
+func (endpoint *tokenEndpoint) count() int {
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	return len(endpoint.requests)
+}
+
+func TestOAuth2Credentials(test *testing.T) {
+	tests := []struct {
+		name        string
+		response    map[string]any
+		status      int
+		config      OAuth2Config
+		refresh     string
+		wantAuth    []string
+		wantFetches int
+		check       func(t *testing.T, requests []*http.Request)
+		wantErr     string
+	}{
+		{
+			name:        "client credentials with Basic authentication",
+			config:      OAuth2Config{ClientID: "client id", ClientSecret: "s&cret", Scopes: []string{"read", "write"}, Audience: "api"},
+			wantAuth:    []string{"Bearer token-1", "Bearer token-1"},
+			wantFetches: 1,
+			check: func(t *testing.T, requests []*http.Request) {
+				form := requests[0].PostForm
+				if form.Get("grant_type") != "client_credentials" || form.Get("scope") != "read write" || form.Get("audience") != "api" {
+					t.Errorf("form = %v", form)
+				}
+				if form.Has("client_secret") {
+					t.Error("client secret sent in the body")
+				}
+				id, secret, _ := requests[0].BasicAuth()
+				if id != "client+id" || secret != "s%26cret" {
+					t.Errorf("Basic credentials = %q, %q, want URL-encoded client id and secret", id, secret)
+				}
+			},
+		},
+		{
+			name:        "client credentials in the body",
+			config:      OAuth2Config{ClientID: "client", ClientSecret: "secret", AuthInBody: true},
+			wantAuth:    []string{"Bearer token-1"},
+			wantFetches: 1,
+			check: func(t *testing.T, requests []*http.Request) {
+				form := requests[0].PostForm
+				if form.Get("client_id") != "client" || form.Get("client_secret") != "secret" {
+					t.Errorf("form = %v", form)
+				}
+				if _, _, ok := requests[0].BasicAuth(); ok {
+					t.Error("Basic credentials sent with AuthInBody")
+				}
+			},
+		},
+		{
+			name:        "refresh token is rotated",
+			response:    map[string]any{"refresh_token": "refresh-2", "expires_in": "5"},
+			config:      OAuth2Config{ClientID: "public"},
+			refresh:     "refresh-1",
+			wantAuth:    []string{"Bearer token-1", "Bearer token-2"},
+			wantFetches: 2,
+			check: func(t *testing.T, requests []*http.Request) {
+				for index, want := range []string{"refresh-1", "refresh-2"} {
+					form := requests[index].PostForm
+					if form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != want || form.Get("client_id") != "public" {
+						t.Errorf("request %d form = %v", index, form)
+					}
+				}
+			},
+		},
+		{
+			name:        "token without expiry is cached",
+			response:    map[string]any{"expires_in": nil, "token_type": "MAC"},
+			config:      OAuth2Config{ClientID: "client", ClientSecret: "secret"},
+			wantAuth:    []string{"MAC token-1", "MAC token-1"},
+			wantFetches: 1,
+		},
+		{
+			name:     "error response",
+			response: map[string]any{"error": "invalid_client", "error_description": "unknown client"},
+			status:   http.StatusUnauthorized,
+			config:   OAuth2Config{ClientID: "client", ClientSecret: "secret"},
+			wantErr:  "401 Unauthorized: invalid_client: unknown client",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusBadGateway,
+			config:  OAuth2Config{ClientID: "client", ClientSecret: "secret"},
+			wantErr: "502 Bad Gateway",
+		},
+		{
+			name:     "missing access token",
+			response: map[string]any{"access_token": nil},
+			config:   OAuth2Config{ClientID: "client", ClientSecret: "secret"},
+			wantErr:  "no access_token",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			endpoint, server := newTokenEndpoint(t, tt.response)
+			if tt.status != 0 {
+				endpoint.status = tt.status
+			}
+
+			config := tt.config
+			config.TokenURL = server.URL
+			var credentials RefreshableCredentials
+			var err error
+			if tt.refresh != "" {
+				credentials, err = NewRefreshTokenCredentials(config, tt.refresh)
+			} else {
+				credentials, err = NewClientCredentials(config)
+			}
+			if err != nil {
+				t.Fatalf("new credentials error = %v", err)
+			}
+
+			if tt.wantErr != "" {
+				_, err := credentials.GetRequestMetadata(context.Background(), server.URL)
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GetRequestMetadata() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			for index, want := range tt.wantAuth {
+				metadata, err := credentials.GetRequestMetadata(context.Background(), server.URL)
+				if err != nil {
+					t.Fatalf("GetRequestMetadata() error = %v", err)
+				}
+				if got := metadata["authorization"]; got != want {
+					t.Errorf("call %d authorization = %q, want %q", index, got, want)
+				}
+			}
+			if got := endpoint.count(); got != tt.wantFetches {
+				t.Errorf("token requests = %d, want %d", got, tt.wantFetches)
+			}
+			if tt.check != nil {
+				tt.check(t, endpoint.requests)
+			}
+
+			// Invalidating always fetches a new token
+			credentials.Invalidate()
+			if _, err := credentials.GetRequestMetadata(context.Background(), server.URL); err != nil {
+				t.Fatalf("GetRequestMetadata() after Invalidate error = %v", err)
+			}
+			if got := endpoint.count(); got != tt.wantFetches+1 {
+				t.Errorf("token requests after Invalidate = %d, want %d", got, tt.wantFetches+1)
+			}
+		})
+	}
+
+	if _, err := NewClientCredentials(OAuth2Config{TokenURL: "https://auth.example.com/token"}); err == nil {
+		test.Error("NewClientCredentials() expected error without a client ID")
+	}
+	if _, err := NewRefreshTokenCredentials(OAuth2Config{TokenURL: "https://auth.example.com/token"}, ""); err == nil {
+		test.Error("NewRefreshTokenCredentials() expected error without a refresh token")
+	}
+}
+
+func TestClientInvokeCredentials(test *testing.T) {
+	tests := []struct {
+		name         string
+		accept       string // Token the server accepts
+		static       bool
+		stream       bool
+		wantCode     int
+		wantFetches  int
+		wantRequests int
+	}{
+		{name: "valid token", accept: "token-1", wantCode: protocol.StatusOK, wantFetches: 1, wantRequests: 1},
+		{name: "refreshed once", accept: "token-2", wantCode: protocol.StatusOK, wantFetches: 2, wantRequests: 2},
+		{name: "refreshed once for streams", accept: "token-2", stream: true, wantCode: protocol.StatusOK, wantFetches: 2, wantRequests: 2},
+		{name: "only one refresh", accept: "token-3", wantCode: protocol.StatusUnauthenticated, wantFetches: 2, wantRequests: 2},
+		{name: "static token is not refreshed", accept: "other", static: true, wantCode: protocol.StatusUnauthenticated, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			endpoint, tokenServer := newTokenEndpoint(t, nil)
+			var mu sync.Mutex
+			var requests int
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				if r.Header.Get("Authorization") != "Bearer "+tt.accept {
+					header := w.Header()
+					header.Set("Content-Type", protocol.ContentTypeGRPCWeb)
+					header.Set("Grpc-Status", "16")
+					header.Set("Grpc-Message", "invalid token")
+					return
+				}
+				grpcWebOK(w, r)
+			}))
+			defer server.Close()
+
+			var credentials PerRPCCredentials = NewStaticCredentials("token-1")
+			if !tt.static {
+				var err error
+				if credentials, err = NewClientCredentials(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "secret"}); err != nil {
+					t.Fatalf("NewClientCredentials() error = %v", err)
+				}
+			}
+			client, err := NewClient(server.URL, &Options{Insecure: true, Credentials: credentials})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			req := &Request{Service: "test.Service", Method: "Method", Message: []byte{}}
+			var resp *Response
+			if tt.stream {
+				resp, err = client.InvokeServerStream(context.Background(), req, nil)
+			} else {
+				resp, err = client.Invoke(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatalf("call error = %v", err)
+			}
+			if resp.Status.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", resp.Status.Code, tt.wantCode)
+			}
+			if got := endpoint.count(); got != tt.wantFetches {
+				t.Errorf("token requests = %d, want %d", got, tt.wantFetches)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if requests != tt.wantRequests {
+				t.Errorf("calls = %d, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsTokenTransport(test *testing.T) {
+	// The token endpoint's certificate is from the server's private CA, and
+	// --resolve sends its address to a closed port if it applied to tokens
+	server, ca, requests := tlsServer(test, nil)
+	tokenCert := newTestCertificate(test, "auth.test", []string{"127.0.0.1"}, ca)
+	tokenServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "token-1", "token_type": "bearer"}`)
+	}))
+	tokenServer.TLS = &tls.Config{Certificates: []tls.Certificate{tokenCert.tlsCertificate()}}
+	tokenServer.StartTLS()
+	defer tokenServer.Close()
+
+	credentials, err := NewClientCredentials(OAuth2Config{TokenURL: tokenServer.URL + "/token", ClientID: "client"})
+	if err != nil {
+		test.Fatalf("NewClientCredentials() error = %v", err)
+	}
+	client, err := NewClient("https://"+server.Listener.Addr().String(), &Options{
+		CAFiles:     []string{ca.writePEM(test, test.TempDir(), "ca.pem", false)},
+		ServerName:  "api.test",
+		Resolves:    []string{tokenServer.Listener.Addr().String() + ":127.0.0.2"},
+		Credentials: credentials,
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if got := (<-requests).Header.Get("Authorization"); got != "Bearer token-1" {
+		test.Errorf("Authorization = %q, want the fetched token", got)
+	}
+}
+
+func TestClientCredentialsTokenUnixSocket(test *testing.T) {
+	// Calls go to the socket, and tokens to the identity provider over TCP
+	dir, err := os.MkdirTemp("", "grpcwebcurl")
+	if err != nil {
+		test.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "envoy.sock")
+	requests := unixServer(test, socket)
+	endpoint, tokenServer := newTokenEndpoint(test, nil)
+
+	credentials, err := NewClientCredentials(OAuth2Config{TokenURL: tokenServer.URL + "/token", ClientID: "client"})
+	if err != nil {
+		test.Fatalf("NewClientCredentials() error = %v", err)
+	}
+	invokeProxyTest(test, "http://api.example.com", &Options{UnixSocket: socket, Credentials: credentials})
+
+	if got := <-requests; got != "api.example.com/test.Service/Method" {
+		test.Errorf("socket received %q, want the call", got)
+	}
+	if got := endpoint.count(); got != 1 {
+		test.Errorf("token requests = %d, want 1", got)
+	}
+}
+
+func TestClientCredentialsTransportSecurity(test *testing.T) {
+	credentials, err := NewClientCredentials(OAuth2Config{TokenURL: "https://auth.example.com/token", ClientID: "client"})
+	if err != nil {
+		test.Fatalf("NewClientCredentials() error = %v", err)
+	}
+	if _, err := NewClient("http://localhost:8080", &Options{Plaintext: true, Credentials: credentials}); err == nil {
+		test.Error("NewClient() expected error for OAuth2 credentials over plaintext")
+	}
+	if _, err := NewClient("http://localhost:8080", &Options{Plaintext: true, Credentials: NewStaticCredentials("token")}); err != nil {
+		test.Errorf("NewClient() error = %v for a static token over plaintext", err)
+	}
+}
+
+func TestWebSocketMetadataCredentials(test *testing.T) {
+	client, err := NewClient("https://api.example.com", &Options{Credentials: NewStaticCredentials("token")})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetHeader("Authorization", "Bearer replaced")
+
+	req := &Request{Service: "test.Service", Method: "Method"}
+	ctx, err := client.credentialsContext(context.Background(), req)
+	if err != nil {
+		test.Fatalf("credentialsContext() error = %v", err)
+	}
+	metadata, _ := client.websocketMetadata(ctx, req)
+	if got := metadata.Get("Authorization"); got != "Bearer token" {
+		test.Errorf("authorization = %q, want the credentials token", got)
+	}
+}
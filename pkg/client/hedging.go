@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// errAttemptAbandoned stops a hedged attempt after another attempt committed.
+var errAttemptAbandoned = errors.New("another hedged attempt was committed")
+
+// HedgingPolicy sends a call several times in parallel, modeled on the
+// hedging policy of the gRPC service config. The first response with an OK
+// or fatal status wins and the other attempts are canceled.
+type HedgingPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// HedgingDelay is the delay before each further attempt is sent while
+	// earlier ones are still outstanding. Zero sends all attempts at once.
+	HedgingDelay time.Duration
+
+	// NonFatalCodes lists the status codes that do not end the call, so the
+	// next attempt is sent right away. Other codes are returned to the caller.
+	NonFatalCodes []int
+}
+
+// hedgedResult is the outcome of one hedged attempt.
+type hedgedResult struct {
+	number int
+	resp   *Response
+	err    error
+}
+
+// withHedging runs hedged attempts of a call until one commits or succeeds,
+// one fails with a fatal status, or all attempts have failed. A streaming
+// attempt commits when it delivers its first message; the others are
+// abandoned.
+func (client *Client) withHedging(ctx context.Context, policy *HedgingPolicy, attempt attemptFunc) (*Response, error) {
+	var mutex sync.Mutex
+	committed := 0
+	commit := func(number int) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if committed == 0 {
+			committed = number
+		}
+		return committed == number
+	}
+
+	results := make(chan hedgedResult, policy.MaxAttempts)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	start := func() {
+		number := len(cancels) + 1
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		if client.verbose && number > 1 {
+			fmt.Printf("* [hedge] sending attempt %d of %d\n", number, policy.MaxAttempts)
+		}
+		go func() {
+			resp, err := attempt(attemptCtx, func() bool { return commit(number) })
+			results <- hedgedResult{number: number, resp: resp, err: err}
+		}()
+	}
+
+	start()
+	pending := 1
+	stopped := false // set by a negative server pushback
+	timer := time.NewTimer(policy.HedgingDelay)
+	defer timer.Stop()
+
+	var last hedgedResult
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			// Outstanding attempts end with the context; wait for them
+			done = nil
+
+		case <-timer.C:
+			if !stopped && len(cancels) < policy.MaxAttempts && ctx.Err() == nil {
+				start()
+				pending++
+				timer.Reset(policy.HedgingDelay)
+			}
+			continue
+
+		case result := <-results:
+			pending--
+			mutex.Lock()
+			winner := committed
+			mutex.Unlock()
+
+			switch {
+			case winner == result.number:
+				return result.resp, result.err
+			case winner != 0:
+				// Abandoned after another attempt committed; wait for the winner
+				continue
+			}
+
+			status := attemptStatus(ctx, result.resp, result.err)
+			if status == nil || status.Status == nil || !slices.Contains(policy.NonFatalCodes, status.Status.Code) {
+				if commit(result.number) {
+					return result.resp, result.err
+				}
+				continue
+			}
+			last = result
+
+			if client.verbose {
+				fmt.Printf("* [hedge] attempt %d of %d failed with %s: %s\n",
+					result.number, policy.MaxAttempts, protocol.StatusName(status.Status.Code), status.Status.Message)
+			}
+
+			// Server pushback delays the next attempt or stops hedging;
+			// otherwise the next attempt is sent right away
+			delay := time.Duration(0)
+			if status.Trailers.Has(HeaderRetryPushback) {
+				pushback, err := strconv.Atoi(status.Trailers.Get(HeaderRetryPushback))
+				if err != nil || pushback < 0 {
+					stopped = true
+				}
+				delay = time.Duration(pushback) * time.Millisecond
+			}
+			if !stopped && len(cancels) < policy.MaxAttempts {
+				if delay == 0 && ctx.Err() == nil {
+					start()
+					pending++
+					delay = policy.HedgingDelay
+				}
+				timer.Reset(delay)
+			}
+		}
+
+		if pending == 0 && (stopped || len(cancels) >= policy.MaxAttempts || ctx.Err() != nil) {
+			return last.resp, last.err
+		}
+	}
+}
+
+// waitForReady wraps attempt to retry connection failures until the call's
+// context is done, as gRPC does for calls that wait for the server to become
+// ready instead of failing fast.
+func (client *Client) waitForReady(attempt attemptFunc) attemptFunc {
+	backoff := DefaultRetryPolicy(0)
+	return func(ctx context.Context, commit func() bool) (*Response, error) {
+		for retry := 1; ; retry++ {
+			resp, err := attempt(ctx, commit)
+			var opErr *net.OpError
+			if err == nil || ctx.Err() != nil || !errors.As(err, &opErr) || opErr.Op != "dial" {
+				return resp, err
+			}
+
+			delay := backoff.backoff(retry)
+			if client.verbose {
+				fmt.Printf("* [wait for ready] connection failed: %v; retrying in %s\n", opErr.Err, delay)
+			}
+			if !sleep(ctx, delay) {
+				return resp, err
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// hedgingTest invokes test.Service/Flaky on server under a service config
+// with the given method config.
+func hedgingTest(test *testing.T, serverURL string, method *MethodConfig) (*Response, error) {
+	method.Names = []MethodName{{Service: "test.Service"}}
+	client, err := NewClient(serverURL, &Options{
+		Plaintext:     true,
+		ServiceConfig: &ServiceConfig{MethodConfigs: []*MethodConfig{method}},
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	return client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Flaky",
+		Message: []byte{},
+	})
+}
+
+func TestInvokeHedging(test *testing.T) {
+	// The first attempt hangs until the client cancels it; the hedged
+	// attempt answers right away
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		encoder := protocol.NewEncoder(w)
+		encoder.Encode([]byte{0x08, 0x01})
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := hedgingTest(test, server.URL, &MethodConfig{
+		Hedging: &HedgingPolicy{MaxAttempts: 3, HedgingDelay: 20 * time.Millisecond},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK || len(resp.Messages) != 1 {
+		test.Errorf("Status code = %d with %d messages, want OK with 1", resp.Status.Code, len(resp.Messages))
+	}
+	if requests.Load() != 2 {
+		test.Errorf("server received %d requests, want 2", requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		test.Errorf("hedged call took %s, want about the 20ms hedging delay", elapsed)
+	}
+}
+
+func TestInvokeHedgingStatus(test *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		fail         http.HandlerFunc
+		wantCode     int
+		wantRequests int32
+	}{
+		{
+			name:         "non-fatal code sends next attempt",
+			failures:     2,
+			fail:         unavailable,
+			wantCode:     protocol.StatusOK,
+			wantRequests: 3,
+		},
+		{
+			name:         "all attempts fail",
+			failures:     5,
+			fail:         unavailable,
+			wantCode:     protocol.StatusUnavailable,
+			wantRequests: 3,
+		},
+		{
+			name:     "fatal code is returned",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Grpc-Status", "5")
+			},
+			wantCode:     protocol.StatusNotFound,
+			wantRequests: 1,
+		},
+		{
+			name:     "negative pushback stops hedging",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				unavailable(w, r)
+				w.Header().Set(HeaderRetryPushback, "-1")
+			},
+			wantCode:     protocol.StatusUnavailable,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			server, requests := flakyServer(t, tt.failures, tt.fail)
+
+			// A long hedging delay leaves only failures to trigger attempts
+			resp, err := hedgingTest(t, server.URL, &MethodConfig{
+				Hedging: &HedgingPolicy{
+					MaxAttempts:   3,
+					HedgingDelay:  time.Minute,
+					NonFatalCodes: []int{protocol.StatusUnavailable},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+			if resp.Status.Code != tt.wantCode {
+				t.Errorf("Status code = %d, want %d", resp.Status.Code, tt.wantCode)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestInvokeServerStreamHedging(test *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		encoder := protocol.NewEncoder(w)
+		encoder.Encode([]byte{0x08, 0x01})
+		encoder.Encode([]byte{0x08, 0x02})
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{
+		Plaintext: true,
+		ServiceConfig: &ServiceConfig{MethodConfigs: []*MethodConfig{{
+			Names:   []MethodName{{}},
+			Hedging: &HedgingPolicy{MaxAttempts: 3},
+		}}},
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	// All attempts are sent at once, but only the committed one delivers
+	// messages to the handler
+	var received atomic.Int32
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Stream",
+		Message: []byte{},
+	}, func(message []byte) error {
+		received.Add(1)
+		return nil
+	})
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if received.Load() != 2 {
+		test.Errorf("handler received %d messages, want 2 from a single attempt", received.Load())
+	}
+}
+
+func TestInvokeWaitForReady(test *testing.T) {
+	// Reserve an address, then start serving on it only after the first
+	// connection attempts have been refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		test.Fatalf("Listen() error = %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server, _ := flakyServer(test, 0, nil)
+	server.Close()
+	listeners := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			close(listeners)
+			return
+		}
+		listeners <- listener
+		http.Serve(listener, server.Config.Handler)
+	}()
+	defer func() {
+		if listener, ok := <-listeners; ok {
+			listener.Close()
+		}
+	}()
+
+	resp, err := hedgingTest(test, "http://"+address, &MethodConfig{
+		Timeout:      5 * time.Second,
+		WaitForReady: true,
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+}
+
+func TestInvokeMethodTimeout(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := hedgingTest(test, server.URL, &MethodConfig{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		test.Fatal("Invoke() expected an error after the method timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		test.Errorf("Invoke() returned after %s, want about the 50ms method timeout", elapsed)
+	}
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// UnaryInvoker makes a unary call.
+type UnaryInvoker func(ctx context.Context, req *Request) (*Response, error)
+
+// UnaryInterceptor intercepts unary calls. It may change the context or the
+// request, call invoker to continue the call, zero or more times, and inspect
+// or replace the response. Interceptors run once per call, around the retry
+// and hedging policies.
+type UnaryInterceptor func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error)
+
+// StreamInvoker makes a streaming call. For server streaming calls source is
+// nil and the request message is req.Message; for client and bidi streaming
+// calls the request messages come from source.
+type StreamInvoker func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error)
+
+// StreamInterceptor intercepts server, client and bidi streaming calls. Besides
+// what a UnaryInterceptor can do, it may wrap source and handler to observe or
+// change the messages sent and received.
+type StreamInterceptor func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error)
+
+// chainUnaryInterceptors returns an invoker that runs the interceptors in
+// order, the first outermost, before invoker.
+func chainUnaryInterceptors(interceptors []UnaryInterceptor, invoker UnaryInvoker) UnaryInvoker {
+	for index := len(interceptors) - 1; index >= 0; index-- {
+		interceptor, next := interceptors[index], invoker
+		invoker = func(ctx context.Context, req *Request) (*Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return invoker
+}
+
+// chainStreamInterceptors returns an invoker that runs the interceptors in
+// order, the first outermost, before invoker.
+func chainStreamInterceptors(interceptors []StreamInterceptor, invoker StreamInvoker) StreamInvoker {
+	for index := len(interceptors) - 1; index >= 0; index-- {
+		interceptor, next := interceptors[index], invoker
+		invoker = func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error) {
+			return interceptor(ctx, req, source, handler, next)
+		}
+	}
+	return invoker
+}
+
+// withHeaders returns a copy of req with headers added, replacing request
+// headers with the same key. req itself is not changed, so it can be reused.
+func withHeaders(req *Request, headers map[string]string) *Request {
+	clone := *req
+	clone.Headers = req.Headers.Clone()
+	for key, value := range headers {
+		clone.Headers.Set(key, value)
+	}
+	return &clone
+}
+
+// HeaderUnaryInterceptor returns a unary interceptor that adds headers to
+// every call, replacing request headers with the same key.
+func HeaderUnaryInterceptor(headers map[string]string) UnaryInterceptor {
+	return func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+		return invoker(ctx, withHeaders(req, headers))
+	}
+}
+
+// HeaderStreamInterceptor returns a stream interceptor that adds headers to
+// every call, replacing request headers with the same key.
+func HeaderStreamInterceptor(headers map[string]string) StreamInterceptor {
+	return func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error) {
+		return invoker(ctx, withHeaders(req, headers), source, handler)
+	}
+}
+
+// LoggingUnaryInterceptor returns a unary interceptor that logs the method,
+// status and duration of every call to logger, or to the standard logger if
+// logger is nil.
+func LoggingUnaryInterceptor(logger *log.Logger) UnaryInterceptor {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+		start := time.Now()
+		resp, err := invoker(ctx, req)
+		logger.Printf("/%s/%s %s in %s", req.Service, req.Method, callResult(resp, err), time.Since(start).Round(time.Microsecond))
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor returns a stream interceptor that logs the method,
+// status, duration and message counts of every call to logger, or to the
+// standard logger if logger is nil.
+func LoggingStreamInterceptor(logger *log.Logger) StreamInterceptor {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error) {
+		var sent, received atomic.Int64
+		if source == nil {
+			sent.Store(1)
+		} else {
+			next := source
+			source = func() ([]byte, error) {
+				message, err := next()
+				if err == nil {
+					sent.Add(1)
+				}
+				return message, err
+			}
+		}
+		next := handler
+		handler = func(message []byte) error {
+			received.Add(1)
+			if next == nil {
+				return nil
+			}
+			return next(message)
+		}
+
+		start := time.Now()
+		resp, err := invoker(ctx, req, source, handler)
+		logger.Printf("/%s/%s %s in %s (sent %d, received %d messages)", req.Service, req.Method,
+			callResult(resp, err), time.Since(start).Round(time.Microsecond), sent.Load(), received.Load())
+		return resp, err
+	}
+}
+
+// callResult describes the outcome of a call for logging: the status name and
+// message, or the error.
+func callResult(resp *Response, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("failed: %v", err)
+	case resp.Status == nil:
+		return "finished without a status"
+	case resp.Status.Message != "":
+		return fmt.Sprintf("%s: %s", protocol.StatusName(resp.Status.Code), resp.Status.Message)
+	default:
+		return protocol.StatusName(resp.Status.Code)
+	}
+}
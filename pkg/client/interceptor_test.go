@@ -0,0 +1,234 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+func TestChainInterceptors(test *testing.T) {
+	var calls []string
+	unary := func(name string) UnaryInterceptor {
+		return func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+			calls = append(calls, name+" before")
+			resp, err := invoker(ctx, req)
+			calls = append(calls, name+" after")
+			return resp, err
+		}
+	}
+	invoker := chainUnaryInterceptors([]UnaryInterceptor{unary("first"), unary("second")}, func(ctx context.Context, req *Request) (*Response, error) {
+		calls = append(calls, "invoker")
+		return &Response{}, nil
+	})
+	invoker(context.Background(), &Request{})
+
+	want := "first before, second before, invoker, second after, first after"
+	if got := strings.Join(calls, ", "); got != want {
+		test.Errorf("calls = %s, want %s", got, want)
+	}
+
+	// A stream interceptor can change the messages in both directions
+	stream := chainStreamInterceptors([]StreamInterceptor{
+		func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error) {
+			return invoker(ctx, req, func() ([]byte, error) {
+				message, err := source()
+				return bytes.ToUpper(message), err
+			}, func(message []byte) error {
+				return handler(append([]byte("<"), message...))
+			})
+		},
+	}, func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error) {
+		message, _ := source()
+		return &Response{}, handler(message)
+	})
+
+	var received string
+	stream(context.Background(), &Request{}, func() ([]byte, error) { return []byte("hello"), nil }, func(message []byte) error {
+		received = string(message)
+		return nil
+	})
+	if received != "<HELLO" {
+		test.Errorf("received %q, want %q", received, "<HELLO")
+	}
+}
+
+func TestClientInterceptors(test *testing.T) {
+	// The first request fails and is retried
+	headers := make(chan http.Header, 10)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		if requests.Add(1) == 1 {
+			unavailable(w, r)
+			return
+		}
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	var intercepted int
+	client, err := NewClient(server.URL, &Options{
+		Plaintext: true,
+		Retry:     testRetryPolicy(3),
+		UnaryInterceptors: []UnaryInterceptor{
+			LoggingUnaryInterceptor(logger),
+			HeaderUnaryInterceptor(map[string]string{"X-Tenant": "acme"}),
+			func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+				intercepted++
+				return invoker(ctx, req)
+			},
+		},
+		StreamInterceptors: []StreamInterceptor{
+			LoggingStreamInterceptor(logger),
+			HeaderStreamInterceptor(map[string]string{"X-Tenant": "acme"}),
+		},
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "Method", Message: []byte{}, Headers: protocol.NewMetadata()}
+	req.Headers.Set("X-Tenant", "other")
+	req.Headers.Set("X-Request", "1")
+	resp, err := client.Invoke(context.Background(), req)
+	if err != nil || resp.Status.Code != protocol.StatusOK {
+		test.Fatalf("Invoke() = %v, %v", resp, err)
+	}
+
+	// Interceptors run once per call, around the retries
+	if intercepted != 1 || requests.Load() != 2 {
+		test.Errorf("interceptor ran %d times for %d requests, want once for 2", intercepted, requests.Load())
+	}
+	for range 2 {
+		header := <-headers
+		if header.Get("X-Tenant") != "acme" || header.Get("X-Request") != "1" {
+			test.Errorf("request headers = %v, want X-Tenant: acme and X-Request: 1", header)
+		}
+	}
+	if req.Headers.Get("X-Tenant") != "other" {
+		test.Error("HeaderUnaryInterceptor changed the caller's request")
+	}
+
+	var messages int
+	if _, err := client.InvokeServerStream(context.Background(), req, func([]byte) error {
+		messages++
+		return nil
+	}); err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if header := <-headers; header.Get("X-Tenant") != "acme" {
+		test.Errorf("stream X-Tenant = %q, want acme", header.Get("X-Tenant"))
+	}
+	if messages != 1 {
+		test.Errorf("handler received %d messages, want 1", messages)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "/test.Service/Method OK in ") ||
+		!strings.HasPrefix(lines[1], "/test.Service/Method OK in ") || !strings.HasSuffix(lines[1], "(sent 1, received 1 messages)") {
+		test.Errorf("logs = %q", logs.String())
+	}
+}
+
+func TestClientInterceptorShortCircuit(test *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+
+	// Fault injection: fail calls without reaching the server
+	injected := errors.New("injected fault")
+	var logs bytes.Buffer
+	client, err := NewClient(server.URL, &Options{
+		Plaintext: true,
+		UnaryInterceptors: []UnaryInterceptor{
+			LoggingUnaryInterceptor(log.New(&logs, "", 0)),
+			func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+				return &Response{Status: &protocol.Status{Code: protocol.StatusUnavailable, Message: "injected"}}, nil
+			},
+		},
+		StreamInterceptors: []StreamInterceptor{
+			func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error) {
+				return nil, injected
+			},
+		},
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "Method", Message: []byte{}}
+	resp, err := client.Invoke(context.Background(), req)
+	if err != nil || resp.Status.Code != protocol.StatusUnavailable {
+		test.Errorf("Invoke() = %v, %v, want the injected status", resp, err)
+	}
+	if !strings.Contains(logs.String(), "/test.Service/Method UNAVAILABLE: injected in ") {
+		test.Errorf("logs = %q", logs.String())
+	}
+	if _, err := client.InvokeServerStream(context.Background(), req, nil); !errors.Is(err, injected) {
+		test.Errorf("InvokeServerStream() error = %v, want the injected error", err)
+	}
+	source := func() ([]byte, error) { return nil, io.EOF }
+	if _, err := client.InvokeBidiStream(context.Background(), req, source, nil); !errors.Is(err, injected) {
+		test.Errorf("InvokeBidiStream() error = %v, want the injected error", err)
+	}
+	if requests != 0 {
+		test.Errorf("server received %d requests, want 0", requests)
+	}
+}
+
+func TestClientInterceptorsBidiStream(test *testing.T) {
+	gotMetadata := make(chan http.Header, 1)
+	server := newWebSocketEchoServer(test, gotMetadata)
+	defer server.Close()
+
+	var logs bytes.Buffer
+	client, err := NewClient(server.URL, &Options{
+		Plaintext: true,
+		StreamInterceptors: []StreamInterceptor{
+			LoggingStreamInterceptor(log.New(&logs, "", 0)),
+			HeaderStreamInterceptor(map[string]string{"X-Tenant": "acme"}),
+		},
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	requests := []string{"one", "two"}
+	source := func() ([]byte, error) {
+		if len(requests) == 0 {
+			return nil, io.EOF
+		}
+		message := requests[0]
+		requests = requests[1:]
+		return []byte(message), nil
+	}
+	if _, err := client.InvokeBidiStream(context.Background(), &Request{Service: "test.Service", Method: "Chat"}, source, nil); err != nil {
+		test.Fatalf("InvokeBidiStream() error = %v", err)
+	}
+
+	if metadata := <-gotMetadata; metadata.Get("X-Tenant") != "acme" {
+		test.Errorf("metadata X-Tenant = %q, want acme", metadata.Get("X-Tenant"))
+	}
+	if !strings.Contains(logs.String(), "/test.Service/Chat OK in ") || !strings.Contains(logs.String(), "(sent 2, received 2 messages)") {
+		test.Errorf("logs = %q", logs.String())
+	}
+
+	if _, err := client.InvokeBidiStream(context.Background(), &Request{Service: "test.Service", Method: "Chat"}, nil, nil); err == nil {
+		test.Error("InvokeBidiStream() expected error without a message source")
+	}
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultJWTLifetime is how long a minted JWT is valid. Tokens are minted for
+// every call, so they only need to outlive the call and some clock skew.
+const defaultJWTLifetime = 5 * time.Minute
+
+// JWTConfig configures credentials that sign a JWT for every call, for
+// services that accept self-signed JWT assertions instead of access tokens.
+type JWTConfig struct {
+	// Key signs the tokens: an RSA key for RS256, or an ECDSA key for ES256,
+	// ES384 or ES512 depending on its curve
+	Key crypto.Signer
+
+	KeyID    string         // Sent as the kid header if set
+	Claims   map[string]any // Claims added to every token, such as iss and sub
+	Audience string         // aud claim; default: the call's base URL and service
+
+	// Lifetime sets the exp claim relative to iat; zero uses five minutes
+	Lifetime time.Duration
+}
+
+// serviceAccountKey is the subset of a Google-style service account JSON key
+// used for self-signed JWTs.
+type serviceAccountKey struct {
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	ClientEmail  string `json:"client_email"`
+}
+
+// jwtCredentials mints a signed JWT for every call.
+type jwtCredentials struct {
+	config    JWTConfig
+	algorithm string
+	hash      crypto.Hash
+}
+
+// LoadJWTConfig loads a signing key and optional claims for JWT credentials.
+// The key file is a PEM private key (PKCS#1, PKCS#8 or SEC 1), or a service
+// account JSON key whose client_email becomes the iss and sub claims and whose
+// private_key_id becomes the kid. The claims file is a JSON object whose
+// claims take precedence over those from the key.
+func LoadJWTConfig(keyFile, claimsFile string) (*JWTConfig, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key: %w", err)
+	}
+
+	config := &JWTConfig{Claims: make(map[string]any)}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var account serviceAccountKey
+		if err := json.Unmarshal(trimmed, &account); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT key %s: %w", keyFile, err)
+		}
+		if account.PrivateKey == "" {
+			return nil, fmt.Errorf("JWT key %s has no private_key", keyFile)
+		}
+		data = []byte(account.PrivateKey)
+		config.KeyID = account.PrivateKeyID
+		if account.ClientEmail != "" {
+			config.Claims["iss"] = account.ClientEmail
+			config.Claims["sub"] = account.ClientEmail
+		}
+	}
+	if config.Key, err = parsePrivateKey(data); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT key %s: %w", keyFile, err)
+	}
+
+	if claimsFile != "" {
+		data, err := os.ReadFile(claimsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT claims: %w", err)
+		}
+		var claims map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&claims); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT claims %s: %w", claimsFile, err)
+		}
+		for name, value := range claims {
+			config.Claims[name] = value
+		}
+	}
+
+	return config, nil
+}
+
+// parsePrivateKey parses the first PEM private key in data.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// NewJWTCredentials returns credentials that send a JWT signed with the
+// configured key as a bearer token, minting a new one with fresh iat and exp
+// claims for every call.
+func NewJWTCredentials(config JWTConfig) (PerRPCCredentials, error) {
+	credentials := &jwtCredentials{config: config}
+
+	switch key := config.Key.(type) {
+	case *rsa.PrivateKey:
+		credentials.algorithm, credentials.hash = "RS256", crypto.SHA256
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			credentials.algorithm, credentials.hash = "ES256", crypto.SHA256
+		case elliptic.P384():
+			credentials.algorithm, credentials.hash = "ES384", crypto.SHA384
+		case elliptic.P521():
+			credentials.algorithm, credentials.hash = "ES512", crypto.SHA512
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", key.Curve.Params().Name)
+		}
+	case nil:
+		return nil, errors.New("JWT credentials require a key")
+	default:
+		return nil, fmt.Errorf("unsupported JWT key type %T: must be RSA or ECDSA", key)
+	}
+
+	if credentials.config.Lifetime <= 0 {
+		credentials.config.Lifetime = defaultJWTLifetime
+	}
+	return credentials, nil
+}
+
+// GetRequestMetadata implements PerRPCCredentials.
+func (credentials *jwtCredentials) GetRequestMetadata(ctx context.Context, uri string) (map[string]string, error) {
+	token, err := credentials.mint(uri, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity implements PerRPCCredentials.
+func (credentials *jwtCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// mint returns a signed JWT for a call to uri issued at now. The aud claim
+// comes from the configured audience, then the claims, then uri; iat and exp
+// are always set.
+func (credentials *jwtCredentials) mint(uri string, now time.Time) (string, error) {
+	config := credentials.config
+
+	header := map[string]string{"alg": credentials.algorithm, "typ": "JWT"}
+	if config.KeyID != "" {
+		header["kid"] = config.KeyID
+	}
+
+	claims := make(map[string]any, len(config.Claims)+3)
+	for name, value := range config.Claims {
+		claims[name] = value
+	}
+	if config.Audience != "" {
+		claims["aud"] = config.Audience
+	} else if _, ok := claims["aud"]; !ok {
+		claims["aud"] = uri
+	}
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(config.Lifetime).Unix()
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." +
+		base64.RawURLEncoding.EncodeToString(encodedClaims)
+
+	signature, err := credentials.sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sign signs data with the key. ECDSA signatures are the fixed-size r || s
+// encoding that JWS uses (RFC 7518 section 3.4) rather than ASN.1.
+func (credentials *jwtCredentials) sign(data []byte) ([]byte, error) {
+	var digest []byte
+	switch credentials.hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512(data)
+		digest = sum[:]
+	}
+
+	switch key := credentials.config.Key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	default:
+		return credentials.config.Key.Sign(rand.Reader, digest, credentials.hash)
+	}
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseJWT verifies a JWT signed with an RS256 or ES256 key and returns its
+// header and claims.
+func parseJWT(test *testing.T, token string, key crypto.PublicKey) (map[string]any, map[string]any) {
+	test.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		test.Fatalf("JWT %q does not have three parts", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		test.Fatalf("invalid signature encoding: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			test.Fatalf("RS256 signature does not verify: %v", err)
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			test.Fatalf("ES256 signature is %d bytes, want 64", len(signature))
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			test.Fatal("ES256 signature does not verify")
+		}
+	}
+
+	var header, claims map[string]any
+	for index, target := range []*map[string]any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[index])
+		if err != nil {
+			test.Fatalf("invalid JWT part encoding: %v", err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			test.Fatalf("invalid JWT part: %v", err)
+		}
+	}
+	return header, claims
+}
+
+// writeKey writes a private key to a PEM file of the given type.
+func writeKey(test *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		test.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadJWTConfig(test *testing.T) {
+	dir := test.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	account, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"private_key":    string(rsaPEM),
+		"private_key_id": "key-1",
+		"client_email":   "svc@project.iam.example.com",
+	})
+	accountFile := filepath.Join(dir, "account.json")
+	os.WriteFile(accountFile, account, 0o600)
+	claimsFile := filepath.Join(dir, "claims.json")
+	os.WriteFile(claimsFile, []byte(`{"sub": "override", "scope": "read", "n": 12345678901234567}`), 0o600)
+	badClaims := filepath.Join(dir, "bad-claims.json")
+	os.WriteFile(badClaims, []byte(`["not", "an", "object"]`), 0o600)
+
+	tests := []struct {
+		name       string
+		keyFile    string
+		claimsFile string
+		wantKey    crypto.PublicKey
+		wantKeyID  string
+		wantClaims map[string]string
+		wantErr    bool
+	}{
+		{name: "PKCS#1 RSA key", keyFile: writeKey(test, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), wantKey: &rsaKey.PublicKey},
+		{name: "SEC 1 EC key", keyFile: writeKey(test, dir, "ec.pem", "EC PRIVATE KEY", sec1), wantKey: &ecKey.PublicKey},
+		{name: "PKCS#8 EC key", keyFile: writeKey(test, dir, "pkcs8.pem", "PRIVATE KEY", pkcs8), wantKey: &ecKey.PublicKey},
+		{
+			name:       "service account key with claims",
+			keyFile:    accountFile,
+			claimsFile: claimsFile,
+			wantKey:    &rsaKey.PublicKey,
+			wantKeyID:  "key-1",
+			wantClaims: map[string]string{"iss": "svc@project.iam.example.com", "sub": "override", "scope": "read", "n": "12345678901234567"},
+		},
+		{name: "missing key", keyFile: filepath.Join(dir, "missing.pem"), wantErr: true},
+		{name: "not a key", keyFile: claimsFile, wantErr: true},
+		{name: "certificate instead of key", keyFile: writeKey(test, dir, "cert.pem", "CERTIFICATE", []byte{1}), wantErr: true},
+		{name: "claims not an object", keyFile: accountFile, claimsFile: badClaims, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			config, err := LoadJWTConfig(tt.keyFile, tt.claimsFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadJWTConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if public, ok := config.Key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(tt.wantKey) {
+				t.Error("LoadJWTConfig() loaded the wrong key")
+			}
+			if config.KeyID != tt.wantKeyID {
+				t.Errorf("KeyID = %q, want %q", config.KeyID, tt.wantKeyID)
+			}
+			for name, want := range tt.wantClaims {
+				if got := config.Claims[name]; got == nil || strings.Trim(mustJSON(t, got), `"`) != want {
+					t.Errorf("claim %s = %v, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// mustJSON encodes value as JSON.
+func mustJSON(test *testing.T, value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		test.Fatalf("Marshal() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestJWTCredentials(test *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+	const uri = "https://api.example.com/test.Service"
+
+	tests := []struct {
+		name     string
+		config   JWTConfig
+		wantAlg  string
+		wantAud  string
+		wantLife int64
+	}{
+		{
+			name:     "RS256 with the call's audience",
+			config:   JWTConfig{Key: rsaKey, KeyID: "key-1", Claims: map[string]any{"iss": "svc", "exp": 1}},
+			wantAlg:  "RS256",
+			wantAud:  uri,
+			wantLife: int64(defaultJWTLifetime / time.Second),
+		},
+		{
+			name:     "ES256 with the audience from claims",
+			config:   JWTConfig{Key: ecKey, Claims: map[string]any{"aud": "claims-audience"}, Lifetime: time.Minute},
+			wantAlg:  "ES256",
+			wantAud:  "claims-audience",
+			wantLife: 60,
+		},
+		{
+			name:     "audience option wins",
+			config:   JWTConfig{Key: ecKey, Claims: map[string]any{"aud": "claims-audience"}, Audience: "https://api.example.com/"},
+			wantAlg:  "ES256",
+			wantAud:  "https://api.example.com/",
+			wantLife: int64(defaultJWTLifetime / time.Second),
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			credentials, err := NewJWTCredentials(tt.config)
+			if err != nil {
+				t.Fatalf("NewJWTCredentials() error = %v", err)
+			}
+			if !credentials.RequireTransportSecurity() {
+				t.Error("RequireTransportSecurity() = false")
+			}
+
+			before := time.Now().Unix()
+			metadata, err := credentials.GetRequestMetadata(context.Background(), uri)
+			if err != nil {
+				t.Fatalf("GetRequestMetadata() error = %v", err)
+			}
+			token, ok := strings.CutPrefix(metadata["authorization"], "Bearer ")
+			if !ok {
+				t.Fatalf("authorization = %q, want a bearer token", metadata["authorization"])
+			}
+
+			header, claims := parseJWT(t, token, tt.config.Key.Public())
+			if header["alg"] != tt.wantAlg || header["typ"] != "JWT" || header["kid"] != nilIfEmpty(tt.config.KeyID) {
+				t.Errorf("header = %v", header)
+			}
+			if claims["aud"] != tt.wantAud {
+				t.Errorf("aud = %v, want %s", claims["aud"], tt.wantAud)
+			}
+			iat, exp := int64(claims["iat"].(float64)), int64(claims["exp"].(float64))
+			if iat < before || iat > time.Now().Unix() || exp-iat != tt.wantLife {
+				t.Errorf("iat = %d, exp = %d, want a %ds token issued now", iat, exp, tt.wantLife)
+			}
+			for name, value := range tt.config.Claims {
+				if name != "aud" && name != "exp" && claims[name] != value {
+					t.Errorf("claim %s = %v, want %v", name, claims[name], value)
+				}
+			}
+		})
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	for _, key := range []crypto.Signer{nil, edKey, p224Key} {
+		if _, err := NewJWTCredentials(JWTConfig{Key: key}); err == nil {
+			test.Errorf("NewJWTCredentials() expected error for key %T", key)
+		}
+	}
+}
+
+// nilIfEmpty returns nil for an empty string, as a missing JSON field decodes.
+func nilIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func TestClientInvokeJWT(test *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	tokens := make(chan string, 10)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+
+	credentials, err := NewJWTCredentials(JWTConfig{Key: key, Claims: map[string]any{"iss": "svc"}})
+	if err != nil {
+		test.Fatalf("NewJWTCredentials() error = %v", err)
+	}
+	client, err := NewClient(server.URL, &Options{Insecure: true, Credentials: credentials})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "Method", Message: []byte{}}
+	if _, err := client.Invoke(context.Background(), req); err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if _, err := client.InvokeServerStream(context.Background(), req, nil); err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+
+	for range 2 {
+		_, claims := parseJWT(test, <-tokens, &key.PublicKey)
+		if claims["aud"] != server.URL+"/test.Service" || claims["iss"] != "svc" {
+			test.Errorf("claims = %v, want aud %s/test.Service", claims, server.URL)
+		}
+	}
+
+	if _, err := NewClient(server.URL, &Options{Plaintext: true, Credentials: credentials}); err == nil {
+		test.Error("NewClient() expected error for JWT credentials over plaintext")
+	}
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proxy URL schemes. With socks5 the client resolves the target host itself;
+// with socks5h the proxy does.
+const (
+	proxySchemeHTTP    = "http"
+	proxySchemeHTTPS   = "https"
+	proxySchemeSOCKS5  = "socks5"
+	proxySchemeSOCKS5H = "socks5h"
+)
+
+// defaultSOCKSPort is used for SOCKS proxy URLs without a port.
+const defaultSOCKSPort = "1080"
+
+// dialFunc dials a network connection, like net.Dialer.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// selectProxy returns the proxy for requests to baseURL: proxy if set,
+// otherwise the one named by HTTPS_PROXY, HTTP_PROXY or ALL_PROXY for the
+// URL's scheme. Returns nil if there is none or noProxy excludes the host.
+// noProxy defaults to the NO_PROXY environment variable.
+func selectProxy(baseURL, proxy, noProxy string) (*url.URL, error) {
+	target, err := url.Parse(baseURL)
+	if err != nil || target.Host == "" {
+		// Not a URL; requests fail later with a clearer error
+		return nil, nil
+	}
+
+	if proxy == "" {
+		if target.Scheme == "https" {
+			proxy = getenv("HTTPS_PROXY", "https_proxy")
+		} else {
+			proxy = getenv("HTTP_PROXY", "http_proxy")
+		}
+		if proxy == "" {
+			proxy = getenv("ALL_PROXY", "all_proxy")
+		}
+		if proxy == "" {
+			return nil, nil
+		}
+	}
+
+	if noProxy == "" {
+		noProxy = getenv("NO_PROXY", "no_proxy")
+	}
+	if bypassProxy(target, noProxy) {
+		return nil, nil
+	}
+
+	return parseProxyURL(proxy)
+}
+
+// getenv returns the value of the first of the environment variables that is
+// set.
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseProxyURL parses a proxy URL. A URL without a scheme is an HTTP proxy.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = proxySchemeHTTP + "://" + proxy
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxyURL.Redacted())
+	}
+
+	switch proxyURL.Scheme {
+	case proxySchemeHTTP, proxySchemeHTTPS:
+	case proxySchemeSOCKS5, proxySchemeSOCKS5H:
+		if proxyURL.Port() == "" {
+			proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), defaultSOCKSPort)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: must be http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
+
+// bypassProxy reports whether noProxy excludes the target from proxying.
+// noProxy is a comma-separated list of host names, which also match their
+// subdomains, IP addresses and CIDR ranges, each optionally with a port. "*"
+// excludes every host.
+func bypassProxy(target *url.URL, noProxy string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if splitHost, splitPort, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = splitHost, splitPort
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.Trim(entryHost, "[]"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// SOCKS5 protocol constants (RFC 1928 and RFC 1929).
+const (
+	socksVersion          = 0x05
+	socksAuthNone         = 0x00
+	socksAuthPassword     = 0x02
+	socksAuthNoAcceptable = 0xff
+	socksPasswordVersion  = 0x01
+	socksCommandConnect   = 0x01
+	socksAddressIPv4      = 0x01
+	socksAddressDomain    = 0x03
+	socksAddressIPv6      = 0x04
+)
+
+// socksReplies describes the SOCKS5 reply codes.
+var socksReplies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socksDialer connects to targets through a SOCKS5 proxy.
+type socksDialer struct {
+	proxyAddr string
+	username  string
+	password  string
+
+	// remoteDNS sends host names to the proxy instead of resolving them
+	remoteDNS bool
+
+	// resolver applies --resolve and --connect-to overrides to the target
+	// address before it is resolved locally
+	resolver *resolver
+
+	// dial connects to the proxy itself
+	dial dialFunc
+}
+
+// newSOCKSDialer returns a dialer for a socks5 or socks5h proxy URL.
+func newSOCKSDialer(proxyURL *url.URL, resolver *resolver, dial dialFunc) *socksDialer {
+	dialer := &socksDialer{
+		proxyAddr: proxyURL.Host,
+		remoteDNS: proxyURL.Scheme == proxySchemeSOCKS5H,
+		resolver:  resolver,
+		dial:      dial,
+	}
+	if proxyURL.User != nil {
+		dialer.username = proxyURL.User.Username()
+		dialer.password, _ = proxyURL.User.Password()
+	}
+	return dialer
+}
+
+// DialContext connects to addr through the proxy. With local resolution,
+// each of addr's targets is tried in order.
+func (dialer *socksDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dialer.remoteDNS {
+		return dialer.dialTarget(ctx, addr)
+	}
+	return dialer.resolver.dialTargets(ctx, addr, func(target string) (net.Conn, error) {
+		return dialer.dialTarget(ctx, target)
+	})
+}
+
+// dialTarget connects to addr through the proxy.
+func (dialer *socksDialer) dialTarget(ctx context.Context, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+
+	if !dialer.remoteDNS && net.ParseIP(host) == nil {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		host = ips[0].String()
+	}
+
+	conn, err := dialer.dial(ctx, "tcp", dialer.proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SOCKS proxy %s: %w", dialer.proxyAddr, err)
+	}
+
+	// Bound the handshake by the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	err = dialer.handshake(conn, host, uint16(portNumber))
+	if !stop() && err != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS proxy %s: %w", dialer.proxyAddr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake authenticates with the proxy and asks it to connect to host:port.
+func (dialer *socksDialer) handshake(conn net.Conn, host string, port uint16) error {
+	methods := []byte{socksAuthNone}
+	if dialer.username != "" {
+		methods = append(methods, socksAuthPassword)
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	switch reply[1] {
+	case socksAuthNone:
+	case socksAuthPassword:
+		if dialer.username == "" {
+			return errors.New("proxy requires a username and password")
+		}
+		if len(dialer.username) > 255 || len(dialer.password) > 255 {
+			return errors.New("username and password must be at most 255 bytes")
+		}
+		auth := []byte{socksPasswordVersion, byte(len(dialer.username))}
+		auth = append(auth, dialer.username...)
+		auth = append(auth, byte(len(dialer.password)))
+		auth = append(auth, dialer.password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	case socksAuthNoAcceptable:
+		return errors.New("no acceptable authentication method")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	request := []byte{socksVersion, socksCommandConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long", host)
+		}
+		request = append(request, socksAddressDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socksAddressIPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socksAddressIPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, port)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		if message, ok := socksReplies[header[1]]; ok {
+			return errors.New(message)
+		}
+		return fmt.Errorf("connect failed with reply %d", header[1])
+	}
+
+	// Skip the bound address and port
+	var skip int
+	switch header[3] {
+	case socksAddressIPv4:
+		skip = net.IPv4len
+	case socksAddressIPv6:
+		skip = net.IPv6len
+	case socksAddressDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("unknown address type %d", header[3])
+	}
+	_, err := io.CopyN(io.Discard, conn, int64(skip+2))
+	return err
+}
+
+// configureProxy routes the transport through proxyURL. HTTP proxies use the
+// transport's proxy support, which dials the proxy with dial; the proxy
+// resolves the target. SOCKS proxies replace the transport's dialer.
+func configureProxy(transport *http.Transport, proxyURL *url.URL, resolver *resolver, dial dialFunc) {
+	switch proxyURL.Scheme {
+	case proxySchemeHTTP, proxySchemeHTTPS:
+		transport.Proxy = http.ProxyURL(proxyURL)
+	case proxySchemeSOCKS5, proxySchemeSOCKS5H:
+		transport.DialContext = newSOCKSDialer(proxyURL, resolver, dial).DialContext
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/hjames9/grpcwebcurl/pkg/descriptor"
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -57,6 +58,7 @@ func (reflectionClient *ReflectionClient) ListServices(ctx context.Context) ([]s
 		Service: reflectionServiceName,
 		Method:  reflectionMethod,
 		Message: reqBytes,
+		Codec:   protocol.ProtoCodec{}, // reflection messages are hand-encoded
 	})
 	if err != nil {
 		// Try v1
@@ -64,6 +66,7 @@ func (reflectionClient *ReflectionClient) ListServices(ctx context.Context) ([]s
 			Service: reflectionV1ServiceName,
 			Method:  reflectionMethod,
 			Message: reqBytes,
+			Codec:   protocol.ProtoCodec{},
 		})
 		if err != nil {
 			return nil, fmt.Errorf("reflection request failed: %w", err)
@@ -120,6 +123,7 @@ func (reflectionClient *ReflectionClient) FileContainingSymbolWithDeps(ctx conte
 		Service: reflectionServiceName,
 		Method:  reflectionMethod,
 		Message: reqBytes,
+		Codec:   protocol.ProtoCodec{}, // reflection messages are hand-encoded
 	})
 	if err != nil {
 		// Try v1
@@ -127,6 +131,7 @@ func (reflectionClient *ReflectionClient) FileContainingSymbolWithDeps(ctx conte
 			Service: reflectionV1ServiceName,
 			Method:  reflectionMethod,
 			Message: reqBytes,
+			Codec:   protocol.ProtoCodec{},
 		})
 		if err != nil {
 			return nil, fmt.Errorf("reflection request failed: %w", err)
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wildcardHost in a --resolve entry matches every host on the entry's port.
+const wildcardHost = "*"
+
+// resolver overrides where connections go, like curl's --resolve and
+// --connect-to options. TLS and the Host header still use the original host.
+type resolver struct {
+	// hosts maps host:port, or *:port for the wildcard, to the addresses to
+	// try in order
+	hosts map[string][]string
+
+	// connectTo redirects matching host:port pairs; the first match applies
+	connectTo []connectToEntry
+
+	verbose bool
+}
+
+// connectToEntry redirects connections for host:port to targetHost:targetPort.
+// An empty host or port matches any, and an empty target keeps the original.
+type connectToEntry struct {
+	host       string
+	port       string
+	targetHost string
+	targetPort string
+}
+
+// newResolver parses --resolve entries of the form [+]host:port:addr[,addr]...
+// and --connect-to entries of the form host:port:host2:port2. IPv6 addresses
+// are written in brackets, and a host of * in a --resolve entry matches every
+// host on its port.
+func newResolver(resolve, connectTo []string) (*resolver, error) {
+	resolver := &resolver{hosts: make(map[string][]string)}
+
+	for _, entry := range resolve {
+		host, port, addresses, err := parseResolveEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --resolve %q: %w", entry, err)
+		}
+		key := net.JoinHostPort(host, port)
+		resolver.hosts[key] = append(resolver.hosts[key], addresses...)
+	}
+
+	for _, entry := range connectTo {
+		parsed, err := parseConnectToEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --connect-to %q: %w", entry, err)
+		}
+		resolver.connectTo = append(resolver.connectTo, parsed)
+	}
+
+	return resolver, nil
+}
+
+// parseResolveEntry parses a --resolve entry into its host, port and
+// addresses. A leading + (curl's lazy entries) is accepted and ignored.
+func parseResolveEntry(entry string) (string, string, []string, error) {
+	host, rest, found, err := cutField(strings.TrimPrefix(entry, "+"))
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !found || host == "" {
+		return "", "", nil, errors.New("expected host:port:address[,address]...")
+	}
+	port, list, found, err := cutField(rest)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !found || list == "" {
+		return "", "", nil, errors.New("expected host:port:address[,address]...")
+	}
+	if err := checkPort(port); err != nil {
+		return "", "", nil, err
+	}
+
+	var addresses []string
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if strings.HasPrefix(address, "[") {
+			if !strings.HasSuffix(address, "]") {
+				return "", "", nil, fmt.Errorf("missing ] in address %q", address)
+			}
+			address = address[1 : len(address)-1]
+		}
+		if address == "" {
+			return "", "", nil, errors.New("empty address")
+		}
+		addresses = append(addresses, address)
+	}
+	return strings.ToLower(host), port, addresses, nil
+}
+
+// parseConnectToEntry parses a --connect-to entry.
+func parseConnectToEntry(entry string) (connectToEntry, error) {
+	var fields [4]string
+	rest := entry
+	for index := range 3 {
+		var found bool
+		var err error
+		fields[index], rest, found, err = cutField(rest)
+		if err != nil {
+			return connectToEntry{}, err
+		}
+		if !found {
+			return connectToEntry{}, errors.New("expected host:port:host2:port2")
+		}
+	}
+	if strings.Contains(rest, ":") {
+		return connectToEntry{}, errors.New("expected host:port:host2:port2")
+	}
+	fields[3] = rest
+
+	for _, port := range []string{fields[1], fields[3]} {
+		if port == "" {
+			continue
+		}
+		if err := checkPort(port); err != nil {
+			return connectToEntry{}, err
+		}
+	}
+	return connectToEntry{
+		host:       strings.ToLower(fields[0]),
+		port:       fields[1],
+		targetHost: strings.ToLower(fields[2]),
+		targetPort: fields[3],
+	}, nil
+}
+
+// cutField cuts s at the first colon that is not inside brackets, returning
+// the text before it without brackets, the text after it, and whether a colon
+// was found.
+func cutField(s string) (string, string, bool, error) {
+	if !strings.HasPrefix(s, "[") {
+		field, rest, found := strings.Cut(s, ":")
+		return field, rest, found, nil
+	}
+
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return "", "", false, fmt.Errorf("missing ] in %q", s)
+	}
+	field, rest := s[1:end], s[end+1:]
+	if rest != "" && !strings.HasPrefix(rest, ":") {
+		return "", "", false, fmt.Errorf("expected : after %q", s[:end+1])
+	}
+	return field, strings.TrimPrefix(rest, ":"), rest != "", nil
+}
+
+// checkPort validates a port number.
+func checkPort(port string) error {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// empty reports whether the resolver overrides nothing.
+func (resolver *resolver) empty() bool {
+	return len(resolver.hosts) == 0 && len(resolver.connectTo) == 0
+}
+
+// targets returns the addresses to dial for addr in order: addr after any
+// --connect-to redirect, replaced by its --resolve addresses if it has any.
+func (resolver *resolver) targets(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []string{addr}
+	}
+	host = strings.ToLower(host)
+
+	for _, entry := range resolver.connectTo {
+		if (entry.host == "" || entry.host == host) && (entry.port == "" || entry.port == port) {
+			if entry.targetHost != "" {
+				host = entry.targetHost
+			}
+			if entry.targetPort != "" {
+				port = entry.targetPort
+			}
+			break
+		}
+	}
+
+	addresses, ok := resolver.hosts[net.JoinHostPort(host, port)]
+	if !ok {
+		addresses = resolver.hosts[net.JoinHostPort(wildcardHost, port)]
+	}
+	if len(addresses) == 0 {
+		return []string{net.JoinHostPort(host, port)}
+	}
+
+	targets := make([]string, len(addresses))
+	for index, address := range addresses {
+		targets[index] = net.JoinHostPort(address, port)
+	}
+	return targets
+}
+
+// dialer returns a dial function that tries the targets for each address in
+// order until one connects.
+func (resolver *resolver) dialer(timeout time.Duration) dialFunc {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return resolver.dialTargets(ctx, addr, func(target string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, target)
+		})
+	}
+}
+
+// dialTargets calls dial for each target of addr until one succeeds, and
+// returns the last error if none does.
+func (resolver *resolver) dialTargets(ctx context.Context, addr string, dial func(target string) (net.Conn, error)) (net.Conn, error) {
+	targets := resolver.targets(addr)
+
+	var err error
+	for index, target := range targets {
+		if resolver.verbose && target != addr {
+			fmt.Printf("* [resolve] %s: connecting to %s\n", addr, target)
+		}
+
+		var conn net.Conn
+		if conn, err = dial(target); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if resolver.verbose && index < len(targets)-1 {
+			fmt.Printf("* [resolve] %s failed: %v; trying the next address\n", target, err)
+		}
+	}
+	return nil, err
+}
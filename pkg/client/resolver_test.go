@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewResolverErrors(test *testing.T) {
+	tests := []struct {
+		name      string
+		resolve   []string
+		legacy    string
+		connectTo []string
+	}{
+		{name: "missing address", resolve: []string{"example.com:443"}},
+		{name: "empty address", resolve: []string{"example.com:443:"}},
+		{name: "empty address in list", resolve: []string{"example.com:443:127.0.0.1,,127.0.0.2"}},
+		{name: "missing port", resolve: []string{"example.com::127.0.0.1"}},
+		{name: "invalid port", resolve: []string{"example.com:https:127.0.0.1"}},
+		{name: "port out of range", resolve: []string{"example.com:70000:127.0.0.1"}},
+		{name: "unclosed IPv6 address", resolve: []string{"example.com:443:[::1"}},
+		{name: "unbracketed IPv6 host", resolve: []string{"::1:443:127.0.0.1"}},
+		{name: "connect-to too few fields", connectTo: []string{"example.com:443:backend"}},
+		{name: "connect-to too many fields", connectTo: []string{"example.com:443:backend:8443:1"}},
+		{name: "connect-to invalid port", connectTo: []string{"example.com:443:backend:http"}},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			if _, err := newResolver(tt.resolve, tt.connectTo); err == nil {
+				t.Error("newResolver() expected error")
+			}
+		})
+	}
+}
+
+func TestResolverTargets(test *testing.T) {
+	tests := []struct {
+		name      string
+		resolve   []string
+		connectTo []string
+		addr      string
+		want      []string
+	}{
+		{
+			name: "no overrides",
+			addr: "example.com:443",
+			want: []string{"example.com:443"},
+		},
+		{
+			name:    "single address",
+			resolve: []string{"example.com:443:127.0.0.1"},
+			addr:    "example.com:443",
+			want:    []string{"127.0.0.1:443"},
+		},
+		{
+			name:    "other port is not overridden",
+			resolve: []string{"example.com:443:127.0.0.1"},
+			addr:    "example.com:8443",
+			want:    []string{"example.com:8443"},
+		},
+		{
+			name:    "host names are case-insensitive",
+			resolve: []string{"Example.COM:443:127.0.0.1"},
+			addr:    "example.com:443",
+			want:    []string{"127.0.0.1:443"},
+		},
+		{
+			name:    "several addresses in order",
+			resolve: []string{"example.com:443:10.0.0.1,[::1], 10.0.0.2"},
+			addr:    "example.com:443",
+			want:    []string{"10.0.0.1:443", "[::1]:443", "10.0.0.2:443"},
+		},
+		{
+			name:    "repeated entries add addresses",
+			resolve: []string{"example.com:443:10.0.0.1", "example.com:443:10.0.0.2"},
+			addr:    "example.com:443",
+			want:    []string{"10.0.0.1:443", "10.0.0.2:443"},
+		},
+		{
+			name:    "lazy entry",
+			resolve: []string{"+example.com:443:10.0.0.1"},
+			addr:    "example.com:443",
+			want:    []string{"10.0.0.1:443"},
+		},
+		{
+			name:    "IPv6 host",
+			resolve: []string{"[2001:db8::1]:443:[::1]"},
+			addr:    "[2001:db8::1]:443",
+			want:    []string{"[::1]:443"},
+		},
+		{
+			name:    "wildcard host",
+			resolve: []string{"*:443:10.0.0.1"},
+			addr:    "anything.example.com:443",
+			want:    []string{"10.0.0.1:443"},
+		},
+		{
+			name:    "exact host wins over wildcard",
+			resolve: []string{"*:443:10.0.0.1", "example.com:443:10.0.0.2"},
+			addr:    "example.com:443",
+			want:    []string{"10.0.0.2:443"},
+		},
+		{
+			name:      "connect-to",
+			connectTo: []string{"example.com:443:backend.local:8443"},
+			addr:      "example.com:443",
+			want:      []string{"backend.local:8443"},
+		},
+		{
+			name:      "connect-to keeps empty target fields",
+			connectTo: []string{"example.com:443::8443"},
+			addr:      "example.com:443",
+			want:      []string{"example.com:8443"},
+		},
+		{
+			name:      "connect-to matches any host and port",
+			connectTo: []string{"::[::1]:"},
+			addr:      "example.com:443",
+			want:      []string{"[::1]:443"},
+		},
+		{
+			name:      "first connect-to match applies",
+			connectTo: []string{"other.com:443:a.local:1", "example.com::b.local:2", "::c.local:3"},
+			addr:      "example.com:443",
+			want:      []string{"b.local:2"},
+		},
+		{
+			name:      "resolve applies to the connect-to target",
+			resolve:   []string{"backend.local:8443:10.0.0.1"},
+			connectTo: []string{"example.com:443:backend.local:8443"},
+			addr:      "example.com:443",
+			want:      []string{"10.0.0.1:8443"},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			resolver, err := newResolver(tt.resolve, tt.connectTo)
+			if err != nil {
+				t.Fatalf("newResolver() error = %v", err)
+			}
+			if got := resolver.targets(tt.addr); !slices.Equal(got, tt.want) {
+				t.Errorf("targets(%q) = %q, want %q", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverDialFallback(test *testing.T) {
+	// Listen on 127.0.0.2 only, so the same port refuses connections on
+	// 127.0.0.1
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		test.Skipf("127.0.0.2 is not available: %v", err)
+	}
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	if conn, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+		conn.Close()
+		test.Skipf("127.0.0.1:%s is in use", port)
+	}
+
+	resolver, err := newResolver([]string{"example.com:" + port + ":127.0.0.1,127.0.0.2"}, nil)
+	if err != nil {
+		test.Fatalf("newResolver() error = %v", err)
+	}
+	conn, err := resolver.dialer(time.Second)(context.Background(), "tcp", "example.com:"+port)
+	if err != nil {
+		test.Fatalf("dial error = %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != listener.Addr().String() {
+		test.Errorf("connected to %s, want the fallback %s", got, listener.Addr())
+	}
+
+	// The last error is returned when every address fails
+	resolver, err = newResolver([]string{"example.com:" + port + ":127.0.0.1"}, nil)
+	if err != nil {
+		test.Fatalf("newResolver() error = %v", err)
+	}
+	if _, err := resolver.dialer(time.Second)(context.Background(), "tcp", "example.com:"+port); err == nil {
+		test.Error("dial expected an error")
+	}
+}
+
+func TestClientInvokeResolve(test *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := []struct {
+		name      string
+		resolve   []string
+		legacy    string
+		connectTo []string
+	}{
+		{
+			name:    "wildcard resolve",
+			resolve: []string{"*:" + port + ":[::1],127.0.0.1"},
+		},
+		{
+			name:      "connect-to",
+			connectTo: []string{"api.example.com:" + port + ":127.0.0.1:"},
+		},
+		{
+			name:      "connect-to with resolve",
+			resolve:   []string{"backend.test:" + port + ":127.0.0.1"},
+			connectTo: []string{"api.example.com::backend.test:"},
+		},
+		{
+			name:    "deprecated single resolve with resolves",
+			resolve: []string{"other.test:" + port + ":192.0.2.1"},
+			legacy:  "api.example.com:" + port + ":127.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			invokeProxyTest(t, "http://api.example.com:"+port, &Options{Resolves: tt.resolve, Resolve: tt.legacy, ConnectTo: tt.connectTo})
+			if host != "api.example.com:"+port {
+				t.Errorf("Host = %q, want the original api.example.com:%s", host, port)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// HeaderRetryPushback is the trailer a server uses to tell the client how
+// long to wait before retrying, in milliseconds. A negative or malformed
+// value tells the client not to retry.
+const HeaderRetryPushback = "grpc-retry-pushback-ms"
+
+// RetryPolicy configures automatic retries of failed calls, modeled on the
+// retry policy of the gRPC service config.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Each further retry
+	// waits BackoffMultiplier times longer, up to MaxBackoff.
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+
+	// Jitter randomly shortens each delay by up to this fraction (e.g., 0.2
+	// for 80-100% of the backoff). A jitter of 1 picks a delay between zero
+	// and the backoff, as gRPC clients do.
+	Jitter float64
+
+	// RetryableCodes lists the gRPC status codes that are retried. Transport
+	// errors, such as a reset connection, count as UNAVAILABLE.
+	RetryableCodes []int
+}
+
+// DefaultRetryPolicy returns a policy that retries UNAVAILABLE up to
+// maxAttempts attempts in total.
+func DefaultRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       maxAttempts,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        5 * time.Second,
+		BackoffMultiplier: 2,
+		Jitter:            0.2,
+		RetryableCodes:    []int{protocol.StatusUnavailable},
+	}
+}
+
+// backoff returns the delay before the given retry, counting from 1.
+func (policy *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := policy.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+
+	backoff := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		backoff *= 1 - policy.Jitter*rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// retryable reports whether a status code is retried by the policy.
+func (policy *RetryPolicy) retryable(code int) bool {
+	return slices.Contains(policy.RetryableCodes, code)
+}
+
+// retryDelay decides whether a failed attempt is retried and how long to wait
+// first. Server pushback takes precedence over RetryInfo details, which take
+// precedence over the policy's backoff.
+func (policy *RetryPolicy) retryDelay(resp *Response, retry int) (time.Duration, bool) {
+	if !policy.retryable(resp.Status.Code) {
+		return 0, false
+	}
+
+	if resp.Trailers.Has(HeaderRetryPushback) {
+		pushback, err := strconv.Atoi(resp.Trailers.Get(HeaderRetryPushback))
+		if err != nil || pushback < 0 {
+			return 0, false
+		}
+		return time.Duration(pushback) * time.Millisecond, true
+	}
+
+	for _, detail := range resp.Status.Details {
+		var info errdetails.RetryInfo
+		if detail.MessageIs(&info) && detail.UnmarshalTo(&info) == nil && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+
+	return policy.backoff(retry), true
+}
+
+// attemptFunc makes one attempt of a call. commit must be called before the
+// attempt delivers data to the caller, such as a streamed message; once an
+// attempt has committed it is never retried. commit returns false if another
+// hedged attempt has already committed, and the attempt must then stop.
+type attemptFunc func(ctx context.Context, commit func() bool) (*Response, error)
+
+// call runs attempt with the client's credentials under the policies for the
+// request's method: the method config from the service config if one matches,
+// otherwise the client's retry policy.
+func (client *Client) call(ctx context.Context, req *Request, attempt attemptFunc) (*Response, error) {
+	attempt = client.withCredentials(req, attempt)
+
+	config := client.serviceConfig.MethodConfig(req.Service, req.Method)
+	if config == nil {
+		return client.withRetry(ctx, client.retry, attempt)
+	}
+
+	if client.verbose {
+		fmt.Printf("* [service config] /%s/%s: %s\n", req.Service, req.Method, config)
+	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	if config.WaitForReady {
+		attempt = client.waitForReady(attempt)
+	}
+
+	switch {
+	case config.Hedging != nil:
+		return client.withHedging(ctx, config.Hedging, attempt)
+	case config.Retry != nil:
+		return client.withRetry(ctx, config.Retry, attempt)
+	default:
+		return client.withRetry(ctx, client.retry, attempt)
+	}
+}
+
+// attemptStatus returns the response of a failed attempt for the retry and
+// hedging policies to judge. Timeouts of the attempt count as
+// DEADLINE_EXCEEDED, and transport errors, including the connection failing
+// while the response is read, as UNAVAILABLE. Other errors, such as malformed
+// responses, handler errors and CORS failures, and errors after the call's
+// context is done, are final and yield nil.
+func attemptStatus(ctx context.Context, resp *Response, err error) *Response {
+	var corsErr *CORSError
+	switch {
+	case err == nil:
+		return resp
+	case ctx.Err() != nil || errors.As(err, &corsErr):
+		return nil
+	case isTimeout(err):
+		return &Response{Status: &protocol.Status{Code: protocol.StatusDeadlineExceeded, Message: err.Error()}}
+	case isTransportError(err):
+		return &Response{Status: &protocol.Status{Code: protocol.StatusUnavailable, Message: err.Error()}}
+	default:
+		return nil
+	}
+}
+
+// isTimeout reports whether err is a timeout, such as the HTTP client's
+// timeout for an attempt, anywhere in its chain.
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// isTransportError reports whether err is the connection failing: a failed
+// dial, or the connection being reset or closed while the request is sent or
+// the response is read. Other errors of the HTTP client, such as TLS, proxy
+// and unsupported scheme errors, are not transient.
+func isTransportError(err error) bool {
+	if isTLSError(err) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed)
+}
+
+// isTLSError reports whether err is a failed TLS handshake or certificate
+// verification, including a --pinnedpubkey mismatch, which retrying cannot fix.
+func isTLSError(err error) bool {
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &alertErr) || errors.As(err, &verifyErr) || errors.As(err, &recordErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		errors.Is(err, errPinnedPubKey)
+}
+
+// withRetry runs attempt until it succeeds, fails with a status or error the
+// retry policy does not cover, commits, or runs out of attempts.
+func (client *Client) withRetry(ctx context.Context, policy *RetryPolicy, attempt attemptFunc) (*Response, error) {
+	committed := false
+	commit := func() bool {
+		committed = true
+		return true
+	}
+
+	for number := 1; ; number++ {
+		resp, err := attempt(ctx, commit)
+		if policy == nil || policy.MaxAttempts < 2 || committed {
+			return resp, err
+		}
+
+		status := attemptStatus(ctx, resp, err)
+		if status == nil || status.Status == nil || status.Status.Code == protocol.StatusOK {
+			return resp, err
+		}
+
+		delay, retry := policy.retryDelay(status, number)
+		if !retry || number >= policy.MaxAttempts {
+			if client.verbose {
+				fmt.Printf("* [retry] attempt %d of %d failed with %s; giving up\n",
+					number, policy.MaxAttempts, protocol.StatusName(status.Status.Code))
+			}
+			return resp, err
+		}
+
+		if client.verbose {
+			fmt.Printf("* [retry] attempt %d of %d failed with %s: %s; retrying in %s\n",
+				number, policy.MaxAttempts, protocol.StatusName(status.Status.Code), status.Status.Message, delay)
+		}
+
+		if !sleep(ctx, delay) {
+			return resp, err
+		}
+	}
+}
+
+// sleep waits for delay, returning false if the context is done first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
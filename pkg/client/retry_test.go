@@ -0,0 +1,377 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// flakyServer fails the first failures requests with fail and then answers
+// with an OK status and one message. It returns the server and the number of
+// requests received.
+func flakyServer(test *testing.T, failures int32, fail http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			fail(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		encoder := protocol.NewEncoder(w)
+		encoder.Encode([]byte{0x08, 0x01})
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+	}))
+	test.Cleanup(server.Close)
+	return server, &requests
+}
+
+// unavailable responds with a trailers-only UNAVAILABLE status.
+func unavailable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Grpc-Status", "14")
+	w.Header().Set("Grpc-Message", "try again")
+}
+
+// testRetryPolicy returns a policy with short backoffs for tests.
+func testRetryPolicy(maxAttempts int) *RetryPolicy {
+	policy := DefaultRetryPolicy(maxAttempts)
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func invokeTest(test *testing.T, server *httptest.Server, policy *RetryPolicy) *Response {
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Retry: policy})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Flaky",
+		Message: []byte{},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	return resp
+}
+
+func TestInvokeRetry(test *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		fail         http.HandlerFunc
+		policy       *RetryPolicy
+		wantCode     int
+		wantRequests int32
+	}{
+		{
+			name:         "succeeds after retries",
+			failures:     2,
+			fail:         unavailable,
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusOK,
+			wantRequests: 3,
+		},
+		{
+			name:         "gives up after max attempts",
+			failures:     5,
+			fail:         unavailable,
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusUnavailable,
+			wantRequests: 3,
+		},
+		{
+			name:         "no policy",
+			failures:     1,
+			fail:         unavailable,
+			wantCode:     protocol.StatusUnavailable,
+			wantRequests: 1,
+		},
+		{
+			name:     "non-retryable code",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Grpc-Status", "3")
+			},
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusInvalidArgument,
+			wantRequests: 1,
+		},
+		{
+			name:         "HTTP 503 maps to UNAVAILABLE",
+			failures:     1,
+			fail:         func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name:     "connection reset",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				// Closing with a zero linger sends a TCP reset
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.(*net.TCPConn).SetLinger(0)
+					conn.Close()
+				}
+			},
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name:     "connection lost while reading the response",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				// The body ends in the middle of the first frame
+				w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+				w.Header().Set("Content-Length", "100")
+				w.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x50, 0x08})
+			},
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name:     "negative pushback stops retries",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				unavailable(w, r)
+				w.Header().Set(HeaderRetryPushback, "-1")
+			},
+			policy:       testRetryPolicy(3),
+			wantCode:     protocol.StatusUnavailable,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			server, requests := flakyServer(t, tt.failures, tt.fail)
+
+			resp := invokeTest(t, server, tt.policy)
+			if resp.Status.Code != tt.wantCode {
+				t.Errorf("Status code = %d, want %d", resp.Status.Code, tt.wantCode)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestInvokeRetryTimeout(test *testing.T) {
+	// The HTTP client's timeout ends the attempt, which is not retried
+	release := make(chan struct{})
+	defer close(release)
+	server, requests := flakyServer(test, 1, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Retry: testRetryPolicy(3), Timeout: 50 * time.Millisecond})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	_, err = client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Flaky", Message: []byte{}})
+	if err == nil {
+		test.Fatal("Invoke() expected a timeout error")
+	}
+	if status := attemptStatus(context.Background(), nil, err); status == nil || status.Status.Code != protocol.StatusDeadlineExceeded {
+		test.Errorf("attemptStatus(%v) = %v, want DEADLINE_EXCEEDED", err, status)
+	}
+	if got := requests.Load(); got != 1 {
+		test.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestInvokeRetryTLSErrors(test *testing.T) {
+	// Each handshake is one attempt; the handler is never reached
+	var handshakes atomic.Int32
+	server, _, _ := tlsServer(test, func(config *tls.Config) {
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			handshakes.Add(1)
+			return nil, nil
+		}
+	})
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "untrusted certificate", opts: Options{Retry: testRetryPolicy(3)}},
+		{
+			name: "pinned public key mismatch",
+			opts: Options{Insecure: true, PinnedPubKey: "sha256//" + strings.Repeat("A", 43) + "=", Retry: testRetryPolicy(3)},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			handshakes.Store(0)
+			opts := tt.opts
+			client, err := NewClient(server.URL, &opts)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Flaky", Message: []byte{}}); err == nil {
+				t.Fatal("Invoke() expected a TLS error")
+			}
+			if got := handshakes.Load(); got != 1 {
+				t.Errorf("server received %d handshakes, want 1", got)
+			}
+		})
+	}
+}
+
+func TestInvokeRetryPushback(test *testing.T) {
+	server, requests := flakyServer(test, 1, func(w http.ResponseWriter, r *http.Request) {
+		unavailable(w, r)
+		w.Header().Set(HeaderRetryPushback, "50")
+	})
+
+	start := time.Now()
+	resp := invokeTest(test, server, testRetryPolicy(2))
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if requests.Load() != 2 {
+		test.Errorf("server received %d requests, want 2", requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		test.Errorf("retried after %s, want at least the 50ms pushback", elapsed)
+	}
+}
+
+func TestInvokeRetryInfo(test *testing.T) {
+	retryInfo, err := anypb.New(&errdetails.RetryInfo{RetryDelay: durationpb.New(50 * time.Millisecond)})
+	if err != nil {
+		test.Fatalf("anypb.New() error = %v", err)
+	}
+	details, err := proto.Marshal(&spb.Status{Code: 14, Message: "overloaded", Details: []*anypb.Any{retryInfo}})
+	if err != nil {
+		test.Fatalf("proto.Marshal() error = %v", err)
+	}
+
+	server, requests := flakyServer(test, 1, func(w http.ResponseWriter, r *http.Request) {
+		unavailable(w, r)
+		w.Header().Set("Grpc-Status-Details-Bin", protocol.EncodeBinaryHeader(details))
+	})
+
+	start := time.Now()
+	resp := invokeTest(test, server, testRetryPolicy(2))
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if requests.Load() != 2 {
+		test.Errorf("server received %d requests, want 2", requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		test.Errorf("retried after %s, want at least the 50ms RetryInfo delay", elapsed)
+	}
+}
+
+func TestInvokeServerStreamRetry(test *testing.T) {
+	server, requests := flakyServer(test, 1, unavailable)
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Retry: testRetryPolicy(3)})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	received := 0
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Flaky",
+		Message: []byte{},
+	}, func(message []byte) error {
+		received++
+		return nil
+	})
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if requests.Load() != 2 || received != 1 {
+		test.Errorf("requests = %d, messages = %d, want 2 and 1", requests.Load(), received)
+	}
+}
+
+func TestInvokeServerStreamNoRetryAfterMessages(test *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", protocol.ContentTypeGRPCWeb)
+		encoder := protocol.NewEncoder(w)
+		encoder.Encode([]byte{0x08, 0x01})
+		encoder.EncodeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("grpc-status: 14\r\n")})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Retry: testRetryPolicy(3)})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.InvokeServerStream(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Flaky",
+		Message: []byte{},
+	}, nil)
+	if err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusUnavailable {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusUnavailable)
+	}
+	if requests.Load() != 1 {
+		test.Errorf("server received %d requests, want 1 (stream was committed)", requests.Load())
+	}
+}
+
+func TestRetryPolicyBackoff(test *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		BackoffMultiplier: 2,
+	}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{retry: 1, want: 100 * time.Millisecond},
+		{retry: 2, want: 200 * time.Millisecond},
+		{retry: 4, want: 800 * time.Millisecond},
+		{retry: 5, want: time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.backoff(tt.retry); got != tt.want {
+			test.Errorf("backoff(%d) = %s, want %s", tt.retry, got, tt.want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := policy.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			test.Errorf("backoff(1) with jitter = %s, want within 50ms-100ms", got)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// maxServiceConfigAttempts is the limit gRPC clients place on maxAttempts in
+// retry and hedging policies; larger values are lowered to it.
+const maxServiceConfigAttempts = 5
+
+// ServiceConfig holds the method configuration of a gRPC service config.
+// Other service config fields, such as load balancing and retry throttling,
+// do not apply to a single command-line call and are ignored.
+type ServiceConfig struct {
+	MethodConfigs []*MethodConfig
+}
+
+// MethodConfig is the configuration applied to the methods it names.
+type MethodConfig struct {
+	Names        []MethodName
+	Timeout      time.Duration // Deadline for the whole call, including retries
+	WaitForReady bool          // Keep retrying connection failures until the deadline
+	Retry        *RetryPolicy
+	Hedging      *HedgingPolicy
+}
+
+// MethodName selects the methods a MethodConfig applies to. An empty Method
+// matches every method of Service, and an empty Service matches every method.
+type MethodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+// serviceConfigJSON is the JSON form of a service config.
+type serviceConfigJSON struct {
+	MethodConfig []methodConfigJSON `json:"methodConfig"`
+}
+
+type methodConfigJSON struct {
+	Name          []MethodName       `json:"name"`
+	Timeout       string             `json:"timeout"`
+	WaitForReady  bool               `json:"waitForReady"`
+	RetryPolicy   *retryPolicyJSON   `json:"retryPolicy"`
+	HedgingPolicy *hedgingPolicyJSON `json:"hedgingPolicy"`
+}
+
+type retryPolicyJSON struct {
+	MaxAttempts          int               `json:"maxAttempts"`
+	InitialBackoff       string            `json:"initialBackoff"`
+	MaxBackoff           string            `json:"maxBackoff"`
+	BackoffMultiplier    float64           `json:"backoffMultiplier"`
+	RetryableStatusCodes []json.RawMessage `json:"retryableStatusCodes"`
+}
+
+type hedgingPolicyJSON struct {
+	MaxAttempts         int               `json:"maxAttempts"`
+	HedgingDelay        string            `json:"hedgingDelay"`
+	NonFatalStatusCodes []json.RawMessage `json:"nonFatalStatusCodes"`
+}
+
+// LoadServiceConfig reads a service config JSON file.
+func LoadServiceConfig(path string) (*ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service config: %w", err)
+	}
+	config, err := ParseServiceConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseServiceConfig parses a service config JSON document, validating it the
+// way gRPC clients do.
+func ParseServiceConfig(data []byte) (*ServiceConfig, error) {
+	var raw serviceConfigJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid service config: %w", err)
+	}
+
+	config := &ServiceConfig{}
+	seen := make(map[MethodName]bool)
+	for index, rawMethod := range raw.MethodConfig {
+		method, err := parseMethodConfig(rawMethod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service config: methodConfig[%d]: %w", index, err)
+		}
+		for _, name := range method.Names {
+			if seen[name] {
+				return nil, fmt.Errorf("invalid service config: methodConfig[%d]: duplicate name %+v", index, name)
+			}
+			seen[name] = true
+		}
+		config.MethodConfigs = append(config.MethodConfigs, method)
+	}
+	return config, nil
+}
+
+// parseMethodConfig converts and validates one methodConfig entry.
+func parseMethodConfig(raw methodConfigJSON) (*MethodConfig, error) {
+	for _, name := range raw.Name {
+		if name.Service == "" && name.Method != "" {
+			return nil, fmt.Errorf("name with method %q has no service", name.Method)
+		}
+	}
+
+	config := &MethodConfig{Names: raw.Name, WaitForReady: raw.WaitForReady}
+	var err error
+	if raw.Timeout != "" {
+		if config.Timeout, err = parseDuration(raw.Timeout); err != nil {
+			return nil, fmt.Errorf("timeout: %w", err)
+		}
+	}
+
+	if raw.RetryPolicy != nil && raw.HedgingPolicy != nil {
+		return nil, fmt.Errorf("retryPolicy and hedgingPolicy are mutually exclusive")
+	}
+	if raw.RetryPolicy != nil {
+		if config.Retry, err = raw.RetryPolicy.policy(); err != nil {
+			return nil, fmt.Errorf("retryPolicy: %w", err)
+		}
+	}
+	if raw.HedgingPolicy != nil {
+		if config.Hedging, err = raw.HedgingPolicy.policy(); err != nil {
+			return nil, fmt.Errorf("hedgingPolicy: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// policy converts a retryPolicy entry. Delays use full jitter, as in gRPC.
+func (raw *retryPolicyJSON) policy() (*RetryPolicy, error) {
+	if raw.MaxAttempts < 2 {
+		return nil, fmt.Errorf("maxAttempts must be greater than 1")
+	}
+
+	policy := &RetryPolicy{
+		MaxAttempts:       min(raw.MaxAttempts, maxServiceConfigAttempts),
+		BackoffMultiplier: raw.BackoffMultiplier,
+		Jitter:            1,
+	}
+	var err error
+	if policy.InitialBackoff, err = parseDuration(raw.InitialBackoff); err != nil || policy.InitialBackoff <= 0 {
+		return nil, fmt.Errorf("initialBackoff must be a positive duration")
+	}
+	if policy.MaxBackoff, err = parseDuration(raw.MaxBackoff); err != nil || policy.MaxBackoff <= 0 {
+		return nil, fmt.Errorf("maxBackoff must be a positive duration")
+	}
+	if policy.BackoffMultiplier <= 0 {
+		return nil, fmt.Errorf("backoffMultiplier must be greater than 0")
+	}
+	if len(raw.RetryableStatusCodes) == 0 {
+		return nil, fmt.Errorf("retryableStatusCodes must not be empty")
+	}
+	if policy.RetryableCodes, err = parseStatusCodes(raw.RetryableStatusCodes); err != nil {
+		return nil, fmt.Errorf("retryableStatusCodes: %w", err)
+	}
+	return policy, nil
+}
+
+// policy converts a hedgingPolicy entry.
+func (raw *hedgingPolicyJSON) policy() (*HedgingPolicy, error) {
+	if raw.MaxAttempts < 2 {
+		return nil, fmt.Errorf("maxAttempts must be greater than 1")
+	}
+
+	policy := &HedgingPolicy{MaxAttempts: min(raw.MaxAttempts, maxServiceConfigAttempts)}
+	var err error
+	if raw.HedgingDelay != "" {
+		if policy.HedgingDelay, err = parseDuration(raw.HedgingDelay); err != nil {
+			return nil, fmt.Errorf("hedgingDelay: %w", err)
+		}
+	}
+	if policy.NonFatalCodes, err = parseStatusCodes(raw.NonFatalStatusCodes); err != nil {
+		return nil, fmt.Errorf("nonFatalStatusCodes: %w", err)
+	}
+	return policy, nil
+}
+
+// parseStatusCodes parses status codes given as names ("UNAVAILABLE") or
+// numbers (14).
+func parseStatusCodes(raw []json.RawMessage) ([]int, error) {
+	var codes []int
+	for _, value := range raw {
+		var name string
+		if err := json.Unmarshal(value, &name); err == nil {
+			code, ok := protocol.StatusCode(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown status code %q", name)
+			}
+			codes = append(codes, code)
+			continue
+		}
+
+		var code int
+		if err := json.Unmarshal(value, &code); err != nil || code < protocol.StatusOK || code > protocol.StatusUnauthenticated {
+			return nil, fmt.Errorf("invalid status code %s", value)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// parseDuration parses a protobuf JSON duration such as "1.5s".
+func parseDuration(value string) (time.Duration, error) {
+	seconds, ok := strings.CutSuffix(value, "s")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q: must be seconds with an s suffix", value)
+	}
+	parsed, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return time.Duration(parsed * float64(time.Second)), nil
+}
+
+// MethodConfig returns the configuration for a method: an entry naming the
+// method exactly, else one naming its service, else the default entry with an
+// empty name. Returns nil if none applies.
+func (config *ServiceConfig) MethodConfig(service, method string) *MethodConfig {
+	if config == nil {
+		return nil
+	}
+
+	for _, candidate := range []MethodName{{service, method}, {service, ""}, {"", ""}} {
+		for _, methodConfig := range config.MethodConfigs {
+			for _, name := range methodConfig.Names {
+				if name == candidate {
+					return methodConfig
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// String summarizes the configuration for verbose output.
+func (config *MethodConfig) String() string {
+	var parts []string
+	if config.Timeout > 0 {
+		parts = append(parts, "timeout "+config.Timeout.String())
+	}
+	if config.WaitForReady {
+		parts = append(parts, "wait for ready")
+	}
+	if config.Retry != nil {
+		parts = append(parts, fmt.Sprintf("retry up to %d attempts", config.Retry.MaxAttempts))
+	}
+	if config.Hedging != nil {
+		parts = append(parts, fmt.Sprintf("hedge up to %d attempts every %s", config.Hedging.MaxAttempts, config.Hedging.HedgingDelay))
+	}
+	if len(parts) == 0 {
+		return "no policies"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+const testServiceConfig = `{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "methodConfig": [
+    {
+      "name": [{"service": "test.Service", "method": "Flaky"}],
+      "timeout": "1.5s",
+      "retryPolicy": {
+        "maxAttempts": 9,
+        "initialBackoff": "0.001s",
+        "maxBackoff": "0.005s",
+        "backoffMultiplier": 2,
+        "retryableStatusCodes": ["UNAVAILABLE", 8]
+      }
+    },
+    {
+      "name": [{"service": "test.Service"}],
+      "waitForReady": true,
+      "hedgingPolicy": {
+        "maxAttempts": 3,
+        "hedgingDelay": "0.5s",
+        "nonFatalStatusCodes": ["UNAVAILABLE"]
+      }
+    },
+    {
+      "name": [{}],
+      "timeout": "10s"
+    }
+  ]
+}`
+
+func TestParseServiceConfig(test *testing.T) {
+	config, err := ParseServiceConfig([]byte(testServiceConfig))
+	if err != nil {
+		test.Fatalf("ParseServiceConfig() error = %v", err)
+	}
+	if len(config.MethodConfigs) != 3 {
+		test.Fatalf("got %d method configs, want 3", len(config.MethodConfigs))
+	}
+
+	method := config.MethodConfigs[0]
+	if method.Timeout != 1500*time.Millisecond {
+		test.Errorf("Timeout = %s, want 1.5s", method.Timeout)
+	}
+	retry := method.Retry
+	if retry == nil {
+		test.Fatal("Retry is nil")
+	}
+	if retry.MaxAttempts != maxServiceConfigAttempts {
+		test.Errorf("MaxAttempts = %d, want it capped at %d", retry.MaxAttempts, maxServiceConfigAttempts)
+	}
+	if retry.InitialBackoff != time.Millisecond || retry.MaxBackoff != 5*time.Millisecond || retry.BackoffMultiplier != 2 {
+		test.Errorf("backoff = %s/%s/%v, want 1ms/5ms/2", retry.InitialBackoff, retry.MaxBackoff, retry.BackoffMultiplier)
+	}
+	if len(retry.RetryableCodes) != 2 || retry.RetryableCodes[0] != protocol.StatusUnavailable || retry.RetryableCodes[1] != protocol.StatusResourceExhausted {
+		test.Errorf("RetryableCodes = %v, want [14 8]", retry.RetryableCodes)
+	}
+
+	hedging := config.MethodConfigs[1].Hedging
+	if hedging == nil {
+		test.Fatal("Hedging is nil")
+	}
+	if hedging.MaxAttempts != 3 || hedging.HedgingDelay != 500*time.Millisecond || len(hedging.NonFatalCodes) != 1 {
+		test.Errorf("Hedging = %+v, want 3 attempts every 500ms", hedging)
+	}
+	if !config.MethodConfigs[1].WaitForReady {
+		test.Error("WaitForReady = false, want true")
+	}
+}
+
+func TestParseServiceConfigErrors(test *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "invalid JSON",
+			config:  `{"methodConfig": [`,
+			wantErr: "invalid service config",
+		},
+		{
+			name:    "method without service",
+			config:  `{"methodConfig": [{"name": [{"method": "Get"}]}]}`,
+			wantErr: "has no service",
+		},
+		{
+			name:    "duplicate name",
+			config:  `{"methodConfig": [{"name": [{"service": "a.B"}]}, {"name": [{"service": "a.B"}]}]}`,
+			wantErr: "duplicate name",
+		},
+		{
+			name:    "bad timeout",
+			config:  `{"methodConfig": [{"name": [{}], "timeout": "5m"}]}`,
+			wantErr: "timeout",
+		},
+		{
+			name: "retry and hedging",
+			config: `{"methodConfig": [{"name": [{}],
+				"retryPolicy": {"maxAttempts": 2, "initialBackoff": "1s", "maxBackoff": "1s", "backoffMultiplier": 1, "retryableStatusCodes": ["UNAVAILABLE"]},
+				"hedgingPolicy": {"maxAttempts": 2}}]}`,
+			wantErr: "mutually exclusive",
+		},
+		{
+			name:    "too few attempts",
+			config:  `{"methodConfig": [{"name": [{}], "hedgingPolicy": {"maxAttempts": 1}}]}`,
+			wantErr: "maxAttempts",
+		},
+		{
+			name: "no retryable codes",
+			config: `{"methodConfig": [{"name": [{}],
+				"retryPolicy": {"maxAttempts": 2, "initialBackoff": "1s", "maxBackoff": "1s", "backoffMultiplier": 1}}]}`,
+			wantErr: "retryableStatusCodes",
+		},
+		{
+			name: "unknown status code",
+			config: `{"methodConfig": [{"name": [{}],
+				"retryPolicy": {"maxAttempts": 2, "initialBackoff": "1s", "maxBackoff": "1s", "backoffMultiplier": 1, "retryableStatusCodes": ["FLAKY"]}}]}`,
+			wantErr: "unknown status code",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			_, err := ParseServiceConfig([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseServiceConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServiceConfigMethodConfig(test *testing.T) {
+	config, err := ParseServiceConfig([]byte(testServiceConfig))
+	if err != nil {
+		test.Fatalf("ParseServiceConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		service string
+		method  string
+		want    *MethodConfig
+	}{
+		{service: "test.Service", method: "Flaky", want: config.MethodConfigs[0]},
+		{service: "test.Service", method: "Other", want: config.MethodConfigs[1]},
+		{service: "other.Service", method: "Flaky", want: config.MethodConfigs[2]},
+	}
+	for _, tt := range tests {
+		if got := config.MethodConfig(tt.service, tt.method); got != tt.want {
+			test.Errorf("MethodConfig(%q, %q) = %v, want %v", tt.service, tt.method, got, tt.want)
+		}
+	}
+
+	var empty *ServiceConfig
+	if got := empty.MethodConfig("test.Service", "Flaky"); got != nil {
+		test.Errorf("nil ServiceConfig MethodConfig() = %v, want nil", got)
+	}
+}
+
+func TestLoadServiceConfig(test *testing.T) {
+	path := filepath.Join(test.TempDir(), "service-config.json")
+	if err := os.WriteFile(path, []byte(testServiceConfig), 0o600); err != nil {
+		test.Fatalf("WriteFile() error = %v", err)
+	}
+
+	config, err := LoadServiceConfig(path)
+	if err != nil {
+		test.Fatalf("LoadServiceConfig() error = %v", err)
+	}
+	if len(config.MethodConfigs) != 3 {
+		test.Errorf("got %d method configs, want 3", len(config.MethodConfigs))
+	}
+
+	if _, err := LoadServiceConfig(filepath.Join(test.TempDir(), "missing.json")); err == nil {
+		test.Error("LoadServiceConfig() expected error for a missing file")
+	}
+}
+
+func TestInvokeServiceConfig(test *testing.T) {
+	config, err := ParseServiceConfig([]byte(testServiceConfig))
+	if err != nil {
+		test.Fatalf("ParseServiceConfig() error = %v", err)
+	}
+
+	var timeouts []string
+	server, requests := flakyServer(test, 2, func(w http.ResponseWriter, r *http.Request) {
+		timeouts = append(timeouts, r.Header.Get("Grpc-Timeout"))
+		w.Header().Set("Grpc-Status", "8")
+	})
+
+	// The client's own retry policy does not cover RESOURCE_EXHAUSTED; the
+	// method config's does, and its timeout bounds the call
+	client, err := NewClient(server.URL, &Options{Plaintext: true, Retry: testRetryPolicy(2), ServiceConfig: config})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Flaky",
+		Message: []byte{},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if requests.Load() != 3 {
+		test.Errorf("server received %d requests, want 3", requests.Load())
+	}
+
+	for _, value := range timeouts {
+		timeout, err := protocol.ParseTimeout(value)
+		if err != nil || timeout > 1500*time.Millisecond || timeout < time.Second {
+			test.Errorf("grpc-timeout = %q, want the 1.5s method timeout", value)
+		}
+	}
+}
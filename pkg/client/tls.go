@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// pinPrefix starts a public key pin in curl's --pinned-pubkey syntax.
+const pinPrefix = "sha256//"
+
+// tlsVersions maps TLS version names, without a TLS or TLSv prefix, to
+// versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// configureTLS sets up TLS configuration based on options.
+func configureTLS(opts *Options) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.Insecure,
+		NextProtos:         opts.ALPN,
+	}
+
+	if opts.ServerName != "" {
+		tlsConfig.ServerName = opts.ServerName
+	}
+
+	// Restrict the protocol versions and cipher suites
+	var err error
+	if opts.TLSMinVersion != "" {
+		if tlsConfig.MinVersion, err = parseTLSVersion(opts.TLSMinVersion); err != nil {
+			return nil, fmt.Errorf("invalid minimum TLS version: %w", err)
+		}
+	}
+	if opts.TLSMaxVersion != "" {
+		if tlsConfig.MaxVersion, err = parseTLSVersion(opts.TLSMaxVersion); err != nil {
+			return nil, fmt.Errorf("invalid maximum TLS version: %w", err)
+		}
+	}
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return nil, fmt.Errorf("minimum TLS version %s is above the maximum %s",
+			tls.VersionName(tlsConfig.MinVersion), tls.VersionName(tlsConfig.MaxVersion))
+	}
+	if len(opts.CipherSuites) > 0 {
+		if tlsConfig.CipherSuites, err = parseCipherSuites(opts.CipherSuites); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load client certificate if provided
+	if opts.CertFile != "" {
+		cert, err := loadClientCertificate(opts.CertFile, opts.KeyFile, opts.CertPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Trust the CA certificates in addition to the system's
+	if caFiles := withDeprecated(opts.CAFiles, opts.CAFile); len(caFiles) > 0 {
+		if tlsConfig.RootCAs, err = loadCertPool(caFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	// Log session keys so captured traffic can be decrypted, e.g. in Wireshark
+	keyLogFile := opts.KeyLogFile
+	if keyLogFile == "" {
+		keyLogFile = os.Getenv("SSLKEYLOGFILE")
+	}
+	if keyLogFile != "" {
+		file, err := os.OpenFile(keyLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key log file: %w", err)
+		}
+		tlsConfig.KeyLogWriter = file
+	}
+
+	// Check the server's public key after the usual verification, or instead
+	// of it with Insecure
+	if opts.PinnedPubKey != "" {
+		pins, err := parsePinnedPubKey(opts.PinnedPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pinned public key: %w", err)
+		}
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPinnedPubKey(rawCerts, pins)
+		}
+	}
+
+	if opts.Verbose {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			logHandshake(state)
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseTLSVersion parses a TLS version such as 1.2, TLS1.2 or TLSv1.2.
+func parseTLSVersion(name string) (uint16, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	normalized = strings.TrimPrefix(strings.TrimPrefix(normalized, "tls"), "v")
+	version, ok := tlsVersions[normalized]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q: must be 1.0, 1.1, 1.2 or 1.3", name)
+	}
+	return version, nil
+}
+
+// parseCipherSuites parses cipher suite names as used by Go and IANA, such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure suites are allowed for
+// reproducing handshake problems. TLS 1.3 suites are not configurable.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		suite, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q (use names such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, fmt.Errorf("cipher suite %s cannot be configured: TLS 1.3 suites are always enabled", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// parsePinnedPubKey parses public key pins in curl's syntax, one or more
+// sha256//<base64> hashes separated by semicolons, or reads a PEM or DER
+// public key file. It returns the SHA-256 hashes of the pinned keys.
+func parsePinnedPubKey(value string) ([][sha256.Size]byte, error) {
+	if !strings.HasPrefix(value, pinPrefix) {
+		return loadPinnedPubKey(value)
+	}
+
+	var pins [][sha256.Size]byte
+	for _, pin := range strings.Split(value, ";") {
+		pin = strings.TrimSpace(pin)
+		encoded, ok := strings.CutPrefix(pin, pinPrefix)
+		if !ok {
+			return nil, fmt.Errorf("%q must start with %s", pin, pinPrefix)
+		}
+		hash, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%q is not a base64 SHA-256 hash", pin)
+		}
+		pins = append(pins, [sha256.Size]byte(hash))
+	}
+	return pins, nil
+}
+
+// loadPinnedPubKey reads a PEM or DER public key file and returns the hash of
+// the key.
+func loadPinnedPubKey(path string) ([][sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	if _, err := x509.ParsePKIXPublicKey(data); err != nil {
+		return nil, fmt.Errorf("%s does not hold a public key: %w", path, err)
+	}
+	return [][sha256.Size]byte{sha256.Sum256(data)}, nil
+}
+
+// errPinnedPubKey is wrapped by the errors of verifyPinnedPubKey.
+var errPinnedPubKey = errors.New("pinned public key")
+
+// verifyPinnedPubKey checks that the public key of the server's certificate
+// matches one of pins, and reports the actual key's hash if it does not.
+func verifyPinnedPubKey(rawCerts [][]byte, pins [][sha256.Size]byte) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("%w: server sent no certificate", errPinnedPubKey)
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPinnedPubKey, err)
+	}
+
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	for _, pin := range pins {
+		if hash == pin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w mismatch: server certificate %s has public key %s", errPinnedPubKey, cert.Subject, publicKeyPin(cert))
+}
+
+// publicKeyPin returns the pin of a certificate's public key in curl's
+// syntax.
+func publicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// loadClientCertificate loads a client certificate and key from PEM files. A
+// certificate file that is not PEM is decoded as PKCS#12 with password,
+// including any intermediate certificates. Without keyFile, a PEM
+// certificate file must hold the key too.
+func loadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if !bytes.Contains(certData, []byte("-----BEGIN")) {
+		key, leaf, chain, err := pkcs12.DecodeChain(certData, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 file %s: %w", certFile, err)
+		}
+		cert := tls.Certificate{
+			Certificate: [][]byte{leaf.Raw},
+			PrivateKey:  key,
+			Leaf:        leaf,
+		}
+		for _, intermediate := range chain {
+			cert.Certificate = append(cert.Certificate, intermediate.Raw)
+		}
+		return cert, nil
+	}
+
+	keyData := certData
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certData, keyData)
+}
+
+// loadCertPool returns the system certificate pool with the certificates in
+// paths added. Each path is a PEM or DER certificate file, or a directory of
+// them; files in a directory that hold no certificates are skipped.
+func loadCertPool(paths []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if !info.IsDir() {
+			if err := appendCertsFromFile(pool, path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate directory: %w", err)
+		}
+		found := false
+		for _, entry := range entries {
+			if !entry.IsDir() && appendCertsFromFile(pool, filepath.Join(path, entry.Name())) == nil {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no CA certificates found in %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// appendCertsFromFile adds the PEM or DER certificates in a file to pool.
+func appendCertsFromFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if pool.AppendCertsFromPEM(data) {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse CA certificate %s: no PEM or DER certificate found", path)
+	}
+	pool.AddCert(cert)
+	return nil
+}
+
+// logHandshake prints the negotiated TLS parameters and a summary of the
+// peer's certificate chain in verbose mode.
+func logHandshake(state tls.ConnectionState) {
+	alpn := state.NegotiatedProtocol
+	if alpn == "" {
+		alpn = "none"
+	}
+	fmt.Printf("* [tls] %s, cipher %s, ALPN %s\n",
+		tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), alpn)
+	if state.ServerName != "" {
+		fmt.Printf("* [tls] server name: %s\n", state.ServerName)
+	}
+
+	for index, cert := range state.PeerCertificates {
+		fmt.Printf("* [tls] certificate %d: %s\n", index, cert.Subject)
+		fmt.Printf("* [tls]   issuer: %s\n", cert.Issuer)
+		fmt.Printf("* [tls]   valid: %s to %s\n", cert.NotBefore.UTC().Format(time.DateOnly), cert.NotAfter.UTC().Format(time.DateOnly))
+		if names := certificateNames(cert); len(names) > 0 {
+			fmt.Printf("* [tls]   names: %s\n", strings.Join(names, ", "))
+		}
+		fmt.Printf("* [tls]   public key: %s\n", publicKeyPin(cert))
+	}
+	if len(state.VerifiedChains) == 0 {
+		fmt.Println("* [tls] certificate chain not verified")
+	}
+	fmt.Println()
+}
+
+// certificateNames returns the DNS names and IP addresses a certificate is
+// valid for.
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
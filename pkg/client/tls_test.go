@@ -0,0 +1,611 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testCertificate is a certificate with its key, signed by a test CA or
+// self-signed.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate creates a certificate for names, signed by parent, or a
+// CA certificate if parent is nil.
+func newTestCertificate(test *testing.T, commonName string, names []string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		test.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		test.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		test.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return &testCertificate{cert: cert, key: key}
+}
+
+// tlsCertificate returns the certificate for a tls.Config.
+func (certificate *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{certificate.cert.Raw}, PrivateKey: certificate.key, Leaf: certificate.cert}
+}
+
+// writePEM writes the certificate, and the key if withKey is set, to a PEM
+// file in dir.
+func (certificate *testCertificate) writePEM(test *testing.T, dir, name string, withKey bool) string {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.cert.Raw})
+	if withKey {
+		der, err := x509.MarshalECPrivateKey(certificate.key)
+		if err != nil {
+			test.Fatalf("MarshalECPrivateKey() error = %v", err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})...)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		test.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+// tlsServer starts an HTTPS server with a certificate for api.test signed by
+// a new CA. It records the connection state of each request and returns the
+// server and the CA.
+func tlsServer(test *testing.T, configure func(*tls.Config)) (*httptest.Server, *testCertificate, <-chan *http.Request) {
+	ca := newTestCertificate(test, "Test CA", nil, nil)
+	serverCert := newTestCertificate(test, "api.test", []string{"api.test", "127.0.0.1"}, ca)
+
+	requests := make(chan *http.Request, 10)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		grpcWebOK(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert.tlsCertificate()}}
+	if configure != nil {
+		configure(server.TLS)
+	}
+	server.StartTLS()
+	test.Cleanup(server.Close)
+	return server, ca, requests
+}
+
+func TestParseTLSVersion(test *testing.T) {
+	tests := []struct {
+		name    string
+		want    uint16
+		wantErr bool
+	}{
+		{name: "1.2", want: tls.VersionTLS12},
+		{name: "1.3", want: tls.VersionTLS13},
+		{name: "TLS1.0", want: tls.VersionTLS10},
+		{name: "tlsv1.1", want: tls.VersionTLS11},
+		{name: "TLSv1.3", want: tls.VersionTLS13},
+		{name: "1.4", wantErr: true},
+		{name: "ssl3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTLSVersion(tt.name)
+		if (err != nil) != tt.wantErr {
+			test.Errorf("parseTLSVersion(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			test.Errorf("parseTLSVersion(%q) = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseCipherSuites(test *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []uint16
+		wantErr string
+	}{
+		{
+			name:  "secure suites",
+			names: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "tls_ecdhe_rsa_with_chacha20_poly1305_sha256"},
+			want:  []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{
+			name:  "insecure suites are allowed",
+			names: []string{"TLS_RSA_WITH_AES_128_CBC_SHA", " "},
+			want:  []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		},
+		{
+			name:    "unknown suite",
+			names:   []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+			wantErr: "unknown cipher suite",
+		},
+		{
+			name:    "TLS 1.3 suite",
+			names:   []string{"TLS_AES_128_GCM_SHA256"},
+			wantErr: "TLS 1.3",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			got, err := parseCipherSuites(tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseCipherSuites() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCipherSuites() error = %v", err)
+			}
+			if len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Errorf("parseCipherSuites() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCertPool(test *testing.T) {
+	dir := test.TempDir()
+	ca := newTestCertificate(test, "Test CA", nil, nil)
+	leaf := newTestCertificate(test, "api.test", []string{"api.test"}, ca)
+
+	certDir := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certDir, 0o700); err != nil {
+		test.Fatalf("Mkdir() error = %v", err)
+	}
+	ca.writePEM(test, certDir, "ca.pem", false)
+	os.WriteFile(filepath.Join(certDir, "README"), []byte("not a certificate"), 0o600)
+
+	derFile := filepath.Join(dir, "ca.der")
+	os.WriteFile(derFile, ca.cert.Raw, 0o600)
+	badFile := filepath.Join(dir, "bad.pem")
+	os.WriteFile(badFile, []byte("not a certificate"), 0o600)
+	emptyDir := filepath.Join(dir, "empty")
+	os.Mkdir(emptyDir, 0o700)
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{name: "PEM file", paths: []string{ca.writePEM(test, dir, "ca.pem", false)}},
+		{name: "DER file", paths: []string{derFile}},
+		{name: "directory", paths: []string{certDir}},
+		{name: "several paths", paths: []string{derFile, certDir}},
+		{name: "missing file", paths: []string{filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "no certificate", paths: []string{badFile}, wantErr: true},
+		{name: "directory without certificates", paths: []string{emptyDir}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			pool, err := loadCertPool(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadCertPool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := leaf.cert.Verify(x509.VerifyOptions{Roots: pool, DNSName: "api.test"}); err != nil {
+				t.Errorf("certificate signed by the CA does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientInvokeTLSOptions(test *testing.T) {
+	server, ca, requests := tlsServer(test, nil)
+	caFile := ca.writePEM(test, test.TempDir(), "ca.pem", false)
+	serverURL := "https://" + server.Listener.Addr().String()
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantVersion uint16
+		wantCipher  uint16
+		wantProto   string
+	}{
+		{
+			name:        "defaults negotiate TLS 1.3 and HTTP/2",
+			opts:        Options{CAFiles: []string{caFile}},
+			wantVersion: tls.VersionTLS13,
+			wantProto:   "HTTP/2.0",
+		},
+		{
+			name: "TLS 1.2 with a cipher suite",
+			opts: Options{
+				CAFiles:       []string{caFile},
+				TLSMaxVersion: "1.2",
+				CipherSuites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			},
+			wantVersion: tls.VersionTLS12,
+			wantCipher:  tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			wantProto:   "HTTP/2.0",
+		},
+		{
+			name:        "ALPN without h2",
+			opts:        Options{CAFiles: []string{caFile}, ALPN: []string{"http/1.1"}},
+			wantVersion: tls.VersionTLS13,
+			wantProto:   "HTTP/1.1",
+		},
+		{
+			name:        "server name override",
+			opts:        Options{CAFiles: []string{caFile}, ServerName: "api.test", TLSMinVersion: "1.3"},
+			wantVersion: tls.VersionTLS13,
+			wantProto:   "HTTP/2.0",
+		},
+		{
+			name:        "deprecated CA file",
+			opts:        Options{CAFile: caFile},
+			wantVersion: tls.VersionTLS13,
+			wantProto:   "HTTP/2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			client, err := NewClient(serverURL, &opts)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+
+			r := <-requests
+			if r.TLS.Version != tt.wantVersion {
+				t.Errorf("TLS version = %s, want %s", tls.VersionName(r.TLS.Version), tls.VersionName(tt.wantVersion))
+			}
+			if tt.wantCipher != 0 && r.TLS.CipherSuite != tt.wantCipher {
+				t.Errorf("cipher suite = %s, want %s", tls.CipherSuiteName(r.TLS.CipherSuite), tls.CipherSuiteName(tt.wantCipher))
+			}
+			if r.Proto != tt.wantProto {
+				t.Errorf("protocol = %s, want %s", r.Proto, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestClientInvokeTLSErrors(test *testing.T) {
+	server, ca, _ := tlsServer(test, func(config *tls.Config) { config.MaxVersion = tls.VersionTLS12 })
+	caFile := ca.writePEM(test, test.TempDir(), "ca.pem", false)
+	serverURL := "https://" + server.Listener.Addr().String()
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "untrusted CA", opts: Options{}},
+		{name: "minimum version above server's", opts: Options{CAFiles: []string{caFile}, TLSMinVersion: "1.3"}},
+		{name: "wrong server name", opts: Options{CAFiles: []string{caFile}, ServerName: "other.test"}},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			client, err := NewClient(serverURL, &opts)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err == nil {
+				t.Error("Invoke() expected a handshake error")
+			}
+		})
+	}
+
+	if _, err := NewClient(serverURL, &Options{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"}); err == nil {
+		test.Error("NewClient() expected error for a minimum version above the maximum")
+	}
+}
+
+func TestClientInvokeClientCertificate(test *testing.T) {
+	clientCA := newTestCertificate(test, "Client CA", nil, nil)
+	clientCert := newTestCertificate(test, "grpcwebcurl", nil, clientCA)
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(clientCA.cert)
+
+	server, ca, requests := tlsServer(test, func(config *tls.Config) {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = clientPool
+	})
+	dir := test.TempDir()
+	caFile := ca.writePEM(test, dir, "ca.pem", false)
+
+	p12, err := pkcs12.Modern2023.Encode(clientCert.key, clientCert.cert, []*x509.Certificate{clientCA.cert}, "secret")
+	if err != nil {
+		test.Fatalf("pkcs12 Encode() error = %v", err)
+	}
+	p12File := filepath.Join(dir, "client.p12")
+	if err := os.WriteFile(p12File, p12, 0o600); err != nil {
+		test.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{
+			name: "PEM certificate and key in one file",
+			opts: Options{CertFile: clientCert.writePEM(test, dir, "client.pem", true)},
+		},
+		{
+			name: "PKCS#12",
+			opts: Options{CertFile: p12File, CertPassword: "secret"},
+		},
+		{
+			name:    "PKCS#12 with wrong password",
+			opts:    Options{CertFile: p12File, CertPassword: "wrong"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.CAFiles = []string{caFile}
+			client, err := NewClient("https://"+server.Listener.Addr().String(), &opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("NewClient() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+
+			r := <-requests
+			if got := r.TLS.PeerCertificates[0].Subject.CommonName; got != "grpcwebcurl" {
+				t.Errorf("client certificate CN = %q, want grpcwebcurl", got)
+			}
+		})
+	}
+}
+
+func TestParsePinnedPubKey(test *testing.T) {
+	dir := test.TempDir()
+	cert := newTestCertificate(test, "api.test", []string{"api.test"}, nil)
+	pin := publicKeyPin(cert.cert)
+	other := publicKeyPin(newTestCertificate(test, "other.test", nil, nil).cert)
+
+	pemFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: cert.cert.RawSubjectPublicKeyInfo}), 0o600)
+	derFile := filepath.Join(dir, "key.der")
+	os.WriteFile(derFile, cert.cert.RawSubjectPublicKeyInfo, 0o600)
+
+	tests := []struct {
+		name      string
+		value     string
+		wantMatch bool
+		wantErr   bool
+	}{
+		{name: "matching hash", value: pin, wantMatch: true},
+		{name: "one of several hashes", value: other + "; " + pin, wantMatch: true},
+		{name: "other hash", value: other},
+		{name: "PEM public key file", value: pemFile, wantMatch: true},
+		{name: "DER public key file", value: derFile, wantMatch: true},
+		{name: "missing prefix", value: pin + ";" + strings.TrimPrefix(other, pinPrefix), wantErr: true},
+		{name: "invalid base64", value: "sha256//not base64", wantErr: true},
+		{name: "wrong hash length", value: "sha256//AAAA", wantErr: true},
+		{name: "certificate is not a public key", value: cert.writePEM(test, dir, "cert.pem", false), wantErr: true},
+		{name: "missing file", value: filepath.Join(dir, "missing.pem"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			pins, err := parsePinnedPubKey(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePinnedPubKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			err = verifyPinnedPubKey([][]byte{cert.cert.Raw}, pins)
+			if (err == nil) != tt.wantMatch {
+				t.Errorf("verifyPinnedPubKey() error = %v, want match %v", err, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestClientInvokePinnedPubKey(test *testing.T) {
+	clientCA := newTestCertificate(test, "Client CA", nil, nil)
+	clientCert := newTestCertificate(test, "grpcwebcurl", nil, clientCA)
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(clientCA.cert)
+
+	server, ca, requests := tlsServer(test, func(config *tls.Config) {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = clientPool
+	})
+	dir := test.TempDir()
+	caFile := ca.writePEM(test, dir, "ca.pem", false)
+	clientFile := clientCert.writePEM(test, dir, "client.pem", true)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	serverPin := publicKeyPin(server.TLS.Certificates[0].Leaf)
+	otherPin := publicKeyPin(ca.cert)
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{
+			name: "matching pin with mTLS and resolve",
+			opts: Options{
+				CAFiles:      []string{caFile},
+				CertFile:     clientFile,
+				Resolves:     []string{"api.test:" + port + ":127.0.0.1"},
+				PinnedPubKey: otherPin + ";" + serverPin,
+			},
+		},
+		{
+			name: "pin replaces verification with insecure",
+			opts: Options{
+				Insecure:     true,
+				Resolves:     []string{"api.test:" + port + ":127.0.0.1"},
+				PinnedPubKey: serverPin,
+			},
+		},
+		{
+			name: "mismatch reports the server's key",
+			opts: Options{
+				CAFiles:      []string{caFile},
+				Resolves:     []string{"api.test:" + port + ":127.0.0.1"},
+				PinnedPubKey: otherPin,
+			},
+			wantErr: "has public key " + serverPin,
+		},
+		{
+			name: "mismatch with insecure",
+			opts: Options{
+				Insecure:     true,
+				Resolves:     []string{"api.test:" + port + ":127.0.0.1"},
+				PinnedPubKey: otherPin,
+			},
+			wantErr: "pinned public key mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			client, err := NewClient("https://api.test:"+port, &opts)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			_, err = client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Invoke() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+			r := <-requests
+			if opts.CertFile != "" && len(r.TLS.PeerCertificates) == 0 {
+				t.Error("server did not receive the client certificate")
+			}
+		})
+	}
+
+	if _, err := NewClient("https://api.test:"+port, &Options{PinnedPubKey: "sha256//AAAA"}); err == nil {
+		test.Error("NewClient() expected error for an invalid pin")
+	}
+}
+
+func TestClientInvokeKeyLogFile(test *testing.T) {
+	server, ca, _ := tlsServer(test, nil)
+	dir := test.TempDir()
+	caFile := ca.writePEM(test, dir, "ca.pem", false)
+
+	tests := []struct {
+		name   string
+		option bool
+	}{
+		{name: "SSLKEYLOGFILE"},
+		{name: "option", option: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			keyLog := filepath.Join(dir, strings.ToLower(tt.name)+".log")
+			opts := Options{CAFiles: []string{caFile}}
+			if tt.option {
+				t.Setenv("SSLKEYLOGFILE", filepath.Join(dir, "unused.log"))
+				opts.KeyLogFile = keyLog
+			} else {
+				t.Setenv("SSLKEYLOGFILE", keyLog)
+			}
+
+			client, err := NewClient("https://"+server.Listener.Addr().String(), &opts)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+
+			data, err := os.ReadFile(keyLog)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if !strings.Contains(string(data), "CLIENT_TRAFFIC_SECRET_0 ") {
+				t.Errorf("key log = %q, want TLS 1.3 traffic secrets", data)
+			}
+
+			// Close releases the file
+			if err := client.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if _, err := client.keyLog.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("key log Write() after Close() error = %v, want %v", err, os.ErrClosed)
+			}
+		})
+	}
+
+	if _, err := NewClient("https://"+server.Listener.Addr().String(), &Options{KeyLogFile: filepath.Join(dir, "missing", "keys.log")}); err == nil {
+		test.Error("NewClient() expected error for an unwritable key log file")
+	}
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// HeaderTraceBin carries the trace context in the binary OpenCensus format
+// still read by some gRPC servers.
+const HeaderTraceBin = "grpc-trace-bin"
+
+// Trace exporters for NewTracerProvider.
+const (
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+// tracerName is the instrumentation scope of the client's spans.
+const tracerName = "github.com/hjames9/grpcwebcurl/pkg/client"
+
+// Span attributes for the messages of a call, named after the OpenTelemetry
+// RPC metrics. Sizes are the total serialized message bytes, uncompressed.
+const (
+	attrRequestsPerRPC  = attribute.Key("rpc.client.requests_per_rpc")
+	attrResponsesPerRPC = attribute.Key("rpc.client.responses_per_rpc")
+	attrRequestSize     = attribute.Key("rpc.client.request.size")
+	attrResponseSize    = attribute.Key("rpc.client.response.size")
+)
+
+// TracingConfig configures NewTracerProvider.
+type TracingConfig struct {
+	Exporter    string        // stdout, otlp, or empty to propagate trace context without exporting spans
+	Endpoint    string        // OTLP/HTTP endpoint URL (default: from OTEL_EXPORTER_OTLP_ENDPOINT, or http://localhost:4318)
+	Writer      io.Writer     // Output of the stdout exporter (default: os.Stdout)
+	ServiceName string        // service.name of the spans (default: grpcwebcurl, or OTEL_SERVICE_NAME)
+	TraceID     trace.TraceID // Trace ID for every new trace, to find calls in a tracing backend; zero for random IDs
+}
+
+// NewTracerProvider returns an OpenTelemetry SDK tracer provider that samples
+// every span and exports it as config sets. Shut the provider down to flush
+// the spans before exiting.
+func NewTracerProvider(ctx context.Context, config TracingConfig) (*sdktrace.TracerProvider, error) {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "grpcwebcurl"
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName), semconv.ServiceVersion(protocol.Version)),
+		resource.WithFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res), sdktrace.WithSampler(sdktrace.AlwaysSample())}
+	if config.TraceID.IsValid() {
+		opts = append(opts, sdktrace.WithIDGenerator(fixedTraceIDGenerator{traceID: config.TraceID}))
+	}
+
+	switch config.Exporter {
+	case "":
+	case TraceExporterStdout:
+		writer := config.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(writer), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	case TraceExporterOTLP:
+		var exporterOpts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q: must be %s or %s", config.Exporter, TraceExporterStdout, TraceExporterOTLP)
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// fixedTraceIDGenerator starts every trace with the same trace ID, and gives
+// spans random IDs.
+type fixedTraceIDGenerator struct {
+	traceID trace.TraceID
+}
+
+func (generator fixedTraceIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	return generator.traceID, generator.NewSpanID(ctx, generator.traceID)
+}
+
+func (generator fixedTraceIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	var spanID trace.SpanID
+	for !spanID.IsValid() {
+		binary.BigEndian.PutUint64(spanID[:], rand.Uint64())
+	}
+	return spanID
+}
+
+// tracingUnaryInterceptor records a span for every unary call.
+func (client *Client) tracingUnaryInterceptor(tracer trace.Tracer) UnaryInterceptor {
+	return func(ctx context.Context, req *Request, invoker UnaryInvoker) (*Response, error) {
+		ctx, span, req := client.startSpan(ctx, tracer, req)
+		resp, err := invoker(ctx, req)
+
+		var received, receivedBytes int
+		if resp != nil {
+			received = len(resp.Messages)
+			for _, message := range resp.Messages {
+				receivedBytes += len(message)
+			}
+		}
+		client.endSpan(span, resp, err, 1, len(req.Message), received, receivedBytes)
+		return resp, err
+	}
+}
+
+// tracingStreamInterceptor records a span for every streaming call, counting
+// the messages as they are sent and received.
+func (client *Client) tracingStreamInterceptor(tracer trace.Tracer) StreamInterceptor {
+	return func(ctx context.Context, req *Request, source MessageSource, handler StreamHandler, invoker StreamInvoker) (*Response, error) {
+		ctx, span, req := client.startSpan(ctx, tracer, req)
+
+		var sent, sentBytes, received, receivedBytes atomic.Int64
+		if source == nil {
+			sent.Store(1)
+			sentBytes.Store(int64(len(req.Message)))
+		} else {
+			next := source
+			source = func() ([]byte, error) {
+				message, err := next()
+				if err == nil {
+					sent.Add(1)
+					sentBytes.Add(int64(len(message)))
+				}
+				return message, err
+			}
+		}
+		next := handler
+		handler = func(message []byte) error {
+			received.Add(1)
+			receivedBytes.Add(int64(len(message)))
+			if next == nil {
+				return nil
+			}
+			return next(message)
+		}
+
+		resp, err := invoker(ctx, req, source, handler)
+		client.endSpan(span, resp, err, int(sent.Load()), int(sentBytes.Load()), int(received.Load()), int(receivedBytes.Load()))
+		return resp, err
+	}
+}
+
+// startSpan starts a client span named after the method, as
+// package.Service/Method, and returns a copy of req that carries its trace
+// context in traceparent and tracestate headers, and in grpc-trace-bin when
+// the client sends it.
+func (client *Client) startSpan(ctx context.Context, tracer trace.Tracer, req *Request) (context.Context, trace.Span, *Request) {
+	system := semconv.RPCSystemGRPC
+	if client.protocol == ProtocolConnect {
+		system = semconv.RPCSystemConnectRPC
+	}
+	attrs := []attribute.KeyValue{system, semconv.RPCService(req.Service), semconv.RPCMethod(req.Method)}
+	attrs = append(attrs, serverAttributes(client.baseURL)...)
+	ctx, span := tracer.Start(ctx, req.Service+"/"+req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	spanContext := span.SpanContext()
+	if !spanContext.IsValid() {
+		return ctx, span, req
+	}
+	if client.verbose {
+		fmt.Printf("* [trace] trace ID %s, span ID %s\n", spanContext.TraceID(), spanContext.SpanID())
+	}
+	headers := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, headers)
+	if client.traceBinary {
+		headers[HeaderTraceBin] = protocol.EncodeBinaryHeader(traceBinary(spanContext))
+	}
+	return ctx, span, withHeaders(req, headers)
+}
+
+// endSpan records the outcome and message counts of a call on its span and
+// ends it. Like gRPC, any status but OK marks a client span as failed.
+func (client *Client) endSpan(span trace.Span, resp *Response, err error, sent, sentBytes, received, receivedBytes int) {
+	defer span.End()
+	span.SetAttributes(
+		attrRequestsPerRPC.Int(sent), attrRequestSize.Int(sentBytes),
+		attrResponsesPerRPC.Int(received), attrResponseSize.Int(receivedBytes))
+
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp.Status == nil:
+	case client.protocol == ProtocolConnect:
+		if resp.Status.Code != protocol.StatusOK {
+			span.SetAttributes(semconv.RPCConnectRPCErrorCodeKey.String(strings.ToLower(protocol.StatusName(resp.Status.Code))))
+			span.SetStatus(codes.Error, resp.Status.Message)
+		}
+	default:
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(resp.Status.Code))
+		if resp.Status.Code != protocol.StatusOK {
+			span.SetStatus(codes.Error, resp.Status.Message)
+		}
+	}
+}
+
+// serverAttributes returns the server.address and server.port attributes for
+// a base URL.
+func serverAttributes(baseURL string) []attribute.KeyValue {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Hostname() == "" {
+		return nil
+	}
+	port, err := strconv.Atoi(parsed.Port())
+	if err != nil {
+		port = 443
+		if parsed.Scheme == "http" {
+			port = 80
+		}
+	}
+	return []attribute.KeyValue{semconv.ServerAddress(parsed.Hostname()), semconv.ServerPort(port)}
+}
+
+// traceBinary encodes a span context in the OpenCensus binary format of
+// grpc-trace-bin: a version byte, then the trace ID, span ID and trace options
+// fields, each preceded by its field ID.
+func traceBinary(spanContext trace.SpanContext) []byte {
+	traceID, spanID := spanContext.TraceID(), spanContext.SpanID()
+	value := make([]byte, 0, 29)
+	value = append(value, 0, 0)
+	value = append(value, traceID[:]...)
+	value = append(value, 1)
+	value = append(value, spanID[:]...)
+	return append(value, 2, byte(spanContext.TraceFlags()&trace.FlagsSampled))
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// spanAttribute returns the value of a span attribute, or an invalid value.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(test *testing.T) {
+	// The first request fails and is retried; X-Fail requests always fail
+	headers := make(chan http.Header, 10)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		if requests.Add(1) == 1 || r.Header.Get("X-Fail") != "" {
+			unavailable(w, r)
+			return
+		}
+		grpcWebOK(w, r)
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client, err := NewClient(server.URL, &Options{
+		Plaintext:      true,
+		Retry:          testRetryPolicy(3),
+		TracerProvider: provider,
+		TraceBinary:    true,
+	})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	req := &Request{Service: "test.Service", Method: "Method", Message: []byte("hello")}
+	if resp, err := client.Invoke(context.Background(), req); err != nil || resp.Status.Code != protocol.StatusOK {
+		test.Fatalf("Invoke() = %v, %v", resp, err)
+	}
+
+	// One span covers the call and its retry, which share its trace context
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 1 {
+		test.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	spanContext := span.SpanContext()
+	traceparent := "00-" + spanContext.TraceID().String() + "-" + spanContext.SpanID().String() + "-01"
+	wantBinary := protocol.EncodeBinaryHeader(traceBinary(spanContext))
+	for range 2 {
+		header := <-headers
+		if header.Get("Traceparent") != traceparent {
+			test.Errorf("traceparent = %q, want %q", header.Get("Traceparent"), traceparent)
+		}
+		if header.Get(HeaderTraceBin) != wantBinary {
+			test.Errorf("grpc-trace-bin = %q, want %q", header.Get(HeaderTraceBin), wantBinary)
+		}
+	}
+	if req.Headers != nil {
+		test.Error("tracing changed the caller's request")
+	}
+
+	if span.Name() != "test.Service/Method" || span.SpanKind() != trace.SpanKindClient {
+		test.Errorf("span = %s (%s), want client span test.Service/Method", span.Name(), span.SpanKind())
+	}
+	wantAttributes := map[attribute.Key]attribute.Value{
+		"rpc.system":                   attribute.StringValue("grpc"),
+		"rpc.service":                  attribute.StringValue("test.Service"),
+		"rpc.method":                   attribute.StringValue("Method"),
+		"rpc.grpc.status_code":         attribute.Int64Value(0),
+		"server.address":               attribute.StringValue("127.0.0.1"),
+		"rpc.client.requests_per_rpc":  attribute.Int64Value(1),
+		"rpc.client.request.size":      attribute.Int64Value(5),
+		"rpc.client.responses_per_rpc": attribute.Int64Value(1),
+		"rpc.client.response.size":     attribute.Int64Value(2),
+	}
+	for key, want := range wantAttributes {
+		if got := spanAttribute(span, key); got != want {
+			test.Errorf("%s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	if span.Status().Code != codes.Unset {
+		test.Errorf("span status = %v, want unset", span.Status())
+	}
+
+	// Streaming spans count the messages the handler receives
+	exporter.Reset()
+	if _, err := client.InvokeServerStream(context.Background(), req, nil); err != nil {
+		test.Fatalf("InvokeServerStream() error = %v", err)
+	}
+	<-headers
+	span = exporter.GetSpans().Snapshots()[0]
+	if got := spanAttribute(span, attrResponsesPerRPC); got.AsInt64() != 1 {
+		test.Errorf("stream responses = %v, want 1", got.Emit())
+	}
+
+	// A parent span in the context is continued, and failed calls are errors
+	exporter.Reset()
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	req.Headers = protocol.NewMetadata()
+	req.Headers.Set("X-Fail", "1")
+	if resp, err := client.Invoke(ctx, req); err != nil || resp.Status.Code != protocol.StatusUnavailable {
+		test.Fatalf("Invoke() = %v, %v, want UNAVAILABLE", resp, err)
+	}
+	parent.End()
+	for range 3 {
+		<-headers
+	}
+	span = exporter.GetSpans().Snapshots()[0]
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() || span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		test.Error("call span is not a child of the context's span")
+	}
+	if span.Status().Code != codes.Error || spanAttribute(span, semconv.RPCGRPCStatusCodeKey).AsInt64() != int64(protocol.StatusUnavailable) {
+		test.Errorf("span status = %v, code %v, want an UNAVAILABLE error", span.Status(), spanAttribute(span, semconv.RPCGRPCStatusCodeKey).Emit())
+	}
+}
+
+func TestNewTracerProvider(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(grpcWebOK))
+	defer server.Close()
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	invoke := func(test *testing.T, config TracingConfig) {
+		provider, err := NewTracerProvider(context.Background(), config)
+		if err != nil {
+			test.Fatalf("NewTracerProvider() error = %v", err)
+		}
+		client, err := NewClient(server.URL, &Options{Plaintext: true, TracerProvider: provider})
+		if err != nil {
+			test.Fatalf("NewClient() error = %v", err)
+		}
+		if _, err := client.Invoke(context.Background(), &Request{Service: "test.Service", Method: "Method", Message: []byte{}}); err != nil {
+			test.Fatalf("Invoke() error = %v", err)
+		}
+		if err := provider.Shutdown(context.Background()); err != nil {
+			test.Fatalf("Shutdown() error = %v", err)
+		}
+	}
+
+	test.Run("otlp", func(t *testing.T) {
+		// A collector stand-in receiving OTLP/HTTP protobuf exports
+		exports := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			export := &coltracepb.ExportTraceServiceRequest{}
+			if r.URL.Path != "/v1/traces" || proto.Unmarshal(body, export) != nil {
+				http.Error(w, "bad export", http.StatusBadRequest)
+				return
+			}
+			exports <- export
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		}))
+		defer collector.Close()
+
+		invoke(t, TracingConfig{Exporter: TraceExporterOTLP, Endpoint: collector.URL, TraceID: traceID})
+
+		export := <-exports
+		resourceSpans := export.GetResourceSpans()
+		if len(resourceSpans) != 1 || len(resourceSpans[0].GetScopeSpans()) != 1 {
+			t.Fatalf("export = %v, want one resource and scope", export)
+		}
+		var serviceName string
+		for _, attr := range resourceSpans[0].GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				serviceName = attr.GetValue().GetStringValue()
+			}
+		}
+		if serviceName != "grpcwebcurl" {
+			t.Errorf("service.name = %q, want grpcwebcurl", serviceName)
+		}
+		spans := resourceSpans[0].GetScopeSpans()[0].GetSpans()
+		if len(spans) != 1 || spans[0].GetName() != "test.Service/Method" || !bytes.Equal(spans[0].GetTraceId(), traceID[:]) {
+			t.Errorf("spans = %v, want test.Service/Method in trace %s", spans, traceID)
+		}
+	})
+
+	test.Run("stdout", func(t *testing.T) {
+		var output bytes.Buffer
+		invoke(t, TracingConfig{Exporter: TraceExporterStdout, Writer: &output, TraceID: traceID})
+		if !strings.Contains(output.String(), `"Name": "test.Service/Method"`) || !strings.Contains(output.String(), traceID.String()) {
+			t.Errorf("output = %s", output.String())
+		}
+	})
+
+	if _, err := NewTracerProvider(context.Background(), TracingConfig{Exporter: "jaeger"}); err == nil || !strings.Contains(err.Error(), "unknown trace exporter") {
+		test.Errorf("NewTracerProvider() error = %v, want unknown trace exporter", err)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Address schemes for Unix domain sockets, as in gRPC target names:
+// unix:path, unix:///absolute/path and unix-abstract:name.
+const (
+	schemeUnix         = "unix:"
+	schemeUnixAbstract = "unix-abstract:"
+)
+
+// unixSocketHost is the authority of requests to unix: addresses.
+const unixSocketHost = "localhost"
+
+// unixSocketTarget returns the Unix socket to connect to and the base URL
+// for requests. A unix: or unix-abstract: address names the socket itself,
+// and requests go to localhost; otherwise socket is used with baseURL
+// unchanged, so requests keep its host and path. An empty socket means TCP.
+// Abstract socket names start with @.
+func unixSocketTarget(baseURL, socket string, plaintext bool) (string, string, error) {
+	var name string
+	switch {
+	case strings.HasPrefix(baseURL, schemeUnixAbstract):
+		name = "@" + strings.TrimPrefix(baseURL, schemeUnixAbstract)
+	case strings.HasPrefix(baseURL, schemeUnix):
+		name = strings.TrimPrefix(baseURL, schemeUnix)
+		if strings.HasPrefix(name, "//") {
+			// unix:///path has an empty authority before the absolute path
+			name = strings.TrimPrefix(name, "//")
+			if !strings.HasPrefix(name, "/") {
+				return "", "", fmt.Errorf("invalid address %q: use unix:///absolute/path or unix:relative/path", baseURL)
+			}
+		}
+	default:
+		return socket, baseURL, nil
+	}
+
+	if name == "" || name == "@" {
+		return "", "", fmt.Errorf("invalid address %q: missing socket path", baseURL)
+	}
+	if socket != "" {
+		return "", "", fmt.Errorf("address %q already names a Unix socket; remove --unix-socket", baseURL)
+	}
+
+	scheme := "https"
+	if plaintext {
+		scheme = "http"
+	}
+	return name, scheme + "://" + unixSocketHost, nil
+}
+
+// unixSocketDialer returns a dialer that connects every request to socket,
+// whatever address the transport asks for.
+func unixSocketDialer(socket string, timeout time.Duration) dialFunc {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+func TestUnixSocketTarget(test *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		socket      string
+		plaintext   bool
+		wantSocket  string
+		wantBaseURL string
+		wantErr     bool
+	}{
+		{
+			name:        "TCP address",
+			baseURL:     "https://api.example.com",
+			wantBaseURL: "https://api.example.com",
+		},
+		{
+			name:        "socket option keeps base URL",
+			baseURL:     "http://api.example.com/prefix",
+			socket:      "/var/run/envoy.sock",
+			wantSocket:  "/var/run/envoy.sock",
+			wantBaseURL: "http://api.example.com/prefix",
+		},
+		{
+			name:        "absolute unix address",
+			baseURL:     "unix:///var/run/envoy.sock",
+			plaintext:   true,
+			wantSocket:  "/var/run/envoy.sock",
+			wantBaseURL: "http://localhost",
+		},
+		{
+			name:        "relative unix address",
+			baseURL:     "unix:run/envoy.sock",
+			wantSocket:  "run/envoy.sock",
+			wantBaseURL: "https://localhost",
+		},
+		{
+			name:        "abstract address",
+			baseURL:     "unix-abstract:envoy",
+			plaintext:   true,
+			wantSocket:  "@envoy",
+			wantBaseURL: "http://localhost",
+		},
+		{
+			name:    "unix address with authority",
+			baseURL: "unix://host/var/run/envoy.sock",
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			baseURL: "unix:",
+			wantErr: true,
+		},
+		{
+			name:    "unix address and socket option",
+			baseURL: "unix:///var/run/envoy.sock",
+			socket:  "/var/run/other.sock",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			socket, baseURL, err := unixSocketTarget(tt.baseURL, tt.socket, tt.plaintext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unixSocketTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if socket != tt.wantSocket || baseURL != tt.wantBaseURL {
+				t.Errorf("unixSocketTarget() = %q, %q, want %q, %q", socket, baseURL, tt.wantSocket, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+// unixServer serves gRPC-Web on a Unix socket and returns the host and path
+// of each request.
+func unixServer(test *testing.T, socket string) <-chan string {
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		test.Fatalf("Listen() error = %v", err)
+	}
+
+	requests := make(chan string, 10)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Host + r.URL.Path
+		grpcWebOK(w, r)
+	})}
+	go server.Serve(listener)
+	test.Cleanup(func() { server.Close() })
+	return requests
+}
+
+func TestClientInvokeUnixSocket(test *testing.T) {
+	// Socket paths are limited to about 100 bytes, which test.TempDir can
+	// exceed
+	dir, err := os.MkdirTemp("", "grpcwebcurl")
+	if err != nil {
+		test.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "envoy.sock")
+	requests := unixServer(test, socket)
+
+	tests := []struct {
+		name     string
+		baseURL  string
+		socket   string
+		wantPath string
+	}{
+		{
+			name:     "socket option",
+			baseURL:  "http://api.example.com/prefix",
+			socket:   socket,
+			wantPath: "api.example.com/prefix/test.Service/Method",
+		},
+		{
+			name:     "unix address",
+			baseURL:  "unix://" + socket,
+			wantPath: "localhost/test.Service/Method",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			// Proxies do not apply to Unix sockets
+			t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+			invokeProxyTest(t, tt.baseURL, &Options{UnixSocket: tt.socket})
+
+			if got := <-requests; got != tt.wantPath {
+				t.Errorf("server received %q, want %q", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestClientInvokeAbstractSocket(test *testing.T) {
+	if runtime.GOOS != "linux" {
+		test.Skip("abstract sockets are Linux only")
+	}
+
+	name := "grpcwebcurl-test-" + strconv.Itoa(os.Getpid())
+	requests := unixServer(test, "@"+name)
+
+	client, err := NewClient("unix-abstract:"+name, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	resp, err := client.Invoke(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Method",
+		Message: []byte{},
+	})
+	if err != nil {
+		test.Fatalf("Invoke() error = %v", err)
+	}
+	if resp.Status.Code != protocol.StatusOK {
+		test.Errorf("Status code = %d, want %d", resp.Status.Code, protocol.StatusOK)
+	}
+	if got := <-requests; got != "localhost/test.Service/Method" {
+		test.Errorf("server received %q, want localhost/test.Service/Method", got)
+	}
+}
@@ -0,0 +1,334 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// WebSocketSubprotocol is the WebSocket subprotocol used by grpc-web proxies
+// (improbable-eng grpcwebproxy, Envoy) for client and bidi streaming.
+const WebSocketSubprotocol = "grpc-websockets"
+
+// Message prefixes for client-to-server WebSocket messages.
+const (
+	wsMessageData      = 0x00 // followed by a gRPC-Web frame
+	wsMessageHalfClose = 0x01 // client has finished sending
+)
+
+// MessageSource supplies request messages for a client or bidi streaming call.
+// It returns io.EOF when there are no more messages to send.
+type MessageSource func() ([]byte, error)
+
+// InvokeBidiStream makes a client streaming or bidirectional streaming call
+// over the grpc-websockets transport.
+//
+// Messages are pulled from source and sent as soon as they are available, while
+// responses are delivered to handler concurrently as they arrive. When source
+// returns io.EOF the client half-closes the stream and waits for the trailers.
+// req.Message is ignored; all request messages come from source. The call runs
+// through the client's stream interceptors.
+func (client *Client) InvokeBidiStream(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error) {
+	if source == nil {
+		return nil, fmt.Errorf("client and bidi streaming calls require a message source")
+	}
+	return client.streamInvoker(ctx, req, source, handler)
+}
+
+// invokeBidiStream makes a client or bidi streaming call over a WebSocket.
+func (client *Client) invokeBidiStream(ctx context.Context, req *Request, source MessageSource, handler StreamHandler) (*Response, error) {
+	if client.protocol != ProtocolGRPCWeb {
+		return nil, fmt.Errorf("client and bidi streaming require the %s protocol", ProtocolGRPCWeb)
+	}
+
+	wsURL, err := websocketURL(client.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	wsURL = fmt.Sprintf("%s/%s/%s", wsURL, req.Service, req.Method)
+
+	// The handshake carries only the Host override; gRPC metadata is sent in
+	// the first WebSocket message, as browsers cannot set handshake headers.
+	// Streams from a source cannot be repeated, so credentials are not
+	// refreshed on UNAUTHENTICATED.
+	ctx, err = client.credentialsContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	metadata, host := client.websocketMetadata(ctx, req)
+	dialHeader := http.Header{}
+	if host != "" {
+		dialHeader.Set("Host", host)
+	}
+	if client.origin != "" {
+		dialHeader.Set("Origin", client.origin)
+	}
+
+	dialer := &websocket.Dialer{
+		NetDialContext:   client.transport.DialContext,
+		Proxy:            client.transport.Proxy,
+		Jar:              client.httpClient.Jar,
+		TLSClientConfig:  client.transport.TLSClientConfig,
+		HandshakeTimeout: client.connectTimeout,
+		Subprotocols:     []string{WebSocketSubprotocol},
+	}
+
+	if client.verbose {
+		fmt.Printf("> WEBSOCKET %s (%s)\n", wsURL, WebSocketSubprotocol)
+		logHeader(">", metadata)
+		fmt.Println()
+	}
+
+	conn, httpResp, err := dialer.DialContext(ctx, wsURL, dialHeader)
+	if err != nil {
+		if httpResp != nil {
+			return nil, fmt.Errorf("websocket handshake failed: %s: %w", httpResp.Status, err)
+		}
+		return nil, fmt.Errorf("websocket handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock reads and writes when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var headerBlock bytes.Buffer
+	if err := metadata.Write(&headerBlock); err != nil {
+		return nil, fmt.Errorf("failed to encode request metadata: %w", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, headerBlock.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send request metadata: %w", err)
+	}
+
+	// Send request messages concurrently with receiving responses
+	sendErr := make(chan error, 1)
+	go func() {
+		err := client.sendWebSocketMessages(conn, source)
+		sendErr <- err
+		// Abort the call if the request messages could not be produced
+		var srcErr *sourceError
+		if errors.As(err, &srcErr) {
+			conn.Close()
+		}
+	}()
+
+	resp, recvErr := client.receiveWebSocketMessages(conn, req, handler)
+
+	// A send failure after the server has already finished is not an error
+	// unless it came from the message source itself
+	select {
+	case err := <-sendErr:
+		var srcErr *sourceError
+		if errors.As(err, &srcErr) {
+			return nil, srcErr.err
+		}
+	default:
+	}
+
+	if recvErr != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request failed: %w", ctx.Err())
+		}
+		return nil, recvErr
+	}
+	return resp, nil
+}
+
+// sourceError wraps an error returned by a MessageSource.
+type sourceError struct {
+	err error
+}
+
+func (err *sourceError) Error() string { return err.err.Error() }
+
+// messageWriter forwards to the writer of the WebSocket message being sent, so
+// one encoder frames every message, and keeps the first write error.
+type messageWriter struct {
+	io.WriteCloser
+	err error
+}
+
+func (writer *messageWriter) Write(p []byte) (int, error) {
+	n, err := writer.WriteCloser.Write(p)
+	if err != nil && writer.err == nil {
+		writer.err = err
+	}
+	return n, err
+}
+
+// sendWebSocketMessages frames each message from source and writes it to the
+// connection, then half-closes the stream. The message prefix, frame header
+// and payload are written straight to the WebSocket message writer.
+func (client *Client) sendWebSocketMessages(conn *websocket.Conn, source MessageSource) error {
+	var writer messageWriter
+	encoder := protocol.NewEncoder(&writer)
+	encoder.SetCompressor(client.compressor)
+	for {
+		message, err := source()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &sourceError{err: fmt.Errorf("failed to read request message: %w", err)}
+		}
+
+		if writer.WriteCloser, err = conn.NextWriter(websocket.BinaryMessage); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		writer.Write([]byte{wsMessageData})
+		if err := encoder.Encode(message); err != nil && writer.err == nil {
+			return &sourceError{err: fmt.Errorf("failed to encode message: %w", err)}
+		}
+		if err := writer.Close(); writer.err != nil || err != nil {
+			return fmt.Errorf("failed to send message: %w", errors.Join(writer.err, err))
+		}
+
+		if client.verbose {
+			client.logFrame(">", &protocol.Frame{
+				Type:       protocol.FrameData,
+				Payload:    message,
+				Compressed: client.compressor != nil,
+				WireSize:   encoder.WireSize(),
+			}, client.compressor)
+		}
+	}
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte{wsMessageHalfClose}); err != nil {
+		return fmt.Errorf("failed to half-close stream: %w", err)
+	}
+	return nil
+}
+
+// receiveWebSocketMessages decodes response frames until the server closes the
+// stream. The first header frame carries the response headers; subsequent
+// header frames carry the trailers.
+func (client *Client) receiveWebSocketMessages(conn *websocket.Conn, req *Request, handler StreamHandler) (*Response, error) {
+	decoder := protocol.NewDecoder(&websocketReader{conn: conn})
+	decoder.SetMaxMessageSize(client.maxMsgSize)
+	decoder.SetReuseBuffers(req.ReuseBuffers)
+	defer decoder.Release()
+
+	resp := &Response{
+		Trailers:    protocol.NewMetadata(),
+		Headers:     protocol.NewMetadata(),
+		HTTPStatus:  http.StatusOK,
+		HTTPHeaders: http.Header{},
+	}
+	headersSeen := false
+
+	for {
+		frame, err := decoder.DecodeFrame()
+		// Once a status has arrived, an abrupt close is the end of the stream
+		if err == io.EOF || (err != nil && resp.Status != nil) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame: %w", err)
+		}
+		if client.verbose {
+			client.logFrame("<", frame, decoder.Compressor())
+		}
+
+		switch frame.Type {
+		case protocol.FrameData:
+			headersSeen = true
+			if handler != nil {
+				if err := handler(frame.Payload); err != nil {
+					return nil, fmt.Errorf("handler error: %w", err)
+				}
+			}
+			resp.Messages = retainMessage(resp.Messages, frame.Payload, req)
+
+		case protocol.FrameTrailer:
+			metadata, status := protocol.ParseTrailers(frame.Payload)
+			if !headersSeen {
+				headersSeen = true
+				resp.Headers = metadata
+				for _, entry := range metadata.Entries() {
+					resp.HTTPHeaders.Add(entry.Key, entry.Value)
+				}
+				// The negotiated encoding applies to the frames that follow
+				decoder.SetCompressor(protocol.GetCompressor(resp.HTTPHeaders.Get(protocol.HeaderGRPCEncoding)))
+				if client.verbose {
+					for _, entry := range metadata.Entries() {
+						fmt.Printf("< %s: %s\n", entry.Key, entry.Value)
+					}
+					fmt.Println()
+				}
+			} else {
+				resp.Trailers.Append(metadata)
+			}
+			if status != nil {
+				resp.Status = status
+			}
+		}
+	}
+
+	if resp.Status == nil {
+		resp.Status = client.missingTrailersStatus()
+	}
+	return resp, nil
+}
+
+// websocketMetadata builds the request metadata sent as the first WebSocket
+// message, returning the Host override separately.
+func (client *Client) websocketMetadata(ctx context.Context, req *Request) (http.Header, string) {
+	httpReq := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+	client.setGRPCWebHeaders(httpReq, protocol.GRPCWebContentType(client.requestCodec(req).Name(), false), req)
+
+	return httpReq.Header, httpReq.Host
+}
+
+// websocketURL converts an http(s) base URL into a ws(s) URL.
+func websocketURL(baseURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(baseURL, "https://"):
+		return "wss://" + strings.TrimPrefix(baseURL, "https://"), nil
+	case strings.HasPrefix(baseURL, "http://"):
+		return "ws://" + strings.TrimPrefix(baseURL, "http://"), nil
+	case strings.HasPrefix(baseURL, "wss://"), strings.HasPrefix(baseURL, "ws://"):
+		return baseURL, nil
+	default:
+		return "", fmt.Errorf("unsupported URL scheme for websocket transport: %s", baseURL)
+	}
+}
+
+// websocketReader presents the binary messages of a WebSocket connection as a
+// continuous byte stream; frames may span message boundaries.
+type websocketReader struct {
+	conn    *websocket.Conn
+	current io.Reader
+}
+
+// Read implements io.Reader, returning io.EOF when the server closes the connection.
+func (reader *websocketReader) Read(buf []byte) (int, error) {
+	for {
+		if reader.current == nil {
+			_, next, err := reader.conn.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			reader.current = next
+		}
+
+		count, err := reader.current.Read(buf)
+		if err == io.EOF {
+			reader.current = nil
+			if count > 0 {
+				return count, nil
+			}
+			continue
+		}
+		return count, err
+	}
+}
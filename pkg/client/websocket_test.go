@@ -0,0 +1,258 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+)
+
+// newWebSocketEchoServer starts a grpc-websockets server that echoes each
+// request message back as a response and, after the client half-closes, sends
+// trailers reporting how many messages it received.
+func newWebSocketEchoServer(test *testing.T, gotMetadata chan<- http.Header) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{WebSocketSubprotocol}}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			test.Errorf("Upgrade() error = %v", err)
+			return
+		}
+		defer conn.Close()
+
+		if conn.Subprotocol() != WebSocketSubprotocol {
+			test.Errorf("Subprotocol() = %q, want %q", conn.Subprotocol(), WebSocketSubprotocol)
+		}
+
+		// First message: request metadata in HTTP/1 header format
+		_, headerBlock, err := conn.ReadMessage()
+		if err != nil {
+			test.Errorf("failed to read metadata: %v", err)
+			return
+		}
+		metadata, err := readHeaderBlock(headerBlock)
+		if err != nil {
+			test.Errorf("invalid metadata block: %v", err)
+			return
+		}
+		if gotMetadata != nil {
+			gotMetadata <- metadata
+		}
+
+		writeFrame := func(frame protocol.Frame) {
+			encoded, _ := protocol.EncodeFrame(frame)
+			conn.WriteMessage(websocket.BinaryMessage, encoded)
+		}
+		writeFrame(protocol.Frame{Type: protocol.FrameTrailer, Payload: []byte("content-type: application/grpc-web+proto\r\n")})
+
+		count := 0
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(message) == 1 && message[0] == wsMessageHalfClose {
+				break
+			}
+			if message[0] != wsMessageData {
+				test.Errorf("message prefix = %#x, want %#x", message[0], wsMessageData)
+				return
+			}
+
+			payload, err := protocol.DecodeMessage(message[1:])
+			if err != nil {
+				test.Errorf("DecodeMessage() error = %v", err)
+				return
+			}
+			count++
+			writeFrame(protocol.Frame{Type: protocol.FrameData, Payload: append([]byte("echo:"), payload...)})
+		}
+
+		writeFrame(protocol.Frame{
+			Type:    protocol.FrameTrailer,
+			Payload: []byte(fmt.Sprintf("grpc-status: 0\r\nx-count: %d\r\n", count)),
+		})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+}
+
+// readHeaderBlock parses an HTTP/1 style header block.
+func readHeaderBlock(block []byte) (http.Header, error) {
+	reader := bufio.NewReader(io.MultiReader(bytes.NewReader(block), strings.NewReader("\r\n")))
+	header := http.Header{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return header, nil
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed header line %q", line)
+		}
+		header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+}
+
+func TestWebsocketURL(test *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "http://localhost:8080", want: "ws://localhost:8080"},
+		{baseURL: "https://api.example.com/prefix", want: "wss://api.example.com/prefix"},
+		{baseURL: "ws://localhost:8080", want: "ws://localhost:8080"},
+		{baseURL: "localhost:8080", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := websocketURL(tt.baseURL)
+		if (err != nil) != tt.wantErr {
+			test.Errorf("websocketURL(%q) error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			test.Errorf("websocketURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestInvokeBidiStreamRoundTrip(test *testing.T) {
+	gotMetadata := make(chan http.Header, 1)
+	server := newWebSocketEchoServer(test, gotMetadata)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetHeader("Authorization", "Bearer token")
+
+	// The source waits for each echo before sending the next message, which
+	// only completes if responses are received while the stream is still open.
+	requests := []string{"one", "two", "three"}
+	received := make(chan []byte, len(requests))
+	sent := 0
+	source := func() ([]byte, error) {
+		if sent > 0 {
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("timed out waiting for echo")
+			}
+		}
+		if sent == len(requests) {
+			return nil, io.EOF
+		}
+		sent++
+		return []byte(requests[sent-1]), nil
+	}
+
+	var responses []string
+	resp, err := client.InvokeBidiStream(context.Background(), &Request{
+		Service: "test.Service",
+		Method:  "Chat",
+	}, source, func(message []byte) error {
+		responses = append(responses, string(message))
+		received <- message
+		return nil
+	})
+	if err != nil {
+		test.Fatalf("InvokeBidiStream() error = %v", err)
+	}
+
+	metadata := <-gotMetadata
+	if metadata.Get("Authorization") != "Bearer token" {
+		test.Errorf("metadata Authorization = %q, want %q", metadata.Get("Authorization"), "Bearer token")
+	}
+	if metadata.Get("Content-Type") != protocol.ContentTypeGRPCWeb {
+		test.Errorf("metadata Content-Type = %q, want %q", metadata.Get("Content-Type"), protocol.ContentTypeGRPCWeb)
+	}
+
+	want := []string{"echo:one", "echo:two", "echo:three"}
+	if strings.Join(responses, ",") != strings.Join(want, ",") {
+		test.Errorf("responses = %v, want %v", responses, want)
+	}
+	if resp.Status == nil || resp.Status.Code != 0 {
+		test.Errorf("Status = %+v, want OK", resp.Status)
+	}
+	if resp.Trailers.Get("x-count") != "3" {
+		test.Errorf("Trailers[x-count] = %q, want %q", resp.Trailers.Get("x-count"), "3")
+	}
+	if resp.HTTPHeaders.Get("Content-Type") != protocol.ContentTypeGRPCWeb {
+		test.Errorf("HTTPHeaders Content-Type = %q", resp.HTTPHeaders.Get("Content-Type"))
+	}
+}
+
+func TestInvokeBidiStreamSourceError(test *testing.T) {
+	server := newWebSocketEchoServer(test, nil)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+
+	_, err = client.InvokeBidiStream(context.Background(), &Request{Service: "test.Service", Method: "Chat"},
+		func() ([]byte, error) { return nil, errors.New("bad input") }, nil)
+	if err == nil || !strings.Contains(err.Error(), "bad input") {
+		test.Fatalf("InvokeBidiStream() error = %v, want source error", err)
+	}
+}
+
+func TestInvokeBidiStreamMissingTrailers(test *testing.T) {
+	// The server answers with headers and a message, then closes the stream
+	// without a trailer frame
+	upgrader := websocket.Upgrader{Subprotocols: []string{WebSocketSubprotocol}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			test.Errorf("Upgrade() error = %v", err)
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		for _, frame := range []protocol.Frame{
+			{Type: protocol.FrameTrailer, Payload: []byte("content-type: application/grpc-web+proto\r\n")},
+			{Type: protocol.FrameData, Payload: []byte("partial")},
+		} {
+			encoded, _ := protocol.EncodeFrame(frame)
+			conn.WriteMessage(websocket.BinaryMessage, encoded)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, &Options{Plaintext: true})
+	if err != nil {
+		test.Fatalf("NewClient() error = %v", err)
+	}
+	resp, err := client.InvokeBidiStream(context.Background(), &Request{Service: "test.Service", Method: "Chat"},
+		func() ([]byte, error) { return nil, io.EOF }, nil)
+	if err != nil {
+		test.Fatalf("InvokeBidiStream() error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.Code != protocol.StatusInternal || !strings.Contains(resp.Status.Message, "missing trailers") {
+		test.Errorf("Status = %+v, want INTERNAL missing trailers", resp.Status)
+	}
+	if len(resp.Messages) != 1 {
+		test.Errorf("received %d messages, want 1", len(resp.Messages))
+	}
+}
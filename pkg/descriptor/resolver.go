@@ -0,0 +1,63 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+
+	// Register the well-known google.rpc error detail types (BadRequest,
+	// ErrorInfo, RetryInfo, QuotaFailure, ...) as a fallback for Any resolution.
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TypeResolver resolves message types referenced by google.protobuf.Any,
+// first through a descriptor Source and then through the types compiled into
+// the binary, including the well-known google.rpc error detail types.
+type TypeResolver struct {
+	source Source
+}
+
+// NewTypeResolver creates a resolver backed by source. A nil source resolves
+// only the compiled-in types.
+func NewTypeResolver(source Source) *TypeResolver {
+	return &TypeResolver{source: source}
+}
+
+// FindMessageByName looks up a message type by its fully qualified name.
+func (resolver *TypeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if resolver.source != nil {
+		if desc, err := resolver.source.FindSymbol(string(name)); err == nil {
+			if msgDesc, ok := desc.(protoreflect.MessageDescriptor); ok {
+				return dynamicpb.NewMessageType(msgDesc), nil
+			}
+		}
+	}
+
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("message type not found: %s", name)
+	}
+	return msgType, nil
+}
+
+// FindMessageByURL looks up a message type by an Any type URL
+// (e.g., type.googleapis.com/google.rpc.ErrorInfo).
+func (resolver *TypeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if slash := strings.LastIndex(url, "/"); slash >= 0 {
+		name = url[slash+1:]
+	}
+	return resolver.FindMessageByName(protoreflect.FullName(name))
+}
+
+// FindExtensionByName looks up an extension field by its fully qualified name.
+func (resolver *TypeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber looks up an extension field by message name and field number.
+func (resolver *TypeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
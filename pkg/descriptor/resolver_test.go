@@ -0,0 +1,60 @@
+package descriptor
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestTypeResolver(test *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:        strPtr("errors.proto"),
+		Package:     strPtr("custom"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: strPtr("QuotaDetail")}},
+	}
+	source, err := NewFileSource(fdp)
+	if err != nil {
+		test.Fatalf("NewFileSource() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "type from source",
+			url:  "type.googleapis.com/custom.QuotaDetail",
+			want: "custom.QuotaDetail",
+		},
+		{
+			name: "well-known error detail fallback",
+			url:  "type.googleapis.com/google.rpc.BadRequest",
+			want: "google.rpc.BadRequest",
+		},
+		{
+			name: "bare name",
+			url:  "google.rpc.RetryInfo",
+			want: "google.rpc.RetryInfo",
+		},
+		{
+			name:    "unknown type",
+			url:     "type.googleapis.com/custom.Missing",
+			wantErr: true,
+		},
+	}
+
+	resolver := NewTypeResolver(source)
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			msgType, err := resolver.FindMessageByURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				test.Fatalf("FindMessageByURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(msgType.Descriptor().FullName()) != tt.want {
+				test.Errorf("FindMessageByURL() = %s, want %s", msgType.Descriptor().FullName(), tt.want)
+			}
+		})
+	}
+}
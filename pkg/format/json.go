@@ -3,12 +3,15 @@ package format
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
 )
 
@@ -28,6 +31,14 @@ type JSONOptions struct {
 	UseProtoNames bool
 	// UseEnumNumbers outputs enum values as numbers instead of strings
 	UseEnumNumbers bool
+	// Resolver resolves message types inside google.protobuf.Any fields
+	Resolver Resolver
+}
+
+// Resolver resolves message and extension types, e.g. for google.protobuf.Any.
+type Resolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
 }
 
 // DefaultJSONOptions returns default JSON formatting options.
@@ -46,7 +57,7 @@ func NewJSONFormatter(opts *JSONOptions) *JSONFormatter {
 		opts = DefaultJSONOptions()
 	}
 
-	return &JSONFormatter{
+	formatter := &JSONFormatter{
 		marshalOpts: protojson.MarshalOptions{
 			EmitDefaultValues: opts.EmitDefaults,
 			Indent:            opts.Indent,
@@ -57,6 +68,12 @@ func NewJSONFormatter(opts *JSONOptions) *JSONFormatter {
 			DiscardUnknown: true,
 		},
 	}
+	if opts.Resolver != nil {
+		formatter.marshalOpts.Resolver = opts.Resolver
+		formatter.unmarshalOpts.Resolver = opts.Resolver
+	}
+
+	return formatter
 }
 
 // Marshal converts a protobuf message to JSON.
@@ -115,6 +132,74 @@ func FormatResponseBytes(data []byte, msgDesc protoreflect.MessageDescriptor, op
 	return formatter.MarshalToString(msg)
 }
 
+// FormatStatusJSON formats a gRPC status as a google.rpc.Status JSON object,
+// resolving each error detail through opts.Resolver. Details whose type
+// cannot be resolved are rendered with their type URL and base64 value.
+func FormatStatusJSON(status *protocol.Status, opts *JSONOptions) (string, error) {
+	details, err := FormatStatusDetailsJSON(status, opts)
+	if err != nil {
+		return "", err
+	}
+
+	rawDetails := make([]json.RawMessage, 0, len(details))
+	for _, detail := range details {
+		rawDetails = append(rawDetails, json.RawMessage(detail))
+	}
+
+	out := struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message,omitempty"`
+		Details []json.RawMessage `json:"details,omitempty"`
+	}{
+		Code:    status.Code,
+		Message: status.Message,
+		Details: rawDetails,
+	}
+
+	indent := ""
+	if opts != nil {
+		indent = opts.Indent
+	}
+	data, err := json.MarshalIndent(out, "", indent)
+	if err != nil {
+		return "", fmt.Errorf("failed to format status: %w", err)
+	}
+	return string(data), nil
+}
+
+// FormatStatusDetailsJSON formats each error detail of a gRPC status as compact JSON.
+func FormatStatusDetailsJSON(status *protocol.Status, opts *JSONOptions) ([]string, error) {
+	compactOpts := DefaultJSONOptions()
+	if opts != nil {
+		copied := *opts
+		compactOpts = &copied
+	}
+	compactOpts.Indent = ""
+	formatter := NewJSONFormatter(compactOpts)
+
+	var details []string
+	for _, detail := range status.Details {
+		data, err := formatter.Marshal(detail)
+		if err != nil {
+			// Unknown detail type: show it opaquely rather than failing
+			fallback, fallbackErr := json.Marshal(map[string]string{
+				"@type": detail.GetTypeUrl(),
+				"value": base64.StdEncoding.EncodeToString(detail.GetValue()),
+			})
+			if fallbackErr != nil {
+				return nil, fallbackErr
+			}
+			data = fallback
+		}
+		compacted, err := CompactJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, string(compacted))
+	}
+	return details, nil
+}
+
 // PrettyPrintJSON pretty-prints a JSON string.
 func PrettyPrintJSON(data []byte) ([]byte, error) {
 	var value interface{}
@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 func TestDefaultJSONOptions(test *testing.T) {
@@ -264,3 +268,44 @@ func TestCompactJSONEdgeCases(test *testing.T) {
 		})
 	}
 }
+
+func TestFormatStatusJSON(test *testing.T) {
+	badRequest, err := anypb.New(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "user_id", Description: "required"}},
+	})
+	if err != nil {
+		test.Fatalf("anypb.New() error = %v", err)
+	}
+	unknown := &anypb.Any{TypeUrl: "type.googleapis.com/custom.Unknown", Value: []byte{0x08, 0x01}}
+
+	status := &protocol.Status{
+		Code:    protocol.StatusInvalidArgument,
+		Message: "bad request",
+		Details: []*anypb.Any{badRequest, unknown},
+	}
+
+	output, err := FormatStatusJSON(status, DefaultJSONOptions())
+	if err != nil {
+		test.Fatalf("FormatStatusJSON() error = %v", err)
+	}
+
+	var parsed struct {
+		Code    int                      `json:"code"`
+		Message string                   `json:"message"`
+		Details []map[string]interface{} `json:"details"`
+	}
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		test.Fatalf("FormatStatusJSON() produced invalid JSON: %v\n%s", err, output)
+	}
+
+	if parsed.Code != 3 || parsed.Message != "bad request" || len(parsed.Details) != 2 {
+		test.Fatalf("FormatStatusJSON() = %s", output)
+	}
+	if parsed.Details[0]["@type"] != "type.googleapis.com/google.rpc.BadRequest" || parsed.Details[0]["fieldViolations"] == nil {
+		test.Errorf("details[0] = %v, want resolved BadRequest", parsed.Details[0])
+	}
+	// Unresolvable types fall back to the opaque form
+	if parsed.Details[1]["value"] != "CAE=" {
+		test.Errorf("details[1] = %v, want base64 value", parsed.Details[1])
+	}
+}
@@ -0,0 +1,62 @@
+package format
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// FormatMetadataValue renders a header or trailer value for display. Binary
+// (-bin) values are decoded and shown as a hex dump in text format, with each
+// line prefixed by indent, or as padded base64 in JSON format. Other values,
+// and binary values that fail to decode, are returned unchanged.
+func FormatMetadataValue(key, value string, text bool, indent string) string {
+	if !protocol.IsBinaryHeader(key) {
+		return value
+	}
+
+	data, err := protocol.DecodeBinaryHeader(value)
+	if err != nil {
+		return value
+	}
+	if !text {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	if len(data) == 0 {
+		return "(0 bytes)"
+	}
+
+	lines := strings.Split(strings.TrimRight(hex.Dump(data), "\n"), "\n")
+	return fmt.Sprintf("(%d bytes)\n%s%s", len(data), indent, strings.Join(lines, "\n"+indent))
+}
+
+// FormatBinaryMessage decodes a binary (-bin) metadata value as a message of
+// type msgDesc and renders it as compact JSON.
+func FormatBinaryMessage(value string, msgDesc protoreflect.MessageDescriptor, opts *JSONOptions) (string, error) {
+	data, err := protocol.DecodeBinaryHeader(value)
+	if err != nil {
+		return "", err
+	}
+
+	msg := dynamicpb.NewMessage(msgDesc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", msgDesc.FullName(), err)
+	}
+
+	// protojson output is not stable, so compact it explicitly
+	jsonData, err := NewJSONFormatter(opts).Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	compacted, err := CompactJSON(jsonData)
+	if err != nil {
+		return "", err
+	}
+	return string(compacted), nil
+}
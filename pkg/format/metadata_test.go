@@ -0,0 +1,60 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hjames9/grpcwebcurl/pkg/protocol"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormatMetadataValue(test *testing.T) {
+	value := protocol.EncodeBinaryHeader([]byte("\x00\x01hello"))
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		text  bool
+		want  string
+	}{
+		{name: "plain header", key: "x-request-id", value: "abc", text: true, want: "abc"},
+		{name: "binary as base64", key: "trace-bin", value: value, want: "AAFoZWxsbw=="},
+		{name: "invalid binary", key: "trace-bin", value: "not base64!", text: true, want: "not base64!"},
+		{name: "empty binary", key: "trace-bin", value: "", text: true, want: "(0 bytes)"},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			if got := FormatMetadataValue(tt.key, tt.value, tt.text, "  "); got != tt.want {
+				test.Errorf("FormatMetadataValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	dump := FormatMetadataValue("Trace-Bin", value, true, "    ")
+	if !strings.HasPrefix(dump, "(7 bytes)\n    00000000  00 01 68 65 6c 6c 6f") || !strings.Contains(dump, "|..hello|") {
+		test.Errorf("hex dump = %q", dump)
+	}
+}
+
+func TestFormatBinaryMessage(test *testing.T) {
+	data, err := proto.Marshal(&errdetails.ErrorInfo{Reason: "QUOTA", Domain: "example.com"})
+	if err != nil {
+		test.Fatalf("proto.Marshal() error = %v", err)
+	}
+
+	msgDesc := (&errdetails.ErrorInfo{}).ProtoReflect().Descriptor()
+	got, err := FormatBinaryMessage(protocol.EncodeBinaryHeader(data), msgDesc, nil)
+	if err != nil {
+		test.Fatalf("FormatBinaryMessage() error = %v", err)
+	}
+	if got != `{"reason":"QUOTA","domain":"example.com"}` {
+		test.Errorf("FormatBinaryMessage() = %s", got)
+	}
+
+	if _, err := FormatBinaryMessage(protocol.EncodeBinaryHeader([]byte{0xff}), msgDesc, nil); err == nil {
+		test.Error("FormatBinaryMessage() expected error for invalid message")
+	}
+}
@@ -26,7 +26,7 @@ func NewPrinter(writer io.Writer, color bool) *Printer {
 }
 
 // PrintResponse prints a formatted response.
-func (printer *Printer) PrintResponse(jsonData string, status *protocol.Status, trailers map[string]string) {
+func (printer *Printer) PrintResponse(jsonData string, status *protocol.Status, trailers *protocol.Metadata) {
 	// Print response data
 	fmt.Fprintln(printer.writer, jsonData)
 
@@ -37,7 +37,7 @@ func (printer *Printer) PrintResponse(jsonData string, status *protocol.Status,
 	}
 
 	// Print trailers if present
-	if len(trailers) > 0 {
+	if trailers.Len() > 0 {
 		fmt.Fprintln(printer.writer)
 		printer.printTrailers(trailers)
 	}
@@ -54,17 +54,23 @@ func (printer *Printer) printError(status *protocol.Status) {
 	if status.Message != "" {
 		fmt.Fprintf(printer.writer, "Message: %s\n", status.Message)
 	}
+	if details, err := FormatStatusDetailsJSON(status, nil); err == nil && len(details) > 0 {
+		fmt.Fprintln(printer.writer, "Details:")
+		for _, detail := range details {
+			fmt.Fprintf(printer.writer, "%s%s\n", printer.indent, detail)
+		}
+	}
 }
 
-// printTrailers prints response trailers.
-func (printer *Printer) printTrailers(trailers map[string]string) {
+// printTrailers prints response trailers, one line per value.
+func (printer *Printer) printTrailers(trailers *protocol.Metadata) {
 	if printer.color {
 		fmt.Fprintln(printer.writer, "\033[90mTrailers:\033[0m")
 	} else {
 		fmt.Fprintln(printer.writer, "Trailers:")
 	}
-	for key, value := range trailers {
-		fmt.Fprintf(printer.writer, "%s%s: %s\n", printer.indent, key, value)
+	for _, entry := range trailers.Entries() {
+		fmt.Fprintf(printer.writer, "%s%s: %s\n", printer.indent, entry.Key, FormatMetadataValue(entry.Key, entry.Value, false, ""))
 	}
 }
 
@@ -147,14 +153,14 @@ func fieldTypeName(field protoreflect.FieldDescriptor) string {
 }
 
 // PrintVerbose prints verbose request/response information.
-func (printer *Printer) PrintVerbose(direction string, headers map[string]string) {
+func (printer *Printer) PrintVerbose(direction string, headers *protocol.Metadata) {
 	prefix := ">"
 	if direction == "response" {
 		prefix = "<"
 	}
 
-	for key, value := range headers {
-		fmt.Fprintf(printer.writer, "%s %s: %s\n", prefix, key, value)
+	for _, entry := range headers.Entries() {
+		fmt.Fprintf(printer.writer, "%s %s: %s\n", prefix, entry.Key, entry.Value)
 	}
 	fmt.Fprintln(printer.writer)
 }
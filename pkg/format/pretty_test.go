@@ -77,7 +77,7 @@ func TestPrinterPrintResponse(test *testing.T) {
 		name     string
 		jsonData string
 		status   *protocol.Status
-		trailers map[string]string
+		trailers *protocol.Metadata
 		color    bool
 		wantJSON bool
 		wantErr  bool
@@ -110,11 +110,8 @@ func TestPrinterPrintResponse(test *testing.T) {
 			name:     "response with trailers",
 			jsonData: `{"result": "ok"}`,
 			status:   &protocol.Status{Code: 0},
-			trailers: map[string]string{
-				"grpc-status":  "0",
-				"grpc-message": "",
-			},
-			color: false,
+			trailers: protocol.Pairs("grpc-status", "0", "grpc-message", ""),
+			color:    false,
 		},
 	}
 
@@ -140,7 +137,7 @@ func TestPrinterPrintResponse(test *testing.T) {
 				}
 			}
 
-			if len(tt.trailers) > 0 {
+			if tt.trailers.Len() > 0 {
 				if !strings.Contains(output, "Trailers:") {
 					test.Errorf("PrintResponse should contain 'Trailers:' header")
 				}
@@ -153,25 +150,25 @@ func TestPrinterPrintVerbose(test *testing.T) {
 	tests := []struct {
 		name      string
 		direction string
-		headers   map[string]string
+		headers   *protocol.Metadata
 		wantPfx   string
 	}{
 		{
 			name:      "request headers",
 			direction: "request",
-			headers: map[string]string{
-				"Content-Type":  "application/grpc-web+proto",
-				"Authorization": "Bearer token",
-			},
+			headers: protocol.Pairs(
+				"Content-Type", "application/grpc-web+proto",
+				"Authorization", "Bearer token",
+			),
 			wantPfx: ">",
 		},
 		{
 			name:      "response headers",
 			direction: "response",
-			headers: map[string]string{
-				"Content-Type": "application/grpc-web+proto",
-				"Grpc-Status":  "0",
-			},
+			headers: protocol.Pairs(
+				"Content-Type", "application/grpc-web+proto",
+				"Grpc-Status", "0",
+			),
 			wantPfx: "<",
 		},
 	}
@@ -191,12 +188,12 @@ func TestPrinterPrintVerbose(test *testing.T) {
 			}
 
 			// Check all headers are present
-			for key, value := range tt.headers {
-				if !strings.Contains(output, key) {
-					test.Errorf("PrintVerbose output missing header key %q", key)
+			for _, entry := range tt.headers.Entries() {
+				if !strings.Contains(output, entry.Key) {
+					test.Errorf("PrintVerbose output missing header key %q", entry.Key)
 				}
-				if !strings.Contains(output, value) {
-					test.Errorf("PrintVerbose output missing header value %q", value)
+				if !strings.Contains(output, entry.Value) {
+					test.Errorf("PrintVerbose output missing header value %q", entry.Value)
 				}
 			}
 		})
@@ -244,11 +241,12 @@ func TestPrinterPrintTrailers(test *testing.T) {
 	var buf bytes.Buffer
 	printer := NewPrinter(&buf, false)
 
-	trailers := map[string]string{
-		"grpc-status":  "0",
-		"grpc-message": "OK",
-		"custom-key":   "custom-value",
-	}
+	trailers := protocol.Pairs(
+		"grpc-status", "0",
+		"grpc-message", "OK",
+		"custom-key", "custom-value",
+		"custom-key", "second-value",
+	)
 
 	printer.printTrailers(trailers)
 
@@ -258,9 +256,9 @@ func TestPrinterPrintTrailers(test *testing.T) {
 		test.Error("printTrailers should include 'Trailers:' header")
 	}
 
-	for key, value := range trailers {
-		if !strings.Contains(output, key+": "+value) {
-			test.Errorf("printTrailers missing %s: %s", key, value)
+	for _, entry := range trailers.Entries() {
+		if !strings.Contains(output, entry.Key+": "+entry.Value) {
+			test.Errorf("printTrailers missing %s: %s", entry.Key, entry.Value)
 		}
 	}
 }
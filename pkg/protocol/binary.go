@@ -0,0 +1,57 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// BinaryHeaderSuffix marks metadata keys whose values are binary. Their values
+// are base64 encoded on the wire.
+const BinaryHeaderSuffix = "-bin"
+
+// IsBinaryHeader reports whether a metadata key carries a binary value.
+func IsBinaryHeader(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), BinaryHeaderSuffix)
+}
+
+// EncodeBinaryHeader encodes a binary metadata value for the wire. Values are
+// sent as unpadded base64, as recommended by the gRPC spec.
+func EncodeBinaryHeader(value []byte) string {
+	return base64.RawStdEncoding.EncodeToString(value)
+}
+
+// DecodeBinaryHeader decodes a binary metadata value from the wire. Both padded
+// and unpadded base64 are accepted.
+func DecodeBinaryHeader(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	decoded, err := base64.RawStdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 in binary metadata: %w", err)
+	}
+	return decoded, nil
+}
+
+// ParseBinaryValue parses a user-supplied binary metadata value. Values
+// prefixed with hex: or 0x are hex encoded and values prefixed with base64:
+// are base64 encoded. Other values are always the raw bytes given, even if
+// they happen to be valid base64 or hex.
+func ParseBinaryValue(value string) ([]byte, error) {
+	lower := strings.ToLower(value)
+	switch {
+	case strings.HasPrefix(lower, "hex:"), strings.HasPrefix(lower, "0x"):
+		digits := strings.TrimPrefix(strings.TrimPrefix(lower, "hex:"), "0x")
+		decoded, err := hex.DecodeString(strings.Join(strings.Fields(digits), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex value: %w", err)
+		}
+		return decoded, nil
+
+	case strings.HasPrefix(lower, "base64:"):
+		return DecodeBinaryHeader(value[len("base64:"):])
+
+	default:
+		return []byte(value), nil
+	}
+}
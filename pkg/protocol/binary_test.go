@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsBinaryHeader(test *testing.T) {
+	tests := map[string]bool{
+		"trace-bin":               true,
+		"Grpc-Status-Details-Bin": true,
+		"x-binary":                false,
+		"bin":                     false,
+	}
+
+	for key, want := range tests {
+		if got := IsBinaryHeader(key); got != want {
+			test.Errorf("IsBinaryHeader(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestBinaryHeaderRoundTrip(test *testing.T) {
+	value := []byte{0x00, 0xff, 0x10, 0x20}
+
+	encoded := EncodeBinaryHeader(value)
+	if encoded != "AP8QIA" {
+		test.Errorf("EncodeBinaryHeader() = %q, want unpadded %q", encoded, "AP8QIA")
+	}
+
+	for _, wire := range []string{encoded, encoded + "==", " " + encoded + " "} {
+		decoded, err := DecodeBinaryHeader(wire)
+		if err != nil {
+			test.Fatalf("DecodeBinaryHeader(%q) error = %v", wire, err)
+		}
+		if !bytes.Equal(decoded, value) {
+			test.Errorf("DecodeBinaryHeader(%q) = %x, want %x", wire, decoded, value)
+		}
+	}
+
+	if _, err := DecodeBinaryHeader("not base64!"); err == nil {
+		test.Error("DecodeBinaryHeader() expected error for invalid base64")
+	}
+}
+
+func TestParseBinaryValue(test *testing.T) {
+	tests := []struct {
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		{value: "hex:00ff10", want: []byte{0x00, 0xff, 0x10}},
+		{value: "0xDEADBEEF", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{value: "hex:00 ff 10", want: []byte{0x00, 0xff, 0x10}},
+		{value: "base64:AP8Q", want: []byte{0x00, 0xff, 0x10}},
+		// Unprefixed values are raw bytes, even when they look encoded
+		{value: "abcd", want: []byte("abcd")},
+		{value: "AP8Q", want: []byte("AP8Q")},
+		{value: "00ff", want: []byte("00ff")},
+		{value: "not base64!", want: []byte("not base64!")},
+		{value: "hex:zz", wantErr: true},
+		{value: "base64:!!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBinaryValue(tt.value)
+		if (err != nil) != tt.wantErr {
+			test.Errorf("ParseBinaryValue(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			test.Errorf("ParseBinaryValue(%q) = %x, want %x", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Codec names, used as the content subtype (e.g., application/grpc-web+json).
+const (
+	CodecProto = "proto"
+	CodecJSON  = "json"
+)
+
+// Codec serializes messages for the wire.
+type Codec interface {
+	// Name returns the content subtype for the codec.
+	Name() string
+
+	// Marshal serializes a message.
+	Marshal(message proto.Message) ([]byte, error)
+
+	// Unmarshal parses a serialized message into message.
+	Unmarshal(data []byte, message proto.Message) error
+}
+
+// ProtoCodec serializes messages in the protobuf binary format.
+type ProtoCodec struct{}
+
+// Name implements Codec.
+func (ProtoCodec) Name() string { return CodecProto }
+
+// Marshal implements Codec.
+func (ProtoCodec) Marshal(message proto.Message) ([]byte, error) {
+	return proto.Marshal(message)
+}
+
+// Unmarshal implements Codec.
+func (ProtoCodec) Unmarshal(data []byte, message proto.Message) error {
+	return proto.Unmarshal(data, message)
+}
+
+// JSONResolver resolves message and extension types for google.protobuf.Any
+// fields in JSON messages.
+type JSONResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// JSONCodec serializes messages in the protobuf JSON format.
+type JSONCodec struct {
+	// Resolver resolves Any types; nil uses the global registry.
+	Resolver JSONResolver
+}
+
+// Name implements Codec.
+func (JSONCodec) Name() string { return CodecJSON }
+
+// Marshal implements Codec.
+func (codec JSONCodec) Marshal(message proto.Message) ([]byte, error) {
+	return protojson.MarshalOptions{Resolver: codec.Resolver}.Marshal(message)
+}
+
+// Unmarshal implements Codec.
+func (codec JSONCodec) Unmarshal(data []byte, message proto.Message) error {
+	return protojson.UnmarshalOptions{Resolver: codec.Resolver, DiscardUnknown: true}.Unmarshal(data, message)
+}
+
+// GetCodec returns the codec with the given name. An empty name selects the
+// protobuf codec.
+func GetCodec(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", CodecProto:
+		return ProtoCodec{}, nil
+	case CodecJSON:
+		return JSONCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %q: must be %q or %q", name, CodecProto, CodecJSON)
+	}
+}
+
+// GRPCWebContentType returns the gRPC-Web content type for a codec, in the
+// binary or text (base64) wire format.
+func GRPCWebContentType(codec string, text bool) string {
+	if codec == "" {
+		codec = CodecProto
+	}
+	if text {
+		return "application/grpc-web-text+" + codec
+	}
+	return "application/grpc-web+" + codec
+}
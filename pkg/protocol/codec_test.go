@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestGetCodec(test *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "", want: CodecProto},
+		{name: "proto", want: CodecProto},
+		{name: "JSON", want: CodecJSON},
+		{name: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		codec, err := GetCodec(tt.name)
+		if (err != nil) != tt.wantErr {
+			test.Errorf("GetCodec(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && codec.Name() != tt.want {
+			test.Errorf("GetCodec(%q).Name() = %q, want %q", tt.name, codec.Name(), tt.want)
+		}
+	}
+}
+
+func TestCodecRoundTrip(test *testing.T) {
+	for _, codec := range []Codec{ProtoCodec{}, JSONCodec{}} {
+		test.Run(codec.Name(), func(t *testing.T) {
+			data, err := codec.Marshal(durationpb.New(1500000000))
+			if err != nil {
+				test.Fatalf("Marshal() error = %v", err)
+			}
+			if codec.Name() == CodecJSON && !strings.Contains(string(data), "1.500s") {
+				test.Errorf("Marshal() = %s, want JSON duration", data)
+			}
+
+			got := &durationpb.Duration{}
+			if err := codec.Unmarshal(data, got); err != nil {
+				test.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !proto.Equal(got, durationpb.New(1500000000)) {
+				test.Errorf("round trip = %v", got)
+			}
+		})
+	}
+}
+
+func TestCodecContentTypes(test *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{GRPCWebContentType(CodecProto, false), ContentTypeGRPCWeb},
+		{GRPCWebContentType(CodecProto, true), ContentTypeGRPCWebText},
+		{GRPCWebContentType(CodecJSON, false), ContentTypeGRPCWebJSON},
+		{GRPCWebContentType(CodecJSON, true), "application/grpc-web-text+json"},
+		{ConnectContentType(CodecProto, false), ContentTypeConnectProto},
+		{ConnectContentType(CodecJSON, false), ContentTypeConnectJSON},
+		{ConnectContentType(CodecJSON, true), ContentTypeConnectStreamJSON},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			test.Errorf("content type = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
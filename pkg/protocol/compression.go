@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+)
+
+// Compressor compresses and decompresses gRPC message payloads.
+// Name is the value used in the Grpc-Encoding and Grpc-Accept-Encoding headers.
+type Compressor interface {
+	Name() string
+	Compress(writer io.Writer) (io.WriteCloser, error)
+	Decompress(reader io.Reader) (io.Reader, error)
+}
+
+// Built-in compressor names.
+const (
+	CompressionIdentity = "identity"
+	CompressionGzip     = "gzip"
+	CompressionDeflate  = "deflate"
+	CompressionSnappy   = "snappy"
+)
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = make(map[string]Compressor)
+)
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+	RegisterCompressor(deflateCompressor{})
+	RegisterCompressor(snappyCompressor{})
+}
+
+// RegisterCompressor makes a compressor available by name, replacing any
+// compressor previously registered under the same name.
+func RegisterCompressor(compressor Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[strings.ToLower(compressor.Name())] = compressor
+}
+
+// GetCompressor returns the compressor registered under name, or nil.
+// The identity encoding and an empty name both return nil.
+func GetCompressor(name string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// RegisteredCompressors returns the sorted names of all registered compressors.
+func RegisteredCompressors() []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AcceptEncoding returns the Grpc-Accept-Encoding header value advertising
+// every registered compressor.
+func AcceptEncoding() string {
+	return strings.Join(append([]string{CompressionIdentity}, RegisteredCompressors()...), ",")
+}
+
+// gzipCompressor implements the "gzip" encoding.
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return CompressionGzip }
+
+func (gzipCompressor) Compress(writer io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(writer), nil
+}
+
+func (gzipCompressor) Decompress(reader io.Reader) (io.Reader, error) {
+	return gzip.NewReader(reader)
+}
+
+// deflateCompressor implements the "deflate" encoding (zlib format, as in HTTP).
+type deflateCompressor struct{}
+
+func (deflateCompressor) Name() string { return CompressionDeflate }
+
+func (deflateCompressor) Compress(writer io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(writer), nil
+}
+
+func (deflateCompressor) Decompress(reader io.Reader) (io.Reader, error) {
+	return zlib.NewReader(reader)
+}
+
+// snappyCompressor implements the "snappy" encoding (framed stream format).
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string { return CompressionSnappy }
+
+func (snappyCompressor) Compress(writer io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(writer), nil
+}
+
+func (snappyCompressor) Decompress(reader io.Reader) (io.Reader, error) {
+	return snappy.NewReader(reader), nil
+}
@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegisteredCompressors(test *testing.T) {
+	names := RegisteredCompressors()
+	for _, want := range []string{CompressionDeflate, CompressionGzip, CompressionSnappy} {
+		found := false
+		for _, name := range names {
+			if name == want {
+				found = true
+			}
+		}
+		if !found {
+			test.Errorf("RegisteredCompressors() = %v, missing %q", names, want)
+		}
+	}
+
+	if GetCompressor("GZIP") == nil {
+		test.Error("GetCompressor() should be case-insensitive")
+	}
+	if GetCompressor(CompressionIdentity) != nil {
+		test.Error("GetCompressor(identity) should return nil")
+	}
+	if !strings.HasPrefix(AcceptEncoding(), CompressionIdentity+",") {
+		test.Errorf("AcceptEncoding() = %q, want identity first", AcceptEncoding())
+	}
+}
+
+func TestCompressedRoundTrip(test *testing.T) {
+	message := bytes.Repeat([]byte("grpc-web compression "), 64)
+
+	for _, name := range []string{CompressionGzip, CompressionDeflate, CompressionSnappy} {
+		test.Run(name, func(t *testing.T) {
+			compressor := GetCompressor(name)
+
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetCompressor(compressor)
+			if err := enc.Encode(message); err != nil {
+				test.Fatalf("Encode() error = %v", err)
+			}
+
+			if buf.Bytes()[0] != byte(FrameData|FlagCompressed) {
+				test.Errorf("frame flag = %#x, want %#x", buf.Bytes()[0], FrameData|FlagCompressed)
+			}
+			if buf.Len()-5 >= len(message) {
+				test.Errorf("compressed payload %d bytes, not smaller than %d", buf.Len()-5, len(message))
+			}
+
+			dec := NewDecoder(&buf)
+			dec.SetCompressor(compressor)
+			frame, err := dec.DecodeFrame()
+			if err != nil {
+				test.Fatalf("DecodeFrame() error = %v", err)
+			}
+			if frame.Type != FrameData || !frame.Compressed {
+				test.Errorf("frame type = %#x, compressed = %v", frame.Type, frame.Compressed)
+			}
+			if !bytes.Equal(frame.Payload, message) {
+				test.Error("decompressed payload does not match original")
+			}
+		})
+	}
+}
+
+func TestDecoder_CompressedTrailer(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCompressor(GetCompressor(CompressionGzip))
+	enc.EncodeFrame(Frame{Type: FrameTrailer, Payload: []byte("grpc-status: 5\r\ngrpc-message: missing\r\n")})
+
+	dec := NewDecoder(&buf)
+	dec.SetCompressor(GetCompressor(CompressionGzip))
+	resp, err := dec.DecodeResponse()
+	if err != nil {
+		test.Fatalf("DecodeResponse() error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.Code != 5 || resp.Status.Message != "missing" {
+		test.Errorf("Status = %+v, want code 5 message %q", resp.Status, "missing")
+	}
+}
+
+func TestDecoder_CompressedWithoutEncoding(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCompressor(GetCompressor(CompressionGzip))
+	enc.Encode([]byte{0x01, 0x02})
+
+	if _, err := NewDecoder(&buf).DecodeFrame(); err == nil {
+		test.Fatal("DecodeFrame() expected error for compressed frame without compressor")
+	}
+}
+
+func TestDecoder_DecompressedSizeLimit(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCompressor(GetCompressor(CompressionGzip))
+	enc.Encode(make([]byte, 10000))
+
+	dec := NewDecoder(&buf)
+	dec.SetCompressor(GetCompressor(CompressionGzip))
+	dec.SetMaxMessageSize(1000)
+	if _, err := dec.DecodeFrame(); err == nil {
+		test.Fatal("DecodeFrame() expected error for oversized decompressed message")
+	}
+}
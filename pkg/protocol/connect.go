@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Content types for the Connect protocol.
+const (
+	ContentTypeConnectProto       = "application/proto"
+	ContentTypeConnectJSON        = "application/json"
+	ContentTypeConnectStreamProto = "application/connect+proto"
+	ContentTypeConnectStreamJSON  = "application/connect+json"
+)
+
+// Header names for the Connect protocol.
+const (
+	HeaderConnectProtocolVersion = "Connect-Protocol-Version"
+	HeaderConnectTimeout         = "Connect-Timeout-Ms"
+	HeaderConnectContentEncoding = "Connect-Content-Encoding"
+	HeaderConnectAcceptEncoding  = "Connect-Accept-Encoding"
+	HeaderContentEncoding        = "Content-Encoding"
+	HeaderAcceptEncoding         = "Accept-Encoding"
+
+	// ConnectTrailerPrefix prefixes trailers sent as headers in unary responses.
+	ConnectTrailerPrefix = "Trailer-"
+)
+
+// ConnectProtocolVersion is the Connect protocol version sent with every request.
+const ConnectProtocolVersion = "1"
+
+// FrameConnectEndStream marks the final envelope of a Connect stream. Its
+// payload is a JSON object carrying the error and trailing metadata.
+const FrameConnectEndStream FrameType = 0x02
+
+// SetConnectRequestHeaders sets the required headers for a Connect request.
+func SetConnectRequestHeaders(req *http.Request, contentType string) {
+	if contentType == "" {
+		contentType = ContentTypeConnectProto
+	}
+
+	if req.Method != http.MethodGet {
+		req.Header.Set(HeaderContentType, contentType)
+	}
+	req.Header.Set(HeaderConnectProtocolVersion, ConnectProtocolVersion)
+	req.Header.Set("User-Agent", DefaultUserAgent)
+}
+
+// ConnectContentType returns the Connect content type for a codec, for unary
+// or streaming calls.
+func ConnectContentType(codec string, streaming bool) string {
+	if codec == "" {
+		codec = CodecProto
+	}
+	if streaming {
+		return "application/connect+" + codec
+	}
+	return "application/" + codec
+}
+
+// ConnectGetQuery builds the query string for a Connect unary GET request,
+// carrying the message as unpadded base64url.
+func ConnectGetQuery(message []byte, encoding, compression string) string {
+	query := url.Values{}
+	query.Set("connect", "v"+ConnectProtocolVersion)
+	query.Set("encoding", encoding)
+	query.Set("base64", "1")
+	query.Set("message", base64.RawURLEncoding.EncodeToString(message))
+	if compression != "" {
+		query.Set("compression", compression)
+	}
+	return query.Encode()
+}
+
+// connectError is the JSON error body of a Connect response.
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message"`
+	Details []connectErrorDetail `json:"details"`
+}
+
+// connectErrorDetail is a single error detail in a Connect error body.
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// connectEndStream is the JSON payload of a Connect end-of-stream envelope.
+type connectEndStream struct {
+	Error    *connectError `json:"error"`
+	Metadata http.Header   `json:"metadata"`
+}
+
+// ParseConnectError parses a Connect error body into a Status. If the body is
+// not a valid Connect error, the code is derived from the HTTP status.
+func ParseConnectError(body []byte, httpStatus int) *Status {
+	var connErr connectError
+	if err := json.Unmarshal(body, &connErr); err != nil || connErr.Code == "" {
+		return StatusFromHTTP(httpStatus)
+	}
+	return connErr.status()
+}
+
+// ParseConnectEndStream parses the payload of a Connect end-of-stream envelope,
+// returning the trailing metadata and the final status.
+func ParseConnectEndStream(payload []byte) (*Metadata, *Status, error) {
+	var endStream connectEndStream
+	if err := json.Unmarshal(payload, &endStream); err != nil {
+		return nil, nil, fmt.Errorf("invalid Connect end-of-stream message: %w", err)
+	}
+
+	// JSON objects are unordered, so keys are sorted
+	trailers := MetadataFromHeader(endStream.Metadata)
+
+	if endStream.Error == nil {
+		return trailers, &Status{Code: StatusOK}, nil
+	}
+	return trailers, endStream.Error.status(), nil
+}
+
+// status converts a Connect error into a Status, keeping details as Any messages.
+func (connErr *connectError) status() *Status {
+	status := &Status{Message: connErr.Message, Code: StatusUnknown}
+	if code, ok := StatusCode(connErr.Code); ok {
+		status.Code = code
+	}
+
+	for _, detail := range connErr.Details {
+		value, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(detail.Value, "="))
+		if err != nil {
+			continue
+		}
+		status.Details = append(status.Details, &anypb.Any{
+			TypeUrl: "type.googleapis.com/" + detail.Type,
+			Value:   value,
+		})
+	}
+	return status
+}
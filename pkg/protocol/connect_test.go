@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseConnectError(test *testing.T) {
+	detail, err := proto.Marshal(&errdetails.ErrorInfo{Reason: "QUOTA"})
+	if err != nil {
+		test.Fatalf("proto.Marshal() error = %v", err)
+	}
+	body := []byte(`{"code":"resource_exhausted","message":"slow down","details":[` +
+		`{"type":"google.rpc.ErrorInfo","value":"` + base64.RawStdEncoding.EncodeToString(detail) + `"}]}`)
+
+	status := ParseConnectError(body, http.StatusTooManyRequests)
+	if status.Code != StatusResourceExhausted || status.Message != "slow down" {
+		test.Errorf("ParseConnectError() = %d %q, want %d %q", status.Code, status.Message, StatusResourceExhausted, "slow down")
+	}
+	if len(status.Details) != 1 || status.Details[0].GetTypeUrl() != "type.googleapis.com/google.rpc.ErrorInfo" {
+		test.Fatalf("Details = %v", status.Details)
+	}
+
+	info := &errdetails.ErrorInfo{}
+	if err := status.Details[0].UnmarshalTo(info); err != nil || info.GetReason() != "QUOTA" {
+		test.Errorf("detail = %v, err = %v", info, err)
+	}
+}
+
+func TestParseConnectErrorFallback(test *testing.T) {
+	tests := []struct {
+		httpStatus int
+		want       int
+	}{
+		{http.StatusBadRequest, StatusInternal},
+		{http.StatusUnauthorized, StatusUnauthenticated},
+		{http.StatusNotFound, StatusUnimplemented},
+		{http.StatusServiceUnavailable, StatusUnavailable},
+		{http.StatusTeapot, StatusUnknown},
+	}
+
+	for _, tt := range tests {
+		status := ParseConnectError([]byte("<html>oops</html>"), tt.httpStatus)
+		if status.Code != tt.want {
+			test.Errorf("ParseConnectError(HTTP %d) code = %d, want %d", tt.httpStatus, status.Code, tt.want)
+		}
+	}
+}
+
+func TestParseConnectEndStream(test *testing.T) {
+	trailers, status, err := ParseConnectEndStream([]byte(`{"metadata":{"X-Count":["1","2"]}}`))
+	if err != nil {
+		test.Fatalf("ParseConnectEndStream() error = %v", err)
+	}
+	if status.Code != StatusOK {
+		test.Errorf("status = %d, want OK", status.Code)
+	}
+	if got := strings.Join(trailers.Values("x-count"), ","); got != "1,2" {
+		test.Errorf("trailers x-count = %q, want %q", got, "1,2")
+	}
+
+	_, status, err = ParseConnectEndStream([]byte(`{"error":{"code":"canceled","message":"stop"}}`))
+	if err != nil {
+		test.Fatalf("ParseConnectEndStream() error = %v", err)
+	}
+	if status.Code != StatusCancelled || status.Message != "stop" {
+		test.Errorf("status = %d %q, want %d %q", status.Code, status.Message, StatusCancelled, "stop")
+	}
+
+	if _, _, err := ParseConnectEndStream([]byte("not json")); err == nil {
+		test.Error("ParseConnectEndStream() expected error for invalid JSON")
+	}
+}
+
+func TestConnectGetQuery(test *testing.T) {
+	query, err := url.ParseQuery(ConnectGetQuery([]byte{0xfb, 0xff}, "proto", "gzip"))
+	if err != nil {
+		test.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	want := map[string]string{
+		"connect":     "v1",
+		"encoding":    "proto",
+		"base64":      "1",
+		"message":     "-_8",
+		"compression": "gzip",
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			test.Errorf("query[%s] = %q, want %q", key, query.Get(key), value)
+		}
+	}
+}
+
+func TestStatusCode(test *testing.T) {
+	tests := []struct {
+		name   string
+		want   int
+		wantOK bool
+	}{
+		{"NOT_FOUND", StatusNotFound, true},
+		{"not_found", StatusNotFound, true},
+		{"canceled", StatusCancelled, true},
+		{"unauthenticated", StatusUnauthenticated, true},
+		{"bogus", StatusUnknown, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := StatusCode(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			test.Errorf("StatusCode(%q) = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -7,15 +7,38 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 // MaxMessageSize is the maximum allowed message size (16MB).
 const MaxMessageSize = 16 * 1024 * 1024
 
+// maxPooledBuffer is the largest payload buffer returned to the pool, so a
+// single large message does not pin its memory for the life of the process.
+const maxPooledBuffer = 1024 * 1024
+
+// payloadPool recycles payload buffers between decoders that reuse buffers.
+var payloadPool = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, 0, 4096)
+		return &buffer
+	},
+}
+
 // Decoder decodes gRPC-Web binary format messages.
 type Decoder struct {
 	reader     io.Reader
 	maxMsgSize int
+	compressor Compressor
+	header     [5]byte
+
+	// Buffer reuse, enabled with SetReuseBuffers
+	reuse    bool
+	payload  *[]byte
+	inflated bytes.Buffer
+	frame    Frame
 }
 
 // NewDecoder creates a new gRPC-Web decoder that reads from reader.
@@ -31,11 +54,70 @@ func (decoder *Decoder) SetMaxMessageSize(size int) {
 	decoder.maxMsgSize = size
 }
 
+// SetCompressor sets the compressor used to decompress frames that have the
+// compressed flag set, normally chosen from the response Grpc-Encoding header.
+func (decoder *Decoder) SetCompressor(compressor Compressor) {
+	decoder.compressor = compressor
+}
+
+// Compressor returns the compressor used for compressed frames, or nil.
+func (decoder *Decoder) Compressor() Compressor {
+	return decoder.compressor
+}
+
+// SetReuseBuffers makes the decoder reuse one frame and payload buffer for
+// every frame instead of allocating new ones. The frame returned by
+// DecodeFrame is then owned by the decoder and only valid until the next
+// call; callers that keep a payload must copy it. Call Release when done.
+func (decoder *Decoder) SetReuseBuffers(reuse bool) {
+	decoder.reuse = reuse
+}
+
+// Release returns the decoder's payload buffer to the pool. The decoder may
+// still be used afterwards, but frames it returned earlier are invalid.
+func (decoder *Decoder) Release() {
+	if decoder.payload == nil {
+		return
+	}
+	if cap(*decoder.payload) <= maxPooledBuffer {
+		*decoder.payload = (*decoder.payload)[:0]
+		payloadPool.Put(decoder.payload)
+	}
+	decoder.payload = nil
+}
+
+// payloadBuffer returns a buffer of the given length for a frame payload,
+// reused between frames when buffer reuse is enabled.
+func (decoder *Decoder) payloadBuffer(length int) []byte {
+	if !decoder.reuse {
+		return make([]byte, length)
+	}
+
+	if decoder.payload == nil {
+		decoder.payload = payloadPool.Get().(*[]byte)
+	}
+	if cap(*decoder.payload) < length {
+		*decoder.payload = make([]byte, length)
+	}
+	return (*decoder.payload)[:length]
+}
+
+// newFrame returns an empty frame, owned by the decoder when buffer reuse is
+// enabled.
+func (decoder *Decoder) newFrame() *Frame {
+	if !decoder.reuse {
+		return &Frame{}
+	}
+	decoder.frame = Frame{}
+	return &decoder.frame
+}
+
 // DecodeFrame reads and decodes the next frame from the stream.
+// Compressed frames are transparently decompressed.
 // Returns io.EOF when no more frames are available.
 func (decoder *Decoder) DecodeFrame() (*Frame, error) {
 	// Read frame header (5 bytes: 1 byte type + 4 bytes length)
-	header := make([]byte, 5)
+	header := decoder.header[:]
 	if _, err := io.ReadFull(decoder.reader, header); err != nil {
 		return nil, err
 	}
@@ -49,14 +131,59 @@ func (decoder *Decoder) DecodeFrame() (*Frame, error) {
 	}
 
 	// Read payload
-	payload := make([]byte, length)
+	payload := decoder.payloadBuffer(int(length))
 	if length > 0 {
 		if _, err := io.ReadFull(decoder.reader, payload); err != nil {
 			return nil, fmt.Errorf("failed to read frame payload: %w", err)
 		}
 	}
 
-	return &Frame{Type: frameType, Payload: payload}, nil
+	if frameType&FlagCompressed == 0 {
+		frame := decoder.newFrame()
+		frame.Type, frame.Payload, frame.WireSize = frameType, payload, int(length)
+		return frame, nil
+	}
+
+	if decoder.compressor == nil {
+		return nil, fmt.Errorf("received compressed frame but no grpc-encoding was negotiated")
+	}
+	payload, err := decoder.decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := decoder.newFrame()
+	frame.Type = frameType &^ FlagCompressed
+	frame.Payload = payload
+	frame.Compressed = true
+	frame.WireSize = int(length)
+	return frame, nil
+}
+
+// decompress inflates a compressed payload, enforcing the maximum message size
+// on the decompressed result.
+func (decoder *Decoder) decompress(payload []byte) ([]byte, error) {
+	reader, err := decoder.compressor.Decompress(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress frame (%s): %w", decoder.compressor.Name(), err)
+	}
+
+	limited := io.LimitReader(reader, int64(decoder.maxMsgSize)+1)
+	var decompressed []byte
+	if decoder.reuse {
+		decoder.inflated.Reset()
+		_, err = decoder.inflated.ReadFrom(limited)
+		decompressed = decoder.inflated.Bytes()
+	} else {
+		decompressed, err = io.ReadAll(limited)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress frame (%s): %w", decoder.compressor.Name(), err)
+	}
+	if len(decompressed) > decoder.maxMsgSize {
+		return nil, fmt.Errorf("decompressed message size exceeds maximum %d", decoder.maxMsgSize)
+	}
+	return decompressed, nil
 }
 
 // Decode reads and decodes the next data frame, returning the message payload.
@@ -75,7 +202,8 @@ func (decoder *Decoder) Decode() ([]byte, error) {
 	}
 }
 
-// DecodeAll reads all frames from the stream and returns them.
+// DecodeAll reads all frames from the stream and returns them. Frames are
+// copied when buffer reuse is enabled, so they stay valid.
 func (decoder *Decoder) DecodeAll() ([]*Frame, error) {
 	var frames []*Frame
 	for {
@@ -86,6 +214,11 @@ func (decoder *Decoder) DecodeAll() ([]*Frame, error) {
 		if err != nil {
 			return frames, err
 		}
+		if decoder.reuse {
+			copied := *frame
+			copied.Payload = bytes.Clone(frame.Payload)
+			frame = &copied
+		}
 		frames = append(frames, frame)
 	}
 	return frames, nil
@@ -94,7 +227,7 @@ func (decoder *Decoder) DecodeAll() ([]*Frame, error) {
 // DecodedResponse contains the parsed response from a gRPC-Web call.
 type DecodedResponse struct {
 	Messages [][]byte
-	Trailers map[string]string
+	Trailers *Metadata
 	Status   *Status
 }
 
@@ -102,18 +235,28 @@ type DecodedResponse struct {
 type Status struct {
 	Code    int
 	Message string
+	// Details holds the error details decoded from grpc-status-details-bin.
+	Details []*anypb.Any
 }
 
 // DecodeResponse decodes a complete gRPC-Web response, separating data and trailers.
 func DecodeResponse(data []byte) (*DecodedResponse, error) {
-	decoder := NewDecoder(bytes.NewReader(data))
+	return NewDecoder(bytes.NewReader(data)).DecodeResponse()
+}
+
+// DecodeResponse reads all remaining frames and separates data and trailers.
+func (decoder *Decoder) DecodeResponse() (*DecodedResponse, error) {
 	frames, err := decoder.DecodeAll()
 	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("failed to decode frames: %w", err)
 	}
+	return CollectFrames(frames), nil
+}
 
+// CollectFrames builds a DecodedResponse from already decoded frames.
+func CollectFrames(frames []*Frame) *DecodedResponse {
 	resp := &DecodedResponse{
-		Trailers: make(map[string]string),
+		Trailers: NewMetadata(),
 	}
 
 	for _, frame := range frames {
@@ -122,20 +265,16 @@ func DecodeResponse(data []byte) (*DecodedResponse, error) {
 			resp.Messages = append(resp.Messages, frame.Payload)
 		case FrameTrailer:
 			// Parse trailers (HTTP header format)
-			trailers, status := parseTrailers(frame.Payload)
-			for key, value := range trailers {
-				resp.Trailers[key] = value
-			}
+			trailers, status := ParseTrailers(frame.Payload)
+			resp.Trailers.Append(trailers)
 			if status != nil {
 				resp.Status = status
 			}
 		default:
 			// Check if it's a trailer frame (high bit set)
 			if frame.Type&0x80 != 0 {
-				trailers, status := parseTrailers(frame.Payload)
-				for key, value := range trailers {
-					resp.Trailers[key] = value
-				}
+				trailers, status := ParseTrailers(frame.Payload)
+				resp.Trailers.Append(trailers)
 				if status != nil {
 					resp.Status = status
 				}
@@ -143,12 +282,13 @@ func DecodeResponse(data []byte) (*DecodedResponse, error) {
 		}
 	}
 
-	return resp, nil
+	return resp
 }
 
-// parseTrailers parses trailer data in HTTP header format.
-func parseTrailers(data []byte) (map[string]string, *Status) {
-	trailers := make(map[string]string)
+// ParseTrailers parses trailer data in HTTP header format. Repeated keys are
+// kept as separate values in order.
+func ParseTrailers(data []byte) (*Metadata, *Status) {
+	trailers := NewMetadata()
 	var status *Status
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
@@ -165,7 +305,7 @@ func parseTrailers(data []byte) (map[string]string, *Status) {
 
 		key := strings.TrimSpace(strings.ToLower(parts[0]))
 		value := strings.TrimSpace(parts[1])
-		trailers[key] = value
+		trailers.Add(key, value)
 
 		// Extract gRPC status
 		if key == "grpc-status" {
@@ -183,6 +323,14 @@ func parseTrailers(data []byte) (map[string]string, *Status) {
 		}
 	}
 
+	// Details are applied last so grpc-status and grpc-message take precedence
+	if trailers.Has(HeaderGRPCStatusDetails) {
+		if status == nil {
+			status = &Status{}
+		}
+		status.applyStatusDetails(trailers.Get(HeaderGRPCStatusDetails))
+	}
+
 	return trailers, status
 }
 
@@ -3,6 +3,7 @@ package protocol
 import (
 	"bytes"
 	"io"
+	"runtime"
 	"testing"
 )
 
@@ -134,8 +135,8 @@ func TestDecodeResponse(test *testing.T) {
 	}
 
 	// Check trailers
-	if resp.Trailers["grpc-status"] != "0" {
-		test.Errorf("DecodeResponse() grpc-status = %q, want %q", resp.Trailers["grpc-status"], "0")
+	if resp.Trailers.Get("grpc-status") != "0" {
+		test.Errorf("DecodeResponse() grpc-status = %q, want %q", resp.Trailers.Get("grpc-status"), "0")
 	}
 
 	// Check status
@@ -170,6 +171,21 @@ func TestDecodeResponse_Error(test *testing.T) {
 	}
 }
 
+func TestParseTrailers_RepeatedKeys(test *testing.T) {
+	trailers, status := ParseTrailers([]byte("grpc-status: 0\r\nx-debug: first\r\nX-Debug: second\r\n"))
+	if status == nil || status.Code != 0 {
+		test.Fatalf("ParseTrailers() status = %+v, want OK", status)
+	}
+
+	values := trailers.Values("x-debug")
+	if len(values) != 2 || values[0] != "first" || values[1] != "second" {
+		test.Errorf("x-debug values = %q, want [first second]", values)
+	}
+	if keys := trailers.Keys(); len(keys) != 2 || keys[0] != "grpc-status" || keys[1] != "x-debug" {
+		test.Errorf("Keys() = %q, want [grpc-status x-debug]", keys)
+	}
+}
+
 func TestDecoder_MaxMessageSize(test *testing.T) {
 	// Create a frame with size exceeding limit
 	input := []byte{0x00, 0x00, 0x01, 0x00, 0x00} // Claims to be 65536 bytes
@@ -215,3 +231,175 @@ func TestRoundTrip(test *testing.T) {
 		test.Errorf("Round trip failed: got %v, want %v", decoded, original)
 	}
 }
+
+func TestDecoder_ReuseBuffers(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.EncodeFrame(Frame{Type: FrameData, Payload: []byte("first")})
+	enc.EncodeFrame(Frame{Type: FrameData, Payload: []byte("second")})
+	enc.EncodeFrame(Frame{Type: FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetReuseBuffers(true)
+	defer dec.Release()
+
+	first, err := dec.DecodeFrame()
+	if err != nil {
+		test.Fatalf("DecodeFrame() error = %v", err)
+	}
+	if string(first.Payload) != "first" {
+		test.Errorf("DecodeFrame() payload = %q, want %q", first.Payload, "first")
+	}
+	kept := bytes.Clone(first.Payload)
+
+	second, err := dec.DecodeFrame()
+	if err != nil {
+		test.Fatalf("DecodeFrame() error = %v", err)
+	}
+	if string(second.Payload) != "second" {
+		test.Errorf("DecodeFrame() payload = %q, want %q", second.Payload, "second")
+	}
+	if first != second {
+		test.Error("DecodeFrame() returned a new frame, want the decoder's frame reused")
+	}
+	if string(kept) != "first" {
+		test.Errorf("copied payload = %q, want %q", kept, "first")
+	}
+
+	trailer, err := dec.DecodeFrame()
+	if err != nil {
+		test.Fatalf("DecodeFrame() error = %v", err)
+	}
+	if trailer.Type != FrameTrailer {
+		test.Errorf("DecodeFrame() type = %v, want %v", trailer.Type, FrameTrailer)
+	}
+}
+
+func TestDecoder_ReuseBuffersDecodeAll(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.EncodeFrame(Frame{Type: FrameData, Payload: []byte("first")})
+	enc.EncodeFrame(Frame{Type: FrameData, Payload: []byte("second")})
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetReuseBuffers(true)
+	defer dec.Release()
+
+	frames, err := dec.DecodeAll()
+	if err != nil {
+		test.Fatalf("DecodeAll() error = %v", err)
+	}
+	if len(frames) != 2 || string(frames[0].Payload) != "first" || string(frames[1].Payload) != "second" {
+		test.Errorf("DecodeAll() frames were overwritten by buffer reuse")
+	}
+}
+
+func TestDecoder_ReuseBuffersAllocations(test *testing.T) {
+	const runs = 100
+	frame := []byte{0x00, 0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03}
+	input := bytes.Repeat(frame, runs+1)
+
+	dec := NewDecoder(bytes.NewReader(input))
+	dec.SetReuseBuffers(true)
+	defer dec.Release()
+
+	allocs := testing.AllocsPerRun(runs, func() {
+		if _, err := dec.DecodeFrame(); err != nil {
+			test.Fatalf("DecodeFrame() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		test.Errorf("DecodeFrame() with reused buffers allocated %v times per frame, want 0", allocs)
+	}
+}
+
+func TestDecoder_ReuseBuffersCompressed(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCompressor(GetCompressor("gzip"))
+	enc.Encode([]byte("compressed first"))
+	enc.Encode([]byte("compressed second"))
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetCompressor(GetCompressor("gzip"))
+	dec.SetReuseBuffers(true)
+	defer dec.Release()
+
+	for _, want := range []string{"compressed first", "compressed second"} {
+		frame, err := dec.DecodeFrame()
+		if err != nil {
+			test.Fatalf("DecodeFrame() error = %v", err)
+		}
+		if string(frame.Payload) != want {
+			test.Errorf("DecodeFrame() payload = %q, want %q", frame.Payload, want)
+		}
+	}
+}
+
+// benchmarkDecoder decodes a stream of count frames of the given size per
+// iteration, with or without buffer reuse.
+func benchmarkDecoder(bench *testing.B, count, size int, reuse bool) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	payload := bytes.Repeat([]byte{0x2a}, size)
+	for index := 0; index < count; index++ {
+		enc.EncodeFrame(Frame{Type: FrameData, Payload: payload})
+	}
+	input := buf.Bytes()
+	reader := bytes.NewReader(input)
+
+	bench.ReportAllocs()
+	bench.SetBytes(int64(len(input)))
+	bench.ResetTimer()
+
+	for iteration := 0; iteration < bench.N; iteration++ {
+		reader.Reset(input)
+		dec := NewDecoder(reader)
+		dec.SetReuseBuffers(reuse)
+		for {
+			if _, err := dec.DecodeFrame(); err == io.EOF {
+				break
+			} else if err != nil {
+				bench.Fatalf("DecodeFrame() error = %v", err)
+			}
+		}
+		dec.Release()
+	}
+}
+
+func BenchmarkDecoder_SmallFrames(bench *testing.B) {
+	bench.Run("allocate", func(b *testing.B) { benchmarkDecoder(b, 1000, 64, false) })
+	bench.Run("reuse", func(b *testing.B) { benchmarkDecoder(b, 1000, 64, true) })
+}
+
+func BenchmarkDecoder_LargeFrames(bench *testing.B) {
+	bench.Run("allocate", func(b *testing.B) { benchmarkDecoder(b, 4, 1024*1024, false) })
+	bench.Run("reuse", func(b *testing.B) { benchmarkDecoder(b, 4, 1024*1024, true) })
+}
+
+// BenchmarkEncoder_LargeFrames encodes uncompressed 1 MiB frames, and fails
+// if the encoder allocates anywhere near the payload size per frame, which
+// means the payload was copied.
+func BenchmarkEncoder_LargeFrames(bench *testing.B) {
+	const size = 1024 * 1024
+	payload := bytes.Repeat([]byte{0x2a}, size)
+	enc := NewEncoder(io.Discard)
+
+	bench.ReportAllocs()
+	bench.SetBytes(size)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	bench.ResetTimer()
+
+	for iteration := 0; iteration < bench.N; iteration++ {
+		if err := enc.EncodeFrame(Frame{Type: FrameData, Payload: payload}); err != nil {
+			bench.Fatalf("EncodeFrame() error = %v", err)
+		}
+	}
+
+	bench.StopTimer()
+	runtime.ReadMemStats(&after)
+	if perFrame := (after.TotalAlloc - before.TotalAlloc) / uint64(bench.N); perFrame >= size/2 {
+		bench.Fatalf("EncodeFrame() allocated %d bytes per %d byte frame, so the payload was copied", perFrame, size)
+	}
+}
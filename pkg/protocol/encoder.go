@@ -4,7 +4,9 @@ package protocol
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"net"
 )
 
 // FrameType represents the type of gRPC-Web frame.
@@ -15,17 +17,32 @@ const (
 	FrameData FrameType = 0x00
 	// FrameTrailer indicates a trailer frame (compressed flag = 0x80).
 	FrameTrailer FrameType = 0x80
+	// FlagCompressed is set on frames whose payload is compressed.
+	FlagCompressed FrameType = 0x01
 )
 
+// FrameHeaderSize is the size of a frame header: a flag byte and a 4-byte
+// big-endian payload length.
+const FrameHeaderSize = 5
+
 // Frame represents a gRPC-Web frame containing either data or trailers.
 type Frame struct {
 	Type    FrameType
 	Payload []byte
+
+	// Compressed reports whether the frame arrived with the compressed flag set.
+	// Decoded frames always carry an uncompressed Payload and a Type without the flag.
+	Compressed bool
+	// WireSize is the payload size on the wire, before decompression.
+	WireSize int
 }
 
 // Encoder encodes messages into gRPC-Web binary format.
 type Encoder struct {
-	writer io.Writer
+	writer     io.Writer
+	compressor Compressor
+	header     [FrameHeaderSize]byte
+	wireSize   int
 }
 
 // NewEncoder creates a new gRPC-Web encoder that writes to writer.
@@ -33,32 +50,62 @@ func NewEncoder(writer io.Writer) *Encoder {
 	return &Encoder{writer: writer}
 }
 
+// SetCompressor sets the compressor applied to every encoded frame.
+// A nil compressor sends frames uncompressed.
+func (encoder *Encoder) SetCompressor(compressor Compressor) {
+	encoder.compressor = compressor
+}
+
 // Encode writes a message in gRPC-Web binary format.
 // Format: [Compressed-Flag (1 byte)][Message-Length (4 bytes)][Message (N bytes)]
 func (encoder *Encoder) Encode(message []byte) error {
 	return encoder.EncodeFrame(Frame{Type: FrameData, Payload: message})
 }
 
-// EncodeFrame writes a frame in gRPC-Web binary format.
+// WireSize returns the payload size on the wire of the last encoded frame,
+// after compression.
+func (encoder *Encoder) WireSize() int {
+	return encoder.wireSize
+}
+
+// EncodeFrame writes a frame in gRPC-Web binary format with a single vectored
+// write of the header and payload, so the payload is never copied.
+// If a compressor is set, the payload is compressed and the compressed flag is set.
 func (encoder *Encoder) EncodeFrame(frame Frame) error {
-	// Write frame type (1 byte)
-	if _, err := encoder.writer.Write([]byte{byte(frame.Type)}); err != nil {
-		return err
+	if encoder.compressor != nil {
+		compressed, err := CompressPayload(encoder.compressor, frame.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to compress frame: %w", err)
+		}
+		frame.Type |= FlagCompressed
+		frame.Payload = compressed
 	}
 
-	// Write message length (4 bytes, big-endian)
-	lengthBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lengthBuf, uint32(len(frame.Payload)))
-	if _, err := encoder.writer.Write(lengthBuf); err != nil {
-		return err
-	}
+	// Frame type (1 byte) and message length (4 bytes, big-endian)
+	encoder.header[0] = byte(frame.Type)
+	binary.BigEndian.PutUint32(encoder.header[1:], uint32(len(frame.Payload)))
+	encoder.wireSize = len(frame.Payload)
 
-	// Write message payload
-	if _, err := encoder.writer.Write(frame.Payload); err != nil {
-		return err
-	}
+	// net.Buffers becomes a single writev on connections that support it
+	buffers := net.Buffers{encoder.header[:], frame.Payload}
+	_, err := buffers.WriteTo(encoder.writer)
+	return err
+}
 
-	return nil
+// CompressPayload compresses an unframed payload with compressor.
+func CompressPayload(compressor Compressor, payload []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := compressor.Compress(&buffer)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
 }
 
 // EncodeMessage encodes a single message into gRPC-Web binary format and returns the bytes.
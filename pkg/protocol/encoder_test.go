@@ -115,3 +115,78 @@ func TestEncodeTrailer(test *testing.T) {
 		test.Errorf("EncodeTrailer() payload missing grpc-status")
 	}
 }
+
+func TestEncoder_WireSize(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+
+	if err := enc.Encode(bytes.Repeat([]byte{0x01}, 100)); err != nil {
+		test.Fatalf("Encode() error = %v", err)
+	}
+	if enc.WireSize() != 100 {
+		test.Errorf("WireSize() = %d, want 100", enc.WireSize())
+	}
+
+	enc.SetCompressor(GetCompressor("gzip"))
+	if err := enc.Encode(bytes.Repeat([]byte{0x01}, 100)); err != nil {
+		test.Fatalf("Encode() error = %v", err)
+	}
+	if enc.WireSize() != buf.Len()-2*FrameHeaderSize-100 {
+		test.Errorf("WireSize() = %d, want compressed size %d", enc.WireSize(), buf.Len()-2*FrameHeaderSize-100)
+	}
+}
+
+// recordingWriter records the slices written to it.
+type recordingWriter struct {
+	bytes.Buffer
+	writes [][]byte
+}
+
+func (writer *recordingWriter) Write(p []byte) (int, error) {
+	writer.writes = append(writer.writes, p)
+	return writer.Buffer.Write(p)
+}
+
+func TestEncoder_EncodeFrameWrites(test *testing.T) {
+	for _, compression := range []string{"", "gzip"} {
+		var writer recordingWriter
+		encoder := NewEncoder(&writer)
+		if compression != "" {
+			encoder.SetCompressor(GetCompressor(compression))
+		}
+
+		messages := [][]byte{[]byte("first"), bytes.Repeat([]byte{0x02}, 1000)}
+		for _, message := range messages {
+			if err := encoder.Encode(message); err != nil {
+				test.Fatalf("Encode() error = %v", err)
+			}
+		}
+
+		// The header and payload are written as they are, without assembling
+		// the frame in a buffer
+		if len(writer.writes) != 2*len(messages) {
+			test.Fatalf("%q: %d writes for %d frames, want a header and payload per frame", compression, len(writer.writes), len(messages))
+		}
+		for index, message := range messages {
+			header, payload := writer.writes[2*index], writer.writes[2*index+1]
+			if len(header) != FrameHeaderSize {
+				test.Errorf("%q: header write of %d bytes, want %d", compression, len(header), FrameHeaderSize)
+			}
+			if compression == "" && &payload[0] != &message[0] {
+				test.Errorf("%q: payload of frame %d was copied", compression, index)
+			}
+		}
+
+		decoder := NewDecoder(&writer.Buffer)
+		decoder.SetCompressor(GetCompressor(compression))
+		for _, want := range messages {
+			frame, err := decoder.DecodeFrame()
+			if err != nil {
+				test.Fatalf("%q: DecodeFrame() error = %v", compression, err)
+			}
+			if !bytes.Equal(frame.Payload, want) {
+				test.Errorf("%q: payload = %q, want %q", compression, frame.Payload, want)
+			}
+		}
+	}
+}
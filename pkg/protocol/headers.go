@@ -3,6 +3,7 @@ package protocol
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // Content types for gRPC-Web.
@@ -23,6 +24,9 @@ const (
 	HeaderGRPCEncoding  = "Grpc-Encoding"
 	HeaderGRPCTimeout   = "Grpc-Timeout"
 	HeaderAuthorization = "Authorization"
+
+	HeaderGRPCAcceptEncoding = "Grpc-Accept-Encoding"
+	HeaderGRPCStatusDetails  = "Grpc-Status-Details-Bin"
 )
 
 // Version is the version of grpcwebcurl.
@@ -43,6 +47,15 @@ func SetRequestHeaders(req *http.Request, contentType string) {
 	req.Header.Set(HeaderUserAgent, DefaultUserAgent)
 }
 
+// SetCompressionHeaders advertises the registered compressors and, if compressor
+// is non-nil, declares the encoding used for request messages.
+func SetCompressionHeaders(req *http.Request, compressor Compressor) {
+	req.Header.Set(HeaderGRPCAcceptEncoding, AcceptEncoding())
+	if compressor != nil {
+		req.Header.Set(HeaderGRPCEncoding, compressor.Name())
+	}
+}
+
 // SetCustomHeaders adds custom headers to the request.
 func SetCustomHeaders(req *http.Request, headers map[string]string) {
 	for key, value := range headers {
@@ -119,3 +132,20 @@ func StatusName(code int) string {
 	}
 	return "UNKNOWN"
 }
+
+// StatusCode returns the gRPC status code for a status name. Names are matched
+// case-insensitively, so both gRPC (NOT_FOUND) and Connect (not_found) names
+// are accepted.
+func StatusCode(name string) (int, bool) {
+	name = strings.ToUpper(name)
+	// Connect spells CANCELLED with a single L
+	if name == "CANCELED" {
+		return StatusCancelled, true
+	}
+	for code := StatusOK; code <= StatusUnauthenticated; code++ {
+		if StatusName(code) == name {
+			return code, true
+		}
+	}
+	return StatusUnknown, false
+}
@@ -0,0 +1,181 @@
+package protocol
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MetadataEntry is a single key/value pair of gRPC metadata.
+type MetadataEntry struct {
+	Key   string
+	Value string
+}
+
+// Metadata is an ordered, multi-valued collection of gRPC metadata, used for
+// request headers, response headers and trailers. Keys are case-insensitive
+// and stored in lowercase; entries keep the order in which they were added.
+//
+// A nil *Metadata is empty and may be read from but not written to.
+type Metadata struct {
+	entries []MetadataEntry
+}
+
+// NewMetadata creates an empty metadata collection.
+func NewMetadata() *Metadata {
+	return &Metadata{}
+}
+
+// Pairs creates metadata from alternating keys and values. It panics if given
+// an odd number of arguments.
+func Pairs(keyValues ...string) *Metadata {
+	if len(keyValues)%2 != 0 {
+		panic("protocol.Pairs: odd number of arguments")
+	}
+
+	md := NewMetadata()
+	for index := 0; index < len(keyValues); index += 2 {
+		md.Add(keyValues[index], keyValues[index+1])
+	}
+	return md
+}
+
+// MetadataFromHeader converts HTTP headers into metadata. HTTP headers are
+// unordered, so keys are sorted; the values of each key keep their order.
+func MetadataFromHeader(header http.Header) *Metadata {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	md := NewMetadata()
+	for _, key := range keys {
+		for _, value := range header[key] {
+			md.Add(key, value)
+		}
+	}
+	return md
+}
+
+// Len returns the number of entries, counting each value separately.
+func (md *Metadata) Len() int {
+	if md == nil {
+		return 0
+	}
+	return len(md.entries)
+}
+
+// Add appends a value for key, keeping any existing values.
+func (md *Metadata) Add(key, value string) {
+	md.entries = append(md.entries, MetadataEntry{Key: strings.ToLower(key), Value: value})
+}
+
+// Set replaces all values for key with value. The entry keeps the position of
+// the first existing value, or is appended if the key is new.
+func (md *Metadata) Set(key, value string) {
+	key = strings.ToLower(key)
+	replaced := false
+	entries := md.entries[:0]
+	for _, entry := range md.entries {
+		if entry.Key == key {
+			if replaced {
+				continue
+			}
+			entry.Value = value
+			replaced = true
+		}
+		entries = append(entries, entry)
+	}
+	md.entries = entries
+
+	if !replaced {
+		md.entries = append(md.entries, MetadataEntry{Key: key, Value: value})
+	}
+}
+
+// Get returns the first value for key, or an empty string if there is none.
+func (md *Metadata) Get(key string) string {
+	if md == nil {
+		return ""
+	}
+	key = strings.ToLower(key)
+	for _, entry := range md.entries {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values for key in order.
+func (md *Metadata) Values(key string) []string {
+	if md == nil {
+		return nil
+	}
+	key = strings.ToLower(key)
+	var values []string
+	for _, entry := range md.entries {
+		if entry.Key == key {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// Has reports whether key has at least one value.
+func (md *Metadata) Has(key string) bool {
+	return len(md.Values(key)) > 0
+}
+
+// Del removes all values for key.
+func (md *Metadata) Del(key string) {
+	if md == nil {
+		return
+	}
+	key = strings.ToLower(key)
+	entries := md.entries[:0]
+	for _, entry := range md.entries {
+		if entry.Key != key {
+			entries = append(entries, entry)
+		}
+	}
+	md.entries = entries
+}
+
+// Keys returns the distinct keys in order of first appearance.
+func (md *Metadata) Keys() []string {
+	if md == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, entry := range md.entries {
+		if !seen[entry.Key] {
+			seen[entry.Key] = true
+			keys = append(keys, entry.Key)
+		}
+	}
+	return keys
+}
+
+// Entries returns a copy of all entries in order.
+func (md *Metadata) Entries() []MetadataEntry {
+	if md == nil {
+		return nil
+	}
+	return append([]MetadataEntry(nil), md.entries...)
+}
+
+// Append adds all entries of other after the existing entries.
+func (md *Metadata) Append(other *Metadata) {
+	if other == nil {
+		return
+	}
+	md.entries = append(md.entries, other.entries...)
+}
+
+// Clone returns a copy of the metadata.
+func (md *Metadata) Clone() *Metadata {
+	return &Metadata{entries: md.Entries()}
+}
@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataAddGet(test *testing.T) {
+	md := NewMetadata()
+	md.Add("X-Debug", "one")
+	md.Add("content-type", "application/grpc-web+proto")
+	md.Add("x-debug", "two")
+
+	if got := md.Get("x-DEBUG"); got != "one" {
+		test.Errorf("Get() = %q, want first value %q", got, "one")
+	}
+	if got := md.Values("X-Debug"); !reflect.DeepEqual(got, []string{"one", "two"}) {
+		test.Errorf("Values() = %q, want [one two]", got)
+	}
+	if got := md.Keys(); !reflect.DeepEqual(got, []string{"x-debug", "content-type"}) {
+		test.Errorf("Keys() = %q, want insertion order", got)
+	}
+	if md.Len() != 3 {
+		test.Errorf("Len() = %d, want 3", md.Len())
+	}
+	if md.Has("missing") || md.Get("missing") != "" {
+		test.Error("missing key should have no values")
+	}
+}
+
+func TestMetadataSetDel(test *testing.T) {
+	md := Pairs("a", "1", "b", "2", "a", "3")
+
+	md.Set("A", "replaced")
+	want := []MetadataEntry{{Key: "a", Value: "replaced"}, {Key: "b", Value: "2"}}
+	if got := md.Entries(); !reflect.DeepEqual(got, want) {
+		test.Errorf("after Set() entries = %v, want %v", got, want)
+	}
+
+	md.Set("c", "new")
+	if got := md.Keys(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		test.Errorf("Set() of a new key should append, keys = %q", got)
+	}
+
+	md.Del("B")
+	if md.Has("b") || md.Len() != 2 {
+		test.Errorf("after Del() entries = %v", md.Entries())
+	}
+}
+
+func TestMetadataNil(test *testing.T) {
+	var md *Metadata
+	if md.Len() != 0 || md.Get("a") != "" || md.Values("a") != nil || md.Keys() != nil || md.Has("a") {
+		test.Error("nil Metadata should read as empty")
+	}
+	md.Del("a")
+	if md.Clone().Len() != 0 {
+		test.Error("Clone() of nil Metadata should be empty")
+	}
+}
+
+func TestMetadataAppendClone(test *testing.T) {
+	md := Pairs("a", "1")
+	clone := md.Clone()
+	clone.Add("a", "2")
+	if md.Len() != 1 {
+		test.Error("Clone() should not share entries with the original")
+	}
+
+	md.Append(clone)
+	md.Append(nil)
+	if got := md.Values("a"); !reflect.DeepEqual(got, []string{"1", "1", "2"}) {
+		test.Errorf("Append() values = %q", got)
+	}
+}
+
+func TestMetadataFromHeader(test *testing.T) {
+	header := http.Header{}
+	header.Add("X-B", "1")
+	header.Add("X-A", "2")
+	header.Add("X-B", "3")
+
+	want := []MetadataEntry{{Key: "x-a", Value: "2"}, {Key: "x-b", Value: "1"}, {Key: "x-b", Value: "3"}}
+	if got := MetadataFromHeader(header).Entries(); !reflect.DeepEqual(got, want) {
+		test.Errorf("MetadataFromHeader() = %v, want %v", got, want)
+	}
+}
+
+func TestPairsOddArguments(test *testing.T) {
+	defer func() {
+		if recover() == nil {
+			test.Error("Pairs() with an odd number of arguments should panic")
+		}
+	}()
+	Pairs("a")
+}
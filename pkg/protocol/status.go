@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// DecodeStatusDetails decodes a grpc-status-details-bin value into a
+// google.rpc.Status. Both padded and unpadded base64 are accepted.
+func DecodeStatusDetails(value string) (*spb.Status, error) {
+	raw, err := DecodeBinaryHeader(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", strings.ToLower(HeaderGRPCStatusDetails), err)
+	}
+
+	status := &spb.Status{}
+	if err := proto.Unmarshal(raw, status); err != nil {
+		return nil, fmt.Errorf("invalid google.rpc.Status in %s: %w", strings.ToLower(HeaderGRPCStatusDetails), err)
+	}
+	return status, nil
+}
+
+// applyStatusDetails attaches the details from a grpc-status-details-bin value.
+// Undecodable values are ignored; the raw value remains in the trailers.
+func (status *Status) applyStatusDetails(value string) {
+	rpcStatus, err := DecodeStatusDetails(value)
+	if err != nil {
+		return
+	}
+
+	status.Details = rpcStatus.GetDetails()
+	// grpc-status and grpc-message take precedence; only fill in what's missing
+	if status.Code == 0 {
+		status.Code = int(rpcStatus.GetCode())
+	}
+	if status.Message == "" {
+		status.Message = rpcStatus.GetMessage()
+	}
+}
+
+// StatusFromHeaders extracts a gRPC status, including any rich error details,
+// from response headers. Returns nil if the headers carry no status.
+func StatusFromHeaders(header http.Header) *Status {
+	code := header.Get(HeaderGRPCStatus)
+	message := header.Get(HeaderGRPCMessage)
+	details := header.Get(HeaderGRPCStatusDetails)
+	if code == "" && message == "" && details == "" {
+		return nil
+	}
+
+	status := &Status{Message: message}
+	if code != "" {
+		_, _ = fmt.Sscanf(code, "%d", &status.Code)
+	}
+	if details != "" {
+		status.applyStatusDetails(details)
+	}
+	return status
+}
+
+// StatusFromHTTP returns the status for an HTTP response that carries no gRPC
+// status of its own, mapping the HTTP status code to a gRPC code.
+func StatusFromHTTP(httpStatus int) *Status {
+	return &Status{
+		Code:    HTTPStatusToCode(httpStatus),
+		Message: strings.TrimSpace(fmt.Sprintf("HTTP %d %s", httpStatus, http.StatusText(httpStatus))),
+	}
+}
+
+// HTTPStatusToCode maps an HTTP status code to a gRPC status code for responses
+// that carry no gRPC status of their own.
+func HTTPStatusToCode(httpStatus int) int {
+	switch httpStatus {
+	case http.StatusOK:
+		return StatusOK
+	case http.StatusBadRequest:
+		return StatusInternal
+	case http.StatusUnauthorized:
+		return StatusUnauthenticated
+	case http.StatusForbidden:
+		return StatusPermissionDenied
+	case http.StatusNotFound:
+		return StatusUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return StatusUnavailable
+	default:
+		return StatusUnknown
+	}
+}
@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// encodeStatusDetails builds a grpc-status-details-bin value for tests.
+func encodeStatusDetails(test *testing.T, status *spb.Status, encoding *base64.Encoding) string {
+	test.Helper()
+	raw, err := proto.Marshal(status)
+	if err != nil {
+		test.Fatalf("proto.Marshal() error = %v", err)
+	}
+	return encoding.EncodeToString(raw)
+}
+
+func newTestRPCStatus(test *testing.T) *spb.Status {
+	test.Helper()
+	detail, err := anypb.New(&errdetails.ErrorInfo{Reason: "QUOTA", Domain: "example.com"})
+	if err != nil {
+		test.Fatalf("anypb.New() error = %v", err)
+	}
+	return &spb.Status{Code: 8, Message: "quota exceeded", Details: []*anypb.Any{detail}}
+}
+
+func TestDecodeStatusDetails(test *testing.T) {
+	rpcStatus := newTestRPCStatus(test)
+
+	for name, encoding := range map[string]*base64.Encoding{
+		"padded":   base64.StdEncoding,
+		"unpadded": base64.RawStdEncoding,
+	} {
+		test.Run(name, func(t *testing.T) {
+			got, err := DecodeStatusDetails(encodeStatusDetails(test, rpcStatus, encoding))
+			if err != nil {
+				test.Fatalf("DecodeStatusDetails() error = %v", err)
+			}
+			if got.GetCode() != 8 || len(got.GetDetails()) != 1 {
+				test.Errorf("DecodeStatusDetails() = %v", got)
+			}
+		})
+	}
+
+	if _, err := DecodeStatusDetails("not base64!"); err == nil {
+		test.Error("DecodeStatusDetails() expected error for invalid base64")
+	}
+}
+
+func TestParseTrailers_StatusDetails(test *testing.T) {
+	details := encodeStatusDetails(test, newTestRPCStatus(test), base64.RawStdEncoding)
+	payload := []byte("grpc-status: 8\r\ngrpc-message: slow down\r\ngrpc-status-details-bin: " + details + "\r\n")
+
+	_, status := ParseTrailers(payload)
+	if status == nil {
+		test.Fatal("ParseTrailers() status is nil")
+	}
+	if status.Code != 8 {
+		test.Errorf("Code = %d, want 8", status.Code)
+	}
+	// grpc-message takes precedence over the message inside the details
+	if status.Message != "slow down" {
+		test.Errorf("Message = %q, want %q", status.Message, "slow down")
+	}
+	if len(status.Details) != 1 || status.Details[0].GetTypeUrl() != "type.googleapis.com/google.rpc.ErrorInfo" {
+		test.Errorf("Details = %v, want one ErrorInfo", status.Details)
+	}
+}
+
+func TestStatusFromHeaders(test *testing.T) {
+	header := http.Header{}
+	if StatusFromHeaders(header) != nil {
+		test.Error("StatusFromHeaders() should be nil without status headers")
+	}
+
+	// Details alone supply code and message
+	header.Set(HeaderGRPCStatusDetails, encodeStatusDetails(test, newTestRPCStatus(test), base64.StdEncoding))
+	status := StatusFromHeaders(header)
+	if status == nil || status.Code != 8 || status.Message != "quota exceeded" || len(status.Details) != 1 {
+		test.Errorf("StatusFromHeaders() = %+v", status)
+	}
+}
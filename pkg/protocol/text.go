@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// IsTextContentType reports whether a content type uses the grpc-web-text
+// (base64) wire format.
+func IsTextContentType(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), "application/grpc-web-text")
+}
+
+// EncodeText base64-encodes gRPC-Web frames for the grpc-web-text wire format.
+func EncodeText(data []byte) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	return encoded
+}
+
+// DecodeText decodes a complete grpc-web-text body back into binary frames.
+// The body may consist of several independently padded base64 chunks.
+func DecodeText(data []byte) ([]byte, error) {
+	return io.ReadAll(NewTextReader(bytes.NewReader(data)))
+}
+
+// textReadSize is the number of base64 bytes read from the source per fill.
+const textReadSize = 4096
+
+// TextReader incrementally decodes a grpc-web-text response stream.
+//
+// Servers flush each frame as its own base64 chunk, so the stream may contain
+// padding in the middle, and network reads may split a base64 quantum across
+// chunk boundaries. TextReader buffers partial quanta until they are complete.
+type TextReader struct {
+	source  io.Reader
+	pending []byte // undecoded base64 input (always less than one quantum after a fill)
+	decoded []byte // decoded bytes not yet returned to the caller
+	readBuf []byte
+	err     error
+}
+
+// NewTextReader returns a reader that base64-decodes a grpc-web-text stream.
+func NewTextReader(source io.Reader) *TextReader {
+	return &TextReader{
+		source:  source,
+		readBuf: make([]byte, textReadSize),
+	}
+}
+
+// Read implements io.Reader.
+func (reader *TextReader) Read(buf []byte) (int, error) {
+	for len(reader.decoded) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		reader.fill()
+	}
+
+	count := copy(buf, reader.decoded)
+	reader.decoded = reader.decoded[count:]
+	return count, nil
+}
+
+// fill reads more base64 input from the source and decodes every complete quantum.
+func (reader *TextReader) fill() {
+	count, err := reader.source.Read(reader.readBuf)
+	for _, char := range reader.readBuf[:count] {
+		// Whitespace is not part of the alphabet; some proxies insert line breaks.
+		if char == '\r' || char == '\n' || char == ' ' || char == '\t' {
+			continue
+		}
+		reader.pending = append(reader.pending, char)
+	}
+
+	complete := len(reader.pending) - len(reader.pending)%4
+	if complete > 0 {
+		decoded, decodeErr := decodeQuanta(reader.pending[:complete])
+		reader.decoded = append(reader.decoded[:0], decoded...)
+		reader.pending = append(reader.pending[:0], reader.pending[complete:]...)
+		if decodeErr != nil {
+			reader.err = decodeErr
+			return
+		}
+	}
+
+	if err == io.EOF {
+		if len(reader.pending) > 0 {
+			reader.err = io.ErrUnexpectedEOF
+			return
+		}
+		reader.err = io.EOF
+		return
+	}
+	if err != nil {
+		reader.err = err
+	}
+}
+
+// decodeQuanta decodes a sequence of complete base64 quanta. Padded quanta may
+// appear anywhere, marking the end of an individually encoded chunk.
+func decodeQuanta(data []byte) ([]byte, error) {
+	out := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+	for len(data) > 0 {
+		// Decode up to and including the next padded quantum in one call
+		end := len(data)
+		if pad := bytes.IndexByte(data, '='); pad >= 0 {
+			end = pad - pad%4 + 4
+		}
+
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		count, err := base64.StdEncoding.Decode(chunk, data[:end])
+		out = append(out, chunk[:count]...)
+		if err != nil {
+			return out, err
+		}
+		data = data[end:]
+	}
+	return out, nil
+}
@@ -0,0 +1,129 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+// chunkReader returns at most size bytes per Read to simulate network chunking.
+type chunkReader struct {
+	data []byte
+	size int
+}
+
+func (reader *chunkReader) Read(buf []byte) (int, error) {
+	if len(reader.data) == 0 {
+		return 0, io.EOF
+	}
+	count := reader.size
+	if count > len(buf) {
+		count = len(buf)
+	}
+	if count > len(reader.data) {
+		count = len(reader.data)
+	}
+	copy(buf, reader.data[:count])
+	reader.data = reader.data[count:]
+	return count, nil
+}
+
+func TestIsTextContentType(test *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{ContentTypeGRPCWebText, true},
+		{"application/grpc-web-text", true},
+		{"Application/GRPC-Web-Text+proto", true},
+		{ContentTypeGRPCWeb, false},
+		{ContentTypeGRPCWebJSON, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTextContentType(tt.contentType); got != tt.want {
+			test.Errorf("IsTextContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeText(test *testing.T) {
+	frame, _ := EncodeMessage([]byte{0x08, 0x01})
+
+	got := EncodeText(frame)
+	want := base64.StdEncoding.EncodeToString(frame)
+	if string(got) != want {
+		test.Errorf("EncodeText() = %q, want %q", got, want)
+	}
+}
+
+func TestTextReader_ConcatenatedChunks(test *testing.T) {
+	// Each frame is encoded independently, as servers flush per frame
+	dataFrame, _ := EncodeFrame(Frame{Type: FrameData, Payload: []byte{0x08, 0x96, 0x01}})
+	trailerFrame, _ := EncodeFrame(Frame{Type: FrameTrailer, Payload: []byte("grpc-status: 0\r\n")})
+
+	var body []byte
+	body = append(body, EncodeText(dataFrame)...)
+	body = append(body, EncodeText(trailerFrame)...)
+
+	want := append(append([]byte{}, dataFrame...), trailerFrame...)
+
+	// Try every chunk size so quanta and padding get split at every offset
+	for size := 1; size <= len(body); size++ {
+		got, err := io.ReadAll(NewTextReader(&chunkReader{data: body, size: size}))
+		if err != nil {
+			test.Fatalf("chunk size %d: ReadAll() error = %v", size, err)
+		}
+		if !bytes.Equal(got, want) {
+			test.Fatalf("chunk size %d: got %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestTextReader_Whitespace(test *testing.T) {
+	frame, _ := EncodeMessage([]byte("hello world"))
+	encoded := EncodeText(frame)
+	body := append(append(append([]byte{}, encoded[:8]...), "\r\n"...), encoded[8:]...)
+
+	got, err := DecodeText(body)
+	if err != nil {
+		test.Fatalf("DecodeText() error = %v", err)
+	}
+	if !bytes.Equal(got, frame) {
+		test.Errorf("DecodeText() = %v, want %v", got, frame)
+	}
+}
+
+func TestTextReader_Errors(test *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "truncated quantum", input: "AAAAAA"},
+		{name: "invalid character", input: "AA*A"},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeText([]byte(tt.input)); err == nil {
+				test.Errorf("DecodeText(%q) expected error", tt.input)
+			}
+		})
+	}
+}
+
+func TestTextReader_FeedsDecoder(test *testing.T) {
+	frame, _ := EncodeMessage([]byte{0x01, 0x02, 0x03})
+	body := EncodeText(frame)
+
+	decoder := NewDecoder(NewTextReader(&chunkReader{data: body, size: 3}))
+	got, err := decoder.Decode()
+	if err != nil {
+		test.Fatalf("Decode() error = %v", err)
+	}
+	if !bytes.Equal(got, []byte{0x01, 0x02, 0x03}) {
+		test.Errorf("Decode() = %v, want [1 2 3]", got)
+	}
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxTimeoutValue is the largest value allowed in a grpc-timeout header,
+// which is limited to 8 ASCII digits.
+const maxTimeoutValue = 99999999
+
+// maxConnectTimeout is the largest Connect-Timeout-Ms value (10 digits).
+const maxConnectTimeout = 9999999999
+
+// timeoutUnits lists the grpc-timeout units from finest to coarsest.
+var timeoutUnits = []struct {
+	unit     byte
+	duration time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// EncodeTimeout encodes a duration in the grpc-timeout format, using the finest
+// unit whose value fits in 8 digits. Values are rounded up so the server never
+// sees a shorter deadline than the client's.
+func EncodeTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "0n"
+	}
+
+	for _, unit := range timeoutUnits {
+		value := int64(timeout / unit.duration)
+		if timeout%unit.duration != 0 {
+			value++
+		}
+		if value <= maxTimeoutValue {
+			return strconv.FormatInt(value, 10) + string(unit.unit)
+		}
+	}
+	return strconv.Itoa(maxTimeoutValue) + "H"
+}
+
+// ParseTimeout parses a grpc-timeout header value (e.g., 100m, 5S, 1H).
+func ParseTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q: expected 1-8 digits and a unit", value)
+	}
+
+	number, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q: value must be a positive integer", value)
+	}
+
+	unit := value[len(value)-1]
+	for _, candidate := range timeoutUnits {
+		if candidate.unit != unit {
+			continue
+		}
+		// Hours can exceed the range of time.Duration
+		if number > int64(math.MaxInt64/candidate.duration) {
+			return time.Duration(math.MaxInt64), nil
+		}
+		return time.Duration(number) * candidate.duration, nil
+	}
+	return 0, fmt.Errorf("invalid grpc-timeout %q: unknown unit %q (use n, u, m, S, M or H)", value, unit)
+}
+
+// EncodeConnectTimeout encodes a duration as a Connect-Timeout-Ms value,
+// rounding up to the next millisecond.
+func EncodeConnectTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "0"
+	}
+
+	millis := int64(timeout / time.Millisecond)
+	if timeout%time.Millisecond != 0 {
+		millis++
+	}
+	if millis > maxConnectTimeout {
+		millis = maxConnectTimeout
+	}
+	return strconv.FormatInt(millis, 10)
+}
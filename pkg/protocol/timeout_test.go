@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeTimeout(test *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{0, "0n"},
+		{-time.Second, "0n"},
+		{500 * time.Nanosecond, "500n"},
+		{99999999 * time.Nanosecond, "99999999n"},
+		{100 * time.Millisecond, "100000u"},
+		{30 * time.Second, "30000000u"},
+		{200 * time.Second, "200000m"},
+		{100000*time.Second + time.Nanosecond, "100001S"},
+		{2000 * time.Hour, "7200000S"},
+		{200000 * time.Hour, "12000000M"},
+		{2000000 * time.Hour, "2000000H"},
+	}
+
+	for _, tt := range tests {
+		if got := EncodeTimeout(tt.timeout); got != tt.want {
+			test.Errorf("EncodeTimeout(%v) = %q, want %q", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeout(test *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "500n", want: 500 * time.Nanosecond},
+		{value: "10u", want: 10 * time.Microsecond},
+		{value: "100m", want: 100 * time.Millisecond},
+		{value: "5S", want: 5 * time.Second},
+		{value: "2M", want: 2 * time.Minute},
+		{value: "1H", want: time.Hour},
+		{value: "99999999H", want: time.Duration(1<<63 - 1)},
+		{value: "", wantErr: true},
+		{value: "5", wantErr: true},
+		{value: "5s", wantErr: true},
+		{value: "-5S", wantErr: true},
+		{value: "123456789S", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeout(tt.value)
+		if (err != nil) != tt.wantErr {
+			test.Errorf("ParseTimeout(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			test.Errorf("ParseTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTimeoutRoundTrip(test *testing.T) {
+	for _, timeout := range []time.Duration{time.Nanosecond, 1234 * time.Microsecond, 90 * time.Second, 72 * time.Hour} {
+		got, err := ParseTimeout(EncodeTimeout(timeout))
+		if err != nil {
+			test.Fatalf("ParseTimeout(EncodeTimeout(%v)) error = %v", timeout, err)
+		}
+		if got < timeout {
+			test.Errorf("round trip of %v = %v, want >= original", timeout, got)
+		}
+	}
+}
+
+func TestEncodeConnectTimeout(test *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{0, "0"},
+		{time.Nanosecond, "1"},
+		{1500 * time.Millisecond, "1500"},
+		{1 << 62, "9999999999"},
+	}
+
+	for _, tt := range tests {
+		if got := EncodeConnectTimeout(tt.timeout); got != tt.want {
+			test.Errorf("EncodeConnectTimeout(%v) = %q, want %q", tt.timeout, got, tt.want)
+		}
+	}
+}